@@ -1,8 +1,8 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"os"
@@ -10,33 +10,74 @@ import (
 	"syscall"
 
 	"github.com/kunal/gpu-batch-router/pkg/config"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
 	"github.com/kunal/gpu-batch-router/pkg/router"
+	"github.com/kunal/gpu-batch-router/pkg/tlsutil"
+	"github.com/kunal/gpu-batch-router/pkg/tracing"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
-	cfg := config.Load()
-	log.SetFlags(log.Ltime | log.Lmicroseconds)
-	log.Printf("🧠 Router starting on port %d", cfg.RouterPort)
-	log.Printf("   Dashboard on port %d", cfg.DashboardPort)
-	log.Printf("   Workers: %v", cfg.WorkerEndpoints)
+	configPath := flag.String("config", "", "JSON config file (default $CONFIG_FILE); environment variables override it")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(config.Router, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		cfg.Print(os.Stdout, config.Router)
+		return
+	}
+
+	if err := logging.Setup(cfg.LogOptions(), os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid logging config: %v\n", err)
+		os.Exit(1)
+	}
+	log := logging.For("main")
+	log.Info("router starting",
+		"port", cfg.RouterPort,
+		"dashboard_port", cfg.DashboardPort,
+		"workers", len(cfg.Workers),
+		"registration", cfg.RegistrationToken != "",
+		"discovery", cfg.DiscoveryMode)
 
 	// Create the router
 	r, err := router.New(cfg)
 	if err != nil {
-		log.Fatalf("❌ Failed to create router: %v", err)
+		logging.Fatal(log, "failed to create router", "err", err)
 	}
 
 	// Start metrics poller
 	r.StartPoller()
 
 	// Start gRPC server
-	grpcServer := grpc.NewServer()
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor(), r.UnaryAuthInterceptor()),
+		grpc.StreamInterceptor(r.StreamAuthInterceptor()),
+	}
+	if cfg.TLSCertFile != "" {
+		certs, err := tlsutil.NewReloader(tlsutil.Files{
+			Cert: cfg.TLSCertFile,
+			Key:  cfg.TLSKeyFile,
+			CA:   cfg.TLSClientCAFile,
+		}, cfg.TLSReload)
+		if err != nil {
+			logging.Fatal(log, "failed to load TLS certificates", "err", err)
+		}
+		defer certs.Close()
+		opts = append(opts, grpc.Creds(credentials.NewTLS(certs.ServerConfig())))
+		log.Info("gRPC TLS enabled", "cert", cfg.TLSCertFile, "client_certs_required", cfg.TLSClientCAFile != "")
+	}
+	grpcServer := grpc.NewServer(opts...)
 	r.RegisterGRPC(grpcServer)
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.RouterPort))
 	if err != nil {
-		log.Fatalf("❌ Failed to listen on port %d: %v", cfg.RouterPort, err)
+		logging.Fatal(log, "failed to listen", "port", cfg.RouterPort, "err", err)
 	}
 
 	// Start dashboard HTTP + WebSocket server
@@ -44,17 +85,28 @@ func main() {
 		mux := http.NewServeMux()
 		r.RegisterHTTP(mux)
 		addr := fmt.Sprintf(":%d", cfg.DashboardPort)
-		log.Printf("📊 Dashboard listening on %s", addr)
+		log.Info("dashboard listening", "addr", addr)
 		if err := http.ListenAndServe(addr, mux); err != nil {
-			log.Fatalf("❌ Dashboard server failed: %v", err)
+			logging.Fatal(log, "dashboard server failed", "err", err)
 		}
 	}()
 
 	// Start gRPC in background
 	go func() {
-		log.Printf("🚀 gRPC server listening on %s", lis.Addr().String())
+		log.Info("gRPC server listening", "addr", lis.Addr().String())
 		if err := grpcServer.Serve(lis); err != nil {
-			log.Fatalf("❌ gRPC server failed: %v", err)
+			logging.Fatal(log, "gRPC server failed", "err", err)
+		}
+	}()
+
+	// Reload live settings on SIGHUP, and when the config file changes
+	reloader := config.NewReloader(config.Router, *configPath, cfg, r.Reload)
+	defer reloader.Close()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloader.Reload()
 		}
 	}()
 
@@ -62,8 +114,8 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("🛑 Shutting down router...")
+	log.Info("shutting down router")
 	grpcServer.GracefulStop()
 	r.Stop()
-	log.Println("✅ Router stopped")
+	log.Info("router stopped")
 }
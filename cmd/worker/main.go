@@ -1,43 +1,83 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/kunal/gpu-batch-router/pkg/config"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+	"github.com/kunal/gpu-batch-router/pkg/tlsutil"
+	"github.com/kunal/gpu-batch-router/pkg/tracing"
 	"github.com/kunal/gpu-batch-router/pkg/worker"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
-	cfg := config.Load()
-	log.SetFlags(log.Ltime | log.Lmicroseconds)
-	log.Printf("⚡ Worker %s starting on port %d", cfg.WorkerID, cfg.WorkerPort)
-	log.Printf("   Metrics on port %d", cfg.MetricsPort)
-	log.Printf("   Executor: %s | NVML: %s", cfg.ExecutorType, cfg.UseNVML)
-	log.Printf("   Batch: max_size=%d, max_wait=%v", cfg.MaxBatchSize, cfg.MaxWaitTime)
+	configPath := flag.String("config", "", "JSON config file (default $CONFIG_FILE); environment variables override it")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(config.Worker, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		cfg.Print(os.Stdout, config.Worker)
+		return
+	}
+
+	if err := logging.Setup(cfg.LogOptions(), os.Stderr, "worker_id", cfg.WorkerID); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid logging config: %v\n", err)
+		os.Exit(1)
+	}
+	log := logging.For("main")
+	log.Info("worker starting",
+		"port", cfg.WorkerPort,
+		"metrics_port", cfg.MetricsPort,
+		"executor", cfg.ExecutorType,
+		"nvml", cfg.UseNVML,
+		"max_batch", cfg.MaxBatchSize,
+		"max_wait", cfg.MaxWaitTime)
 
 	// Create the worker
 	w, err := worker.New(cfg)
 	if err != nil {
-		log.Fatalf("❌ Failed to create worker: %v", err)
+		logging.Fatal(log, "failed to create worker", "err", err)
 	}
 
 	// Start the batcher
 	w.StartBatcher()
 
 	// Start gRPC server
-	grpcServer := grpc.NewServer()
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(tracing.UnaryServerInterceptor())}
+	if cfg.TLSCertFile != "" {
+		certs, err := tlsutil.NewReloader(tlsutil.Files{
+			Cert: cfg.TLSCertFile,
+			Key:  cfg.TLSKeyFile,
+			CA:   cfg.TLSClientCAFile,
+		}, cfg.TLSReload)
+		if err != nil {
+			logging.Fatal(log, "failed to load TLS certificates", "err", err)
+		}
+		defer certs.Close()
+		opts = append(opts, grpc.Creds(credentials.NewTLS(certs.ServerConfig())))
+		log.Info("gRPC TLS enabled", "cert", cfg.TLSCertFile, "client_certs_required", cfg.TLSClientCAFile != "")
+	}
+	grpcServer := grpc.NewServer(opts...)
 	w.RegisterGRPC(grpcServer)
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.WorkerPort))
 	if err != nil {
-		log.Fatalf("❌ Failed to listen on port %d: %v", cfg.WorkerPort, err)
+		logging.Fatal(log, "failed to listen", "port", cfg.WorkerPort, "err", err)
 	}
 
 	// Start metrics HTTP server
@@ -45,17 +85,38 @@ func main() {
 		mux := http.NewServeMux()
 		w.RegisterMetricsHTTP(mux)
 		addr := fmt.Sprintf(":%d", cfg.MetricsPort)
-		log.Printf("📊 Metrics endpoint on %s/metrics", addr)
+		log.Info("metrics endpoint listening", "addr", addr, "path", "/metrics")
 		if err := http.ListenAndServe(addr, mux); err != nil {
-			log.Fatalf("❌ Metrics server failed: %v", err)
+			logging.Fatal(log, "metrics server failed", "err", err)
 		}
 	}()
 
 	// Start gRPC in background
 	go func() {
-		log.Printf("🚀 gRPC server listening on %s", lis.Addr().String())
+		log.Info("gRPC server listening", "addr", lis.Addr().String())
 		if err := grpcServer.Serve(lis); err != nil {
-			log.Fatalf("❌ gRPC server failed: %v", err)
+			logging.Fatal(log, "gRPC server failed", "err", err)
+		}
+	}()
+
+	// Register with the router, if we know where it is
+	var reg *worker.Registrar
+	if cfg.RouterAddress != "" {
+		reg, err = w.NewRegistrar(cfg)
+		if err != nil {
+			logging.Fatal(log, "failed to set up registration", "err", err)
+		}
+		reg.Start()
+	}
+
+	// Reload live settings on SIGHUP, and when the config file changes
+	reloader := config.NewReloader(config.Worker, *configPath, cfg, func(c *config.Config) error { w.Reload(c); return nil })
+	defer reloader.Close()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloader.Reload()
 		}
 	}()
 
@@ -63,8 +124,14 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("🛑 Shutting down worker...")
+	log.Info("shutting down worker")
+	if reg != nil {
+		reg.Stop()
+	}
+	w.EndStreams()
 	grpcServer.GracefulStop()
-	w.Stop()
-	log.Println("✅ Worker stopped")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	w.Stop(ctx)
+	log.Info("worker stopped")
 }
@@ -275,6 +275,61 @@ func (*MetricsRequest) Descriptor() ([]byte, []int) {
 	return file_inference_v1_inference_proto_rawDescGZIP(), []int{2}
 }
 
+// WatchMetricsRequest opens a metrics stream. The worker pushes a snapshot
+// straight away, then whenever the metrics change significantly (at most
+// every min_interval_ms), and at least every heartbeat_ms.
+type WatchMetricsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	HeartbeatMs   int32                  `protobuf:"varint,1,opt,name=heartbeat_ms,json=heartbeatMs,proto3" json:"heartbeat_ms,omitempty"`         // 0 = worker default
+	MinIntervalMs int32                  `protobuf:"varint,2,opt,name=min_interval_ms,json=minIntervalMs,proto3" json:"min_interval_ms,omitempty"` // 0 = worker default
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchMetricsRequest) Reset() {
+	*x = WatchMetricsRequest{}
+	mi := &file_inference_v1_inference_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchMetricsRequest) ProtoMessage() {}
+
+func (x *WatchMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_v1_inference_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchMetricsRequest.ProtoReflect.Descriptor instead.
+func (*WatchMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_inference_v1_inference_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *WatchMetricsRequest) GetHeartbeatMs() int32 {
+	if x != nil {
+		return x.HeartbeatMs
+	}
+	return 0
+}
+
+func (x *WatchMetricsRequest) GetMinIntervalMs() int32 {
+	if x != nil {
+		return x.MinIntervalMs
+	}
+	return 0
+}
+
 type WorkerMetrics struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
 	WorkerId       string                 `protobuf:"bytes,1,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
@@ -286,13 +341,37 @@ type WorkerMetrics struct {
 	TemperatureC   float64                `protobuf:"fixed64,7,opt,name=temperature_c,json=temperatureC,proto3" json:"temperature_c,omitempty"`
 	CurrentBatch   int32                  `protobuf:"varint,8,opt,name=current_batch,json=currentBatch,proto3" json:"current_batch,omitempty"`
 	Healthy        bool                   `protobuf:"varint,9,opt,name=healthy,proto3" json:"healthy,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	Devices        []*DeviceMetrics       `protobuf:"bytes,10,rep,name=devices,proto3" json:"devices,omitempty"`                                  // per-GPU breakdown (multi-GPU workers)
+	MetricsSource  string                 `protobuf:"bytes,11,opt,name=metrics_source,json=metricsSource,proto3" json:"metrics_source,omitempty"` // "nvml" (real hardware) or "simulated"
+	// Extended telemetry, aggregated over devices (see DeviceMetrics)
+	PowerDrawW      float64 `protobuf:"fixed64,12,opt,name=power_draw_w,json=powerDrawW,proto3" json:"power_draw_w,omitempty"`             // summed
+	PowerLimitW     float64 `protobuf:"fixed64,13,opt,name=power_limit_w,json=powerLimitW,proto3" json:"power_limit_w,omitempty"`          // summed
+	SmClockMhz      float64 `protobuf:"fixed64,14,opt,name=sm_clock_mhz,json=smClockMhz,proto3" json:"sm_clock_mhz,omitempty"`             // slowest device
+	MemClockMhz     float64 `protobuf:"fixed64,15,opt,name=mem_clock_mhz,json=memClockMhz,proto3" json:"mem_clock_mhz,omitempty"`          // slowest device
+	ThrottleReasons uint64  `protobuf:"varint,16,opt,name=throttle_reasons,json=throttleReasons,proto3" json:"throttle_reasons,omitempty"` // OR of device NVML clock-throttle bitmasks
+	EccCorrected    uint64  `protobuf:"varint,17,opt,name=ecc_corrected,json=eccCorrected,proto3" json:"ecc_corrected,omitempty"`          // summed volatile counts
+	EccUncorrected  uint64  `protobuf:"varint,18,opt,name=ecc_uncorrected,json=eccUncorrected,proto3" json:"ecc_uncorrected,omitempty"`
+	PcieTxKbps      float64 `protobuf:"fixed64,19,opt,name=pcie_tx_kbps,json=pcieTxKbps,proto3" json:"pcie_tx_kbps,omitempty"` // summed
+	PcieRxKbps      float64 `protobuf:"fixed64,20,opt,name=pcie_rx_kbps,json=pcieRxKbps,proto3" json:"pcie_rx_kbps,omitempty"`
+	FanSpeedPct     float64 `protobuf:"fixed64,21,opt,name=fan_speed_pct,json=fanSpeedPct,proto3" json:"fan_speed_pct,omitempty"` // highest device
+	// Time-sliced GPUs: vram_free_gb / vram_total_gb above describe this
+	// worker's share of the device; these carry the raw numbers behind it.
+	ProcessVramUsedGb float64 `protobuf:"fixed64,22,opt,name=process_vram_used_gb,json=processVramUsedGb,proto3" json:"process_vram_used_gb,omitempty"` // held by this worker process (NVML running processes)
+	DeviceVramFreeGb  float64 `protobuf:"fixed64,23,opt,name=device_vram_free_gb,json=deviceVramFreeGb,proto3" json:"device_vram_free_gb,omitempty"`    // whole-device free, shared with other tenants
+	DeviceVramTotalGb float64 `protobuf:"fixed64,24,opt,name=device_vram_total_gb,json=deviceVramTotalGb,proto3" json:"device_vram_total_gb,omitempty"`
+	GpuShare          float64 `protobuf:"fixed64,25,opt,name=gpu_share,json=gpuShare,proto3" json:"gpu_share,omitempty"` // configured fraction of each device (0-1]
+	// Freshness: lets the router age snapshots, drop reordered ones and
+	// notice worker restarts (started_at changes).
+	Seq               uint64 `protobuf:"varint,26,opt,name=seq,proto3" json:"seq,omitempty"` // increases with every snapshot this process produces
+	CollectedAtUnixMs int64  `protobuf:"varint,27,opt,name=collected_at_unix_ms,json=collectedAtUnixMs,proto3" json:"collected_at_unix_ms,omitempty"`
+	StartedAtUnixMs   int64  `protobuf:"varint,28,opt,name=started_at_unix_ms,json=startedAtUnixMs,proto3" json:"started_at_unix_ms,omitempty"` // worker process start
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
 func (x *WorkerMetrics) Reset() {
 	*x = WorkerMetrics{}
-	mi := &file_inference_v1_inference_proto_msgTypes[3]
+	mi := &file_inference_v1_inference_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -304,7 +383,7 @@ func (x *WorkerMetrics) String() string {
 func (*WorkerMetrics) ProtoMessage() {}
 
 func (x *WorkerMetrics) ProtoReflect() protoreflect.Message {
-	mi := &file_inference_v1_inference_proto_msgTypes[3]
+	mi := &file_inference_v1_inference_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -317,7 +396,7 @@ func (x *WorkerMetrics) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use WorkerMetrics.ProtoReflect.Descriptor instead.
 func (*WorkerMetrics) Descriptor() ([]byte, []int) {
-	return file_inference_v1_inference_proto_rawDescGZIP(), []int{3}
+	return file_inference_v1_inference_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *WorkerMetrics) GetWorkerId() string {
@@ -383,6 +462,645 @@ func (x *WorkerMetrics) GetHealthy() bool {
 	return false
 }
 
+func (x *WorkerMetrics) GetDevices() []*DeviceMetrics {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+func (x *WorkerMetrics) GetMetricsSource() string {
+	if x != nil {
+		return x.MetricsSource
+	}
+	return ""
+}
+
+func (x *WorkerMetrics) GetPowerDrawW() float64 {
+	if x != nil {
+		return x.PowerDrawW
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetPowerLimitW() float64 {
+	if x != nil {
+		return x.PowerLimitW
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetSmClockMhz() float64 {
+	if x != nil {
+		return x.SmClockMhz
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetMemClockMhz() float64 {
+	if x != nil {
+		return x.MemClockMhz
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetThrottleReasons() uint64 {
+	if x != nil {
+		return x.ThrottleReasons
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetEccCorrected() uint64 {
+	if x != nil {
+		return x.EccCorrected
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetEccUncorrected() uint64 {
+	if x != nil {
+		return x.EccUncorrected
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetPcieTxKbps() float64 {
+	if x != nil {
+		return x.PcieTxKbps
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetPcieRxKbps() float64 {
+	if x != nil {
+		return x.PcieRxKbps
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetFanSpeedPct() float64 {
+	if x != nil {
+		return x.FanSpeedPct
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetProcessVramUsedGb() float64 {
+	if x != nil {
+		return x.ProcessVramUsedGb
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetDeviceVramFreeGb() float64 {
+	if x != nil {
+		return x.DeviceVramFreeGb
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetDeviceVramTotalGb() float64 {
+	if x != nil {
+		return x.DeviceVramTotalGb
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetGpuShare() float64 {
+	if x != nil {
+		return x.GpuShare
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetCollectedAtUnixMs() int64 {
+	if x != nil {
+		return x.CollectedAtUnixMs
+	}
+	return 0
+}
+
+func (x *WorkerMetrics) GetStartedAtUnixMs() int64 {
+	if x != nil {
+		return x.StartedAtUnixMs
+	}
+	return 0
+}
+
+// DeviceMetrics — one GPU served by a worker. The device-level fields in
+// WorkerMetrics are aggregates over these.
+type DeviceMetrics struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Index             int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`      // CUDA device ordinal
+	Executor          string                 `protobuf:"bytes,2,opt,name=executor,proto3" json:"executor,omitempty"` // e.g. "onnx-gpu", "simulation"
+	VramFreeGb        float64                `protobuf:"fixed64,3,opt,name=vram_free_gb,json=vramFreeGb,proto3" json:"vram_free_gb,omitempty"`
+	VramTotalGb       float64                `protobuf:"fixed64,4,opt,name=vram_total_gb,json=vramTotalGb,proto3" json:"vram_total_gb,omitempty"`
+	GpuUtilization    float64                `protobuf:"fixed64,5,opt,name=gpu_utilization,json=gpuUtilization,proto3" json:"gpu_utilization,omitempty"` // 0-100
+	TemperatureC      float64                `protobuf:"fixed64,6,opt,name=temperature_c,json=temperatureC,proto3" json:"temperature_c,omitempty"`
+	CurrentBatch      int32                  `protobuf:"varint,7,opt,name=current_batch,json=currentBatch,proto3" json:"current_batch,omitempty"`
+	AvgLatencyMs      float64                `protobuf:"fixed64,8,opt,name=avg_latency_ms,json=avgLatencyMs,proto3" json:"avg_latency_ms,omitempty"`
+	TotalBatches      int64                  `protobuf:"varint,9,opt,name=total_batches,json=totalBatches,proto3" json:"total_batches,omitempty"`
+	PowerDrawW        float64                `protobuf:"fixed64,10,opt,name=power_draw_w,json=powerDrawW,proto3" json:"power_draw_w,omitempty"`
+	PowerLimitW       float64                `protobuf:"fixed64,11,opt,name=power_limit_w,json=powerLimitW,proto3" json:"power_limit_w,omitempty"`
+	SmClockMhz        float64                `protobuf:"fixed64,12,opt,name=sm_clock_mhz,json=smClockMhz,proto3" json:"sm_clock_mhz,omitempty"`
+	MemClockMhz       float64                `protobuf:"fixed64,13,opt,name=mem_clock_mhz,json=memClockMhz,proto3" json:"mem_clock_mhz,omitempty"`
+	ThrottleReasons   uint64                 `protobuf:"varint,14,opt,name=throttle_reasons,json=throttleReasons,proto3" json:"throttle_reasons,omitempty"` // NVML clock-throttle reason bitmask
+	EccCorrected      uint64                 `protobuf:"varint,15,opt,name=ecc_corrected,json=eccCorrected,proto3" json:"ecc_corrected,omitempty"`
+	EccUncorrected    uint64                 `protobuf:"varint,16,opt,name=ecc_uncorrected,json=eccUncorrected,proto3" json:"ecc_uncorrected,omitempty"`
+	PcieTxKbps        float64                `protobuf:"fixed64,17,opt,name=pcie_tx_kbps,json=pcieTxKbps,proto3" json:"pcie_tx_kbps,omitempty"`
+	PcieRxKbps        float64                `protobuf:"fixed64,18,opt,name=pcie_rx_kbps,json=pcieRxKbps,proto3" json:"pcie_rx_kbps,omitempty"`
+	FanSpeedPct       float64                `protobuf:"fixed64,19,opt,name=fan_speed_pct,json=fanSpeedPct,proto3" json:"fan_speed_pct,omitempty"`
+	ProcessVramUsedGb float64                `protobuf:"fixed64,20,opt,name=process_vram_used_gb,json=processVramUsedGb,proto3" json:"process_vram_used_gb,omitempty"`
+	DeviceVramFreeGb  float64                `protobuf:"fixed64,21,opt,name=device_vram_free_gb,json=deviceVramFreeGb,proto3" json:"device_vram_free_gb,omitempty"`
+	DeviceVramTotalGb float64                `protobuf:"fixed64,22,opt,name=device_vram_total_gb,json=deviceVramTotalGb,proto3" json:"device_vram_total_gb,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *DeviceMetrics) Reset() {
+	*x = DeviceMetrics{}
+	mi := &file_inference_v1_inference_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeviceMetrics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceMetrics) ProtoMessage() {}
+
+func (x *DeviceMetrics) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_v1_inference_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceMetrics.ProtoReflect.Descriptor instead.
+func (*DeviceMetrics) Descriptor() ([]byte, []int) {
+	return file_inference_v1_inference_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeviceMetrics) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetExecutor() string {
+	if x != nil {
+		return x.Executor
+	}
+	return ""
+}
+
+func (x *DeviceMetrics) GetVramFreeGb() float64 {
+	if x != nil {
+		return x.VramFreeGb
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetVramTotalGb() float64 {
+	if x != nil {
+		return x.VramTotalGb
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetGpuUtilization() float64 {
+	if x != nil {
+		return x.GpuUtilization
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetTemperatureC() float64 {
+	if x != nil {
+		return x.TemperatureC
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetCurrentBatch() int32 {
+	if x != nil {
+		return x.CurrentBatch
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetAvgLatencyMs() float64 {
+	if x != nil {
+		return x.AvgLatencyMs
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetTotalBatches() int64 {
+	if x != nil {
+		return x.TotalBatches
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetPowerDrawW() float64 {
+	if x != nil {
+		return x.PowerDrawW
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetPowerLimitW() float64 {
+	if x != nil {
+		return x.PowerLimitW
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetSmClockMhz() float64 {
+	if x != nil {
+		return x.SmClockMhz
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetMemClockMhz() float64 {
+	if x != nil {
+		return x.MemClockMhz
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetThrottleReasons() uint64 {
+	if x != nil {
+		return x.ThrottleReasons
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetEccCorrected() uint64 {
+	if x != nil {
+		return x.EccCorrected
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetEccUncorrected() uint64 {
+	if x != nil {
+		return x.EccUncorrected
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetPcieTxKbps() float64 {
+	if x != nil {
+		return x.PcieTxKbps
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetPcieRxKbps() float64 {
+	if x != nil {
+		return x.PcieRxKbps
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetFanSpeedPct() float64 {
+	if x != nil {
+		return x.FanSpeedPct
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetProcessVramUsedGb() float64 {
+	if x != nil {
+		return x.ProcessVramUsedGb
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetDeviceVramFreeGb() float64 {
+	if x != nil {
+		return x.DeviceVramFreeGb
+	}
+	return 0
+}
+
+func (x *DeviceMetrics) GetDeviceVramTotalGb() float64 {
+	if x != nil {
+		return x.DeviceVramTotalGb
+	}
+	return 0
+}
+
+// RegisterRequest adds a worker to the router, or refreshes it. A worker
+// registering under its ID from a new address replaces the old entry.
+type RegisterRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkerId      string                 `protobuf:"bytes,1,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	Address       string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`    // host:port the router should dial
+	Models        []string               `protobuf:"bytes,3,rep,name=models,proto3" json:"models,omitempty"`      // models served; empty = any
+	Capacity      int32                  `protobuf:"varint,4,opt,name=capacity,proto3" json:"capacity,omitempty"` // requests it can run at once (devices × max batch size)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterRequest) Reset() {
+	*x = RegisterRequest{}
+	mi := &file_inference_v1_inference_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterRequest) ProtoMessage() {}
+
+func (x *RegisterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_v1_inference_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterRequest.ProtoReflect.Descriptor instead.
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return file_inference_v1_inference_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RegisterRequest) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetModels() []string {
+	if x != nil {
+		return x.Models
+	}
+	return nil
+}
+
+func (x *RegisterRequest) GetCapacity() int32 {
+	if x != nil {
+		return x.Capacity
+	}
+	return 0
+}
+
+type RegisterResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	HeartbeatMs   int32                  `protobuf:"varint,1,opt,name=heartbeat_ms,json=heartbeatMs,proto3" json:"heartbeat_ms,omitempty"` // heartbeat at least this often; three missed ones expire the worker
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterResponse) Reset() {
+	*x = RegisterResponse{}
+	mi := &file_inference_v1_inference_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterResponse) ProtoMessage() {}
+
+func (x *RegisterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_v1_inference_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterResponse.ProtoReflect.Descriptor instead.
+func (*RegisterResponse) Descriptor() ([]byte, []int) {
+	return file_inference_v1_inference_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RegisterResponse) GetHeartbeatMs() int32 {
+	if x != nil {
+		return x.HeartbeatMs
+	}
+	return 0
+}
+
+type HeartbeatRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkerId      string                 `protobuf:"bytes,1,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	Address       string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeartbeatRequest) Reset() {
+	*x = HeartbeatRequest{}
+	mi := &file_inference_v1_inference_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeartbeatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeartbeatRequest) ProtoMessage() {}
+
+func (x *HeartbeatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_v1_inference_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeartbeatRequest.ProtoReflect.Descriptor instead.
+func (*HeartbeatRequest) Descriptor() ([]byte, []int) {
+	return file_inference_v1_inference_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *HeartbeatRequest) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+func (x *HeartbeatRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+// HeartbeatResponse is empty; a worker the router doesn't know gets
+// NOT_FOUND and should register again.
+type HeartbeatResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeartbeatResponse) Reset() {
+	*x = HeartbeatResponse{}
+	mi := &file_inference_v1_inference_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeartbeatResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeartbeatResponse) ProtoMessage() {}
+
+func (x *HeartbeatResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_v1_inference_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeartbeatResponse.ProtoReflect.Descriptor instead.
+func (*HeartbeatResponse) Descriptor() ([]byte, []int) {
+	return file_inference_v1_inference_proto_rawDescGZIP(), []int{9}
+}
+
+type DeregisterRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkerId      string                 `protobuf:"bytes,1,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	Address       string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeregisterRequest) Reset() {
+	*x = DeregisterRequest{}
+	mi := &file_inference_v1_inference_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeregisterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeregisterRequest) ProtoMessage() {}
+
+func (x *DeregisterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_v1_inference_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeregisterRequest.ProtoReflect.Descriptor instead.
+func (*DeregisterRequest) Descriptor() ([]byte, []int) {
+	return file_inference_v1_inference_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeregisterRequest) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+func (x *DeregisterRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type DeregisterResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeregisterResponse) Reset() {
+	*x = DeregisterResponse{}
+	mi := &file_inference_v1_inference_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeregisterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeregisterResponse) ProtoMessage() {}
+
+func (x *DeregisterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_v1_inference_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeregisterResponse.ProtoReflect.Descriptor instead.
+func (*DeregisterResponse) Descriptor() ([]byte, []int) {
+	return file_inference_v1_inference_proto_rawDescGZIP(), []int{11}
+}
+
 var File_inference_v1_inference_proto protoreflect.FileDescriptor
 
 const file_inference_v1_inference_proto_rawDesc = "" +
@@ -407,7 +1125,10 @@ const file_inference_v1_inference_proto_rawDesc = "" +
 	"batch_size\x18\x05 \x01(\x05R\tbatchSize\x12\"\n" +
 	"\rqueue_wait_ms\x18\x06 \x01(\x05R\vqueueWaitMs\x12#\n" +
 	"\rpriority_used\x18\a \x01(\tR\fpriorityUsed\"\x10\n" +
-	"\x0eMetricsRequest\"\xc6\x02\n" +
+	"\x0eMetricsRequest\"`\n" +
+	"\x13WatchMetricsRequest\x12!\n" +
+	"\fheartbeat_ms\x18\x01 \x01(\x05R\vheartbeatMs\x12&\n" +
+	"\x0fmin_interval_ms\x18\x02 \x01(\x05R\rminIntervalMs\"\xaf\b\n" +
 	"\rWorkerMetrics\x12\x1b\n" +
 	"\tworker_id\x18\x01 \x01(\tR\bworkerId\x12 \n" +
 	"\fvram_free_gb\x18\x02 \x01(\x01R\n" +
@@ -419,17 +1140,91 @@ const file_inference_v1_inference_proto_rawDesc = "" +
 	"\x0fgpu_utilization\x18\x06 \x01(\x01R\x0egpuUtilization\x12#\n" +
 	"\rtemperature_c\x18\a \x01(\x01R\ftemperatureC\x12#\n" +
 	"\rcurrent_batch\x18\b \x01(\x05R\fcurrentBatch\x12\x18\n" +
-	"\ahealthy\x18\t \x01(\bR\ahealthy*)\n" +
+	"\ahealthy\x18\t \x01(\bR\ahealthy\x125\n" +
+	"\adevices\x18\n" +
+	" \x03(\v2\x1b.inference.v1.DeviceMetricsR\adevices\x12%\n" +
+	"\x0emetrics_source\x18\v \x01(\tR\rmetricsSource\x12 \n" +
+	"\fpower_draw_w\x18\f \x01(\x01R\n" +
+	"powerDrawW\x12\"\n" +
+	"\rpower_limit_w\x18\r \x01(\x01R\vpowerLimitW\x12 \n" +
+	"\fsm_clock_mhz\x18\x0e \x01(\x01R\n" +
+	"smClockMhz\x12\"\n" +
+	"\rmem_clock_mhz\x18\x0f \x01(\x01R\vmemClockMhz\x12)\n" +
+	"\x10throttle_reasons\x18\x10 \x01(\x04R\x0fthrottleReasons\x12#\n" +
+	"\recc_corrected\x18\x11 \x01(\x04R\feccCorrected\x12'\n" +
+	"\x0fecc_uncorrected\x18\x12 \x01(\x04R\x0eeccUncorrected\x12 \n" +
+	"\fpcie_tx_kbps\x18\x13 \x01(\x01R\n" +
+	"pcieTxKbps\x12 \n" +
+	"\fpcie_rx_kbps\x18\x14 \x01(\x01R\n" +
+	"pcieRxKbps\x12\"\n" +
+	"\rfan_speed_pct\x18\x15 \x01(\x01R\vfanSpeedPct\x12/\n" +
+	"\x14process_vram_used_gb\x18\x16 \x01(\x01R\x11processVramUsedGb\x12-\n" +
+	"\x13device_vram_free_gb\x18\x17 \x01(\x01R\x10deviceVramFreeGb\x12/\n" +
+	"\x14device_vram_total_gb\x18\x18 \x01(\x01R\x11deviceVramTotalGb\x12\x1b\n" +
+	"\tgpu_share\x18\x19 \x01(\x01R\bgpuShare\x12\x10\n" +
+	"\x03seq\x18\x1a \x01(\x04R\x03seq\x12/\n" +
+	"\x14collected_at_unix_ms\x18\x1b \x01(\x03R\x11collectedAtUnixMs\x12+\n" +
+	"\x12started_at_unix_ms\x18\x1c \x01(\x03R\x0fstartedAtUnixMs\"\xc3\x06\n" +
+	"\rDeviceMetrics\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x1a\n" +
+	"\bexecutor\x18\x02 \x01(\tR\bexecutor\x12 \n" +
+	"\fvram_free_gb\x18\x03 \x01(\x01R\n" +
+	"vramFreeGb\x12\"\n" +
+	"\rvram_total_gb\x18\x04 \x01(\x01R\vvramTotalGb\x12'\n" +
+	"\x0fgpu_utilization\x18\x05 \x01(\x01R\x0egpuUtilization\x12#\n" +
+	"\rtemperature_c\x18\x06 \x01(\x01R\ftemperatureC\x12#\n" +
+	"\rcurrent_batch\x18\a \x01(\x05R\fcurrentBatch\x12$\n" +
+	"\x0eavg_latency_ms\x18\b \x01(\x01R\favgLatencyMs\x12#\n" +
+	"\rtotal_batches\x18\t \x01(\x03R\ftotalBatches\x12 \n" +
+	"\fpower_draw_w\x18\n" +
+	" \x01(\x01R\n" +
+	"powerDrawW\x12\"\n" +
+	"\rpower_limit_w\x18\v \x01(\x01R\vpowerLimitW\x12 \n" +
+	"\fsm_clock_mhz\x18\f \x01(\x01R\n" +
+	"smClockMhz\x12\"\n" +
+	"\rmem_clock_mhz\x18\r \x01(\x01R\vmemClockMhz\x12)\n" +
+	"\x10throttle_reasons\x18\x0e \x01(\x04R\x0fthrottleReasons\x12#\n" +
+	"\recc_corrected\x18\x0f \x01(\x04R\feccCorrected\x12'\n" +
+	"\x0fecc_uncorrected\x18\x10 \x01(\x04R\x0eeccUncorrected\x12 \n" +
+	"\fpcie_tx_kbps\x18\x11 \x01(\x01R\n" +
+	"pcieTxKbps\x12 \n" +
+	"\fpcie_rx_kbps\x18\x12 \x01(\x01R\n" +
+	"pcieRxKbps\x12\"\n" +
+	"\rfan_speed_pct\x18\x13 \x01(\x01R\vfanSpeedPct\x12/\n" +
+	"\x14process_vram_used_gb\x18\x14 \x01(\x01R\x11processVramUsedGb\x12-\n" +
+	"\x13device_vram_free_gb\x18\x15 \x01(\x01R\x10deviceVramFreeGb\x12/\n" +
+	"\x14device_vram_total_gb\x18\x16 \x01(\x01R\x11deviceVramTotalGb\"|\n" +
+	"\x0fRegisterRequest\x12\x1b\n" +
+	"\tworker_id\x18\x01 \x01(\tR\bworkerId\x12\x18\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\x12\x16\n" +
+	"\x06models\x18\x03 \x03(\tR\x06models\x12\x1a\n" +
+	"\bcapacity\x18\x04 \x01(\x05R\bcapacity\"5\n" +
+	"\x10RegisterResponse\x12!\n" +
+	"\fheartbeat_ms\x18\x01 \x01(\x05R\vheartbeatMs\"I\n" +
+	"\x10HeartbeatRequest\x12\x1b\n" +
+	"\tworker_id\x18\x01 \x01(\tR\bworkerId\x12\x18\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\"\x13\n" +
+	"\x11HeartbeatResponse\"J\n" +
+	"\x11DeregisterRequest\x12\x1b\n" +
+	"\tworker_id\x18\x01 \x01(\tR\bworkerId\x12\x18\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\"\x14\n" +
+	"\x12DeregisterResponse*)\n" +
 	"\bPriority\x12\a\n" +
 	"\x03LOW\x10\x00\x12\n" +
 	"\n" +
 	"\x06MEDIUM\x10\x01\x12\b\n" +
 	"\x04HIGH\x10\x022T\n" +
 	"\x10InferenceService\x12@\n" +
-	"\x05Infer\x12\x1a.inference.v1.InferRequest\x1a\x1b.inference.v1.InferResponse2_\n" +
+	"\x05Infer\x12\x1a.inference.v1.InferRequest\x1a\x1b.inference.v1.InferResponse2\xb1\x01\n" +
 	"\x14WorkerMetricsService\x12G\n" +
 	"\n" +
-	"GetMetrics\x12\x1c.inference.v1.MetricsRequest\x1a\x1b.inference.v1.WorkerMetricsB@Z>github.com/kunal/gpu-batch-router/gen/inference/v1;inferencev1b\x06proto3"
+	"GetMetrics\x12\x1c.inference.v1.MetricsRequest\x1a\x1b.inference.v1.WorkerMetrics\x12P\n" +
+	"\fWatchMetrics\x12!.inference.v1.WatchMetricsRequest\x1a\x1b.inference.v1.WorkerMetrics0\x012\xff\x01\n" +
+	"\x13RegistrationService\x12I\n" +
+	"\bRegister\x12\x1d.inference.v1.RegisterRequest\x1a\x1e.inference.v1.RegisterResponse\x12L\n" +
+	"\tHeartbeat\x12\x1e.inference.v1.HeartbeatRequest\x1a\x1f.inference.v1.HeartbeatResponse\x12O\n" +
+	"\n" +
+	"Deregister\x12\x1f.inference.v1.DeregisterRequest\x1a .inference.v1.DeregisterResponseB@Z>github.com/kunal/gpu-batch-router/gen/inference/v1;inferencev1b\x06proto3"
 
 var (
 	file_inference_v1_inference_proto_rawDescOnce sync.Once
@@ -444,25 +1239,42 @@ func file_inference_v1_inference_proto_rawDescGZIP() []byte {
 }
 
 var file_inference_v1_inference_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_inference_v1_inference_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_inference_v1_inference_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_inference_v1_inference_proto_goTypes = []any{
-	(Priority)(0),          // 0: inference.v1.Priority
-	(*InferRequest)(nil),   // 1: inference.v1.InferRequest
-	(*InferResponse)(nil),  // 2: inference.v1.InferResponse
-	(*MetricsRequest)(nil), // 3: inference.v1.MetricsRequest
-	(*WorkerMetrics)(nil),  // 4: inference.v1.WorkerMetrics
+	(Priority)(0),               // 0: inference.v1.Priority
+	(*InferRequest)(nil),        // 1: inference.v1.InferRequest
+	(*InferResponse)(nil),       // 2: inference.v1.InferResponse
+	(*MetricsRequest)(nil),      // 3: inference.v1.MetricsRequest
+	(*WatchMetricsRequest)(nil), // 4: inference.v1.WatchMetricsRequest
+	(*WorkerMetrics)(nil),       // 5: inference.v1.WorkerMetrics
+	(*DeviceMetrics)(nil),       // 6: inference.v1.DeviceMetrics
+	(*RegisterRequest)(nil),     // 7: inference.v1.RegisterRequest
+	(*RegisterResponse)(nil),    // 8: inference.v1.RegisterResponse
+	(*HeartbeatRequest)(nil),    // 9: inference.v1.HeartbeatRequest
+	(*HeartbeatResponse)(nil),   // 10: inference.v1.HeartbeatResponse
+	(*DeregisterRequest)(nil),   // 11: inference.v1.DeregisterRequest
+	(*DeregisterResponse)(nil),  // 12: inference.v1.DeregisterResponse
 }
 var file_inference_v1_inference_proto_depIdxs = []int32{
-	0, // 0: inference.v1.InferRequest.priority:type_name -> inference.v1.Priority
-	1, // 1: inference.v1.InferenceService.Infer:input_type -> inference.v1.InferRequest
-	3, // 2: inference.v1.WorkerMetricsService.GetMetrics:input_type -> inference.v1.MetricsRequest
-	2, // 3: inference.v1.InferenceService.Infer:output_type -> inference.v1.InferResponse
-	4, // 4: inference.v1.WorkerMetricsService.GetMetrics:output_type -> inference.v1.WorkerMetrics
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: inference.v1.InferRequest.priority:type_name -> inference.v1.Priority
+	6,  // 1: inference.v1.WorkerMetrics.devices:type_name -> inference.v1.DeviceMetrics
+	1,  // 2: inference.v1.InferenceService.Infer:input_type -> inference.v1.InferRequest
+	3,  // 3: inference.v1.WorkerMetricsService.GetMetrics:input_type -> inference.v1.MetricsRequest
+	4,  // 4: inference.v1.WorkerMetricsService.WatchMetrics:input_type -> inference.v1.WatchMetricsRequest
+	7,  // 5: inference.v1.RegistrationService.Register:input_type -> inference.v1.RegisterRequest
+	9,  // 6: inference.v1.RegistrationService.Heartbeat:input_type -> inference.v1.HeartbeatRequest
+	11, // 7: inference.v1.RegistrationService.Deregister:input_type -> inference.v1.DeregisterRequest
+	2,  // 8: inference.v1.InferenceService.Infer:output_type -> inference.v1.InferResponse
+	5,  // 9: inference.v1.WorkerMetricsService.GetMetrics:output_type -> inference.v1.WorkerMetrics
+	5,  // 10: inference.v1.WorkerMetricsService.WatchMetrics:output_type -> inference.v1.WorkerMetrics
+	8,  // 11: inference.v1.RegistrationService.Register:output_type -> inference.v1.RegisterResponse
+	10, // 12: inference.v1.RegistrationService.Heartbeat:output_type -> inference.v1.HeartbeatResponse
+	12, // 13: inference.v1.RegistrationService.Deregister:output_type -> inference.v1.DeregisterResponse
+	8,  // [8:14] is the sub-list for method output_type
+	2,  // [2:8] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_inference_v1_inference_proto_init() }
@@ -476,9 +1288,9 @@ func file_inference_v1_inference_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_inference_v1_inference_proto_rawDesc), len(file_inference_v1_inference_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   4,
+			NumMessages:   12,
 			NumExtensions: 0,
-			NumServices:   2,
+			NumServices:   3,
 		},
 		GoTypes:           file_inference_v1_inference_proto_goTypes,
 		DependencyIndexes: file_inference_v1_inference_proto_depIdxs,
@@ -125,16 +125,19 @@ var InferenceService_ServiceDesc = grpc.ServiceDesc{
 }
 
 const (
-	WorkerMetricsService_GetMetrics_FullMethodName = "/inference.v1.WorkerMetricsService/GetMetrics"
+	WorkerMetricsService_GetMetrics_FullMethodName   = "/inference.v1.WorkerMetricsService/GetMetrics"
+	WorkerMetricsService_WatchMetrics_FullMethodName = "/inference.v1.WorkerMetricsService/WatchMetrics"
 )
 
 // WorkerMetricsServiceClient is the client API for WorkerMetricsService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 //
-// WorkerMetricsService — Router polls each worker for GPU stats
+// WorkerMetricsService — Router polls each worker for GPU stats, or
+// subscribes with WatchMetrics to have them pushed
 type WorkerMetricsServiceClient interface {
 	GetMetrics(ctx context.Context, in *MetricsRequest, opts ...grpc.CallOption) (*WorkerMetrics, error)
+	WatchMetrics(ctx context.Context, in *WatchMetricsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WorkerMetrics], error)
 }
 
 type workerMetricsServiceClient struct {
@@ -155,13 +158,34 @@ func (c *workerMetricsServiceClient) GetMetrics(ctx context.Context, in *Metrics
 	return out, nil
 }
 
+func (c *workerMetricsServiceClient) WatchMetrics(ctx context.Context, in *WatchMetricsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WorkerMetrics], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WorkerMetricsService_ServiceDesc.Streams[0], WorkerMetricsService_WatchMetrics_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchMetricsRequest, WorkerMetrics]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WorkerMetricsService_WatchMetricsClient = grpc.ServerStreamingClient[WorkerMetrics]
+
 // WorkerMetricsServiceServer is the server API for WorkerMetricsService service.
 // All implementations must embed UnimplementedWorkerMetricsServiceServer
 // for forward compatibility.
 //
-// WorkerMetricsService — Router polls each worker for GPU stats
+// WorkerMetricsService — Router polls each worker for GPU stats, or
+// subscribes with WatchMetrics to have them pushed
 type WorkerMetricsServiceServer interface {
 	GetMetrics(context.Context, *MetricsRequest) (*WorkerMetrics, error)
+	WatchMetrics(*WatchMetricsRequest, grpc.ServerStreamingServer[WorkerMetrics]) error
 	mustEmbedUnimplementedWorkerMetricsServiceServer()
 }
 
@@ -175,6 +199,9 @@ type UnimplementedWorkerMetricsServiceServer struct{}
 func (UnimplementedWorkerMetricsServiceServer) GetMetrics(context.Context, *MetricsRequest) (*WorkerMetrics, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetMetrics not implemented")
 }
+func (UnimplementedWorkerMetricsServiceServer) WatchMetrics(*WatchMetricsRequest, grpc.ServerStreamingServer[WorkerMetrics]) error {
+	return status.Error(codes.Unimplemented, "method WatchMetrics not implemented")
+}
 func (UnimplementedWorkerMetricsServiceServer) mustEmbedUnimplementedWorkerMetricsServiceServer() {}
 func (UnimplementedWorkerMetricsServiceServer) testEmbeddedByValue()                              {}
 
@@ -214,6 +241,17 @@ func _WorkerMetricsService_GetMetrics_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WorkerMetricsService_WatchMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchMetricsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WorkerMetricsServiceServer).WatchMetrics(m, &grpc.GenericServerStream[WatchMetricsRequest, WorkerMetrics]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WorkerMetricsService_WatchMetricsServer = grpc.ServerStreamingServer[WorkerMetrics]
+
 // WorkerMetricsService_ServiceDesc is the grpc.ServiceDesc for WorkerMetricsService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -226,6 +264,198 @@ var WorkerMetricsService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _WorkerMetricsService_GetMetrics_Handler,
 		},
 	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchMetrics",
+			Handler:       _WorkerMetricsService_WatchMetrics_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "inference/v1/inference.proto",
+}
+
+const (
+	RegistrationService_Register_FullMethodName   = "/inference.v1.RegistrationService/Register"
+	RegistrationService_Heartbeat_FullMethodName  = "/inference.v1.RegistrationService/Heartbeat"
+	RegistrationService_Deregister_FullMethodName = "/inference.v1.RegistrationService/Deregister"
+)
+
+// RegistrationServiceClient is the client API for RegistrationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RegistrationService — Worker → Router. Workers that know the router's
+// address register themselves instead of being listed in its config,
+// then heartbeat to stay registered.
+type RegistrationServiceClient interface {
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
+	Deregister(ctx context.Context, in *DeregisterRequest, opts ...grpc.CallOption) (*DeregisterResponse, error)
+}
+
+type registrationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRegistrationServiceClient(cc grpc.ClientConnInterface) RegistrationServiceClient {
+	return &registrationServiceClient{cc}
+}
+
+func (c *registrationServiceClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, RegistrationService_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registrationServiceClient) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HeartbeatResponse)
+	err := c.cc.Invoke(ctx, RegistrationService_Heartbeat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registrationServiceClient) Deregister(ctx context.Context, in *DeregisterRequest, opts ...grpc.CallOption) (*DeregisterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeregisterResponse)
+	err := c.cc.Invoke(ctx, RegistrationService_Deregister_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RegistrationServiceServer is the server API for RegistrationService service.
+// All implementations must embed UnimplementedRegistrationServiceServer
+// for forward compatibility.
+//
+// RegistrationService — Worker → Router. Workers that know the router's
+// address register themselves instead of being listed in its config,
+// then heartbeat to stay registered.
+type RegistrationServiceServer interface {
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+	Deregister(context.Context, *DeregisterRequest) (*DeregisterResponse, error)
+	mustEmbedUnimplementedRegistrationServiceServer()
+}
+
+// UnimplementedRegistrationServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRegistrationServiceServer struct{}
+
+func (UnimplementedRegistrationServiceServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedRegistrationServiceServer) Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedRegistrationServiceServer) Deregister(context.Context, *DeregisterRequest) (*DeregisterResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Deregister not implemented")
+}
+func (UnimplementedRegistrationServiceServer) mustEmbedUnimplementedRegistrationServiceServer() {}
+func (UnimplementedRegistrationServiceServer) testEmbeddedByValue()                             {}
+
+// UnsafeRegistrationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RegistrationServiceServer will
+// result in compilation errors.
+type UnsafeRegistrationServiceServer interface {
+	mustEmbedUnimplementedRegistrationServiceServer()
+}
+
+func RegisterRegistrationServiceServer(s grpc.ServiceRegistrar, srv RegistrationServiceServer) {
+	// If the following call panics, it indicates UnimplementedRegistrationServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RegistrationService_ServiceDesc, srv)
+}
+
+func _RegistrationService_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistrationServiceServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistrationService_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistrationServiceServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistrationService_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistrationServiceServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistrationService_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistrationServiceServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistrationService_Deregister_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeregisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistrationServiceServer).Deregister(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistrationService_Deregister_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistrationServiceServer).Deregister(ctx, req.(*DeregisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RegistrationService_ServiceDesc is the grpc.ServiceDesc for RegistrationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RegistrationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "inference.v1.RegistrationService",
+	HandlerType: (*RegistrationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _RegistrationService_Register_Handler,
+		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _RegistrationService_Heartbeat_Handler,
+		},
+		{
+			MethodName: "Deregister",
+			Handler:    _RegistrationService_Deregister_Handler,
+		},
+	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "inference/v1/inference.proto",
 }
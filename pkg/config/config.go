@@ -1,10 +1,9 @@
 package config
 
 import (
-	"os"
-	"strconv"
-	"strings"
 	"time"
+
+	"github.com/kunal/gpu-batch-router/pkg/logging"
 )
 
 // Config holds all configuration for both router and worker services.
@@ -13,55 +12,191 @@ type Config struct {
 	WorkerID string
 
 	// Router
-	RouterPort      int
-	WorkerEndpoints []string
-	PollInterval    time.Duration
-	DashboardPort   int
+	RouterPort        int
+	Workers           []WorkerConfig
+	PollInterval      time.Duration
+	MetricsMode       string        // "stream" (WatchMetrics, polling fallback) or "poll"
+	MetricsHeartbeat  time.Duration // max gap between pushes on a metrics stream
+	MetricsStaleAfter time.Duration // metrics older than this start losing score
+	MetricsMaxAge     time.Duration // ... and carry the full stale penalty from here
+	HistoryRetention  time.Duration // how much metrics history the dashboard can chart
+	HistoryInterval   time.Duration // sampling interval for that history
+	AdminToken        string        // bearer token for the admin API; empty disables it
+	DashboardPort     int
+	Scoring           Scoring
+	Retries           Retries
+
+	// Dashboard access (router)
+	DashboardAuth         string        // "none", "token" or "basic"
+	DashboardTokens       string        // token mode: "role:token,..." with role read or admin
+	DashboardUsers        string        // basic mode: "user:role:password,..."; password may be "sha256:<hex>"
+	SessionSecret         string        // signs session cookies; empty sends credentials every request
+	SessionTTL            time.Duration // session cookie lifetime
+	WSAllowedOrigins      []string      // origins besides the dashboard's own allowed to open /ws ("*" for any)
+	DashboardPushInterval time.Duration // default interval between dashboard updates
 
 	// Worker
 	WorkerPort   int
 	MetricsPort  int
 	MaxBatchSize int
 	MaxWaitTime  time.Duration
-	ExecutorType string // "simulation" or "onnx"
-	UseNVML      string // "auto", "true", "false"
+	BatchTimeout time.Duration // deadline for a single executor call
+	ExecutorType string        // "simulation" or "onnx"
+	GPUDevices   string        // "" (device 0), "all", or "0,1,..."
+	GPUShare     float64       // fraction of each device's memory this worker may use (time-slicing)
+	UseNVML      string        // "auto", "true", "false"
+
+	// TLS (both services). The gRPC listener serves TLS when a certificate
+	// is set, and requires client certificates when a client CA is set too.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+	TLSReload       time.Duration // how often certificate files are checked for rotation
+
+	// Router to worker TLS; enabled when either the CA or a client
+	// certificate is set. Workers are verified against the ID in their
+	// endpoint ("id@host:port"), or the host otherwise.
+	WorkerTLSCAFile   string
+	WorkerTLSCertFile string
+	WorkerTLSKeyFile  string
+
+	// gRPC auth (router). Off unless a tenants file is given.
+	AuthTenantsFile  string // JSON tenants: API keys, allowed models, max priority
+	JWTSecret        string // HS256 key; empty rejects HS256 tokens
+	JWTPublicKeyFile string // RS256 public key (PEM); empty rejects RS256 tokens
+	JWTIssuer        string // required iss, if set
+	JWTAudience      string // required aud, if set
+	JWTTenantClaim   string // claim naming the caller's tenant
+
+	// Self-registration. The router accepts RegistrationService calls that
+	// carry the token, and turns them away if it is empty; a worker with a
+	// router address registers there and heartbeats until it stops.
+	RegistrationToken     string
+	RegistrationHeartbeat time.Duration // router: how often workers are told to heartbeat
+	RouterAddress         string        // worker: router to register with
+	AdvertiseAddress      string        // worker: host:port the router should dial
+	RouterTLSCAFile       string        // worker: CA for the router's certificate; enables TLS to it
+	Models                []string      // worker: models served, sent when registering; empty = any
+
+	// DNS discovery (router). Workers are looked up every DiscoveryInterval,
+	// added as they appear and drained as they disappear.
+	DiscoveryMode     string        // "" (off), "dns" (A/AAAA of host:port) or "srv"
+	DiscoveryName     string        // dns: "host:port"; srv: "_service._proto.name"
+	DiscoveryInterval time.Duration // how often the name is resolved again
+
+	// Config reload (both services)
+	ConfigWatch time.Duration // how often the config file is checked for changes; 0 reloads on SIGHUP only
+
+	// Tracing (both services)
+	TraceExport     string  // "" (off), "json" or "otlp"
+	TraceFile       string  // defaults to <service>-traces.jsonl
+	TraceSampleRate float64 // fraction of new traces recorded
+
+	// Logging (both services)
+	LogLevel            string // debug, info, warn, error
+	LogFormat           string // text or json
+	LogComponents       string // per-component levels, e.g. "batcher=debug,poller=warn"
+	LogSampleInitial    int    // per message per second, log the first N...
+	LogSampleThereafter int    // ...then every Mth
 }
 
-// Load reads configuration from environment variables with sane defaults.
-func Load() *Config {
-	c := &Config{
-		WorkerID:     envStr("WORKER_ID", "worker-0"),
-		RouterPort:   envInt("ROUTER_PORT", 50051),
-		WorkerPort:   envInt("WORKER_PORT", 50052),
-		MetricsPort:  envInt("METRICS_PORT", 9090),
-		DashboardPort: envInt("DASHBOARD_PORT", 8080),
-		MaxBatchSize: envInt("MAX_BATCH_SIZE", 32),
-		MaxWaitTime:  time.Duration(envInt("MAX_WAIT_MS", 50)) * time.Millisecond,
-		PollInterval: time.Duration(envInt("POLL_INTERVAL_MS", 500)) * time.Millisecond,
-		ExecutorType: envStr("EXECUTOR_TYPE", "simulation"),
-		UseNVML:      envStr("USE_NVML", "auto"),
-	}
+// WorkerConfig is one worker the router sends requests to.
+type WorkerConfig struct {
+	Address  string  `json:"address"`
+	ID       string  `json:"id,omitempty"` // expected worker ID, checked against its TLS certificate
+	Weight   float64 `json:"weight"`       // initial operator weight
+	Cordoned bool    `json:"cordoned"`     // start out of rotation
+}
 
-	// Parse worker endpoints: "host1:port1,host2:port2,..."
-	if eps := os.Getenv("WORKER_ENDPOINTS"); eps != "" {
-		c.WorkerEndpoints = strings.Split(eps, ",")
+// Scoring weighs worker metrics into a routing score; see router.Score.
+type Scoring struct {
+	MemoryWeight           float64 // points for a completely free GPU
+	QueuePenalty           float64 // per queued request
+	LatencyPenalty         float64 // per ms of average latency
+	UtilizationWeight      float64 // points lost at 100% utilization
+	ThermalLimitC          float64 // temperature above which ThermalPenalty applies
+	ThermalPenalty         float64
+	ThrottleThermalPenalty float64 // clocks held back for temperature
+	ThrottlePowerPenalty   float64 // clocks held back by the power cap
+	ECCPenalty             float64 // uncorrected ECC errors
+	StalePenalty           float64 // most a worker loses for stale metrics
+	Candidates             int     // top-scoring workers the pick is spread across
+}
+
+// DefaultScoring returns the built-in scoring weights.
+func DefaultScoring() Scoring {
+	return Scoring{
+		MemoryWeight:           100,
+		QueuePenalty:           0.1,
+		LatencyPenalty:         0.1,
+		UtilizationWeight:      50,
+		ThermalLimitC:          80,
+		ThermalPenalty:         50,
+		ThrottleThermalPenalty: 40,
+		ThrottlePowerPenalty:   20,
+		ECCPenalty:             200,
+		StalePenalty:           100,
+		Candidates:             3,
 	}
+}
 
-	return c
+// Retries controls how the router retries a failed forward.
+type Retries struct {
+	MaxAttempts    int           // forwards per request, including the first
+	ForwardTimeout time.Duration // deadline for one forward to a worker
 }
 
-func envStr(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// defaults returns the configuration used where neither the file nor the
+// environment says otherwise.
+func defaults() *Config {
+	return &Config{
+		WorkerID:              "worker-0",
+		RouterPort:            50051,
+		WorkerPort:            50052,
+		MetricsPort:           9090,
+		DashboardPort:         8080,
+		MaxBatchSize:          32,
+		MaxWaitTime:           50 * time.Millisecond,
+		BatchTimeout:          30 * time.Second,
+		PollInterval:          500 * time.Millisecond,
+		MetricsMode:           "stream",
+		MetricsHeartbeat:      2 * time.Second,
+		MetricsStaleAfter:     5 * time.Second,
+		MetricsMaxAge:         30 * time.Second,
+		HistoryRetention:      time.Hour,
+		HistoryInterval:       time.Second,
+		DashboardAuth:         "none",
+		SessionTTL:            12 * time.Hour,
+		DashboardPushInterval: 500 * time.Millisecond,
+		Scoring:               DefaultScoring(),
+		Retries:               Retries{MaxAttempts: 3, ForwardTimeout: 10 * time.Second},
+		ExecutorType:          "simulation",
+		UseNVML:               "auto",
+		GPUShare:              1.0,
+
+		TLSReload:   30 * time.Second,
+		ConfigWatch: 10 * time.Second,
+
+		RegistrationHeartbeat: 5 * time.Second,
+		DiscoveryInterval:     10 * time.Second,
+		JWTTenantClaim:        "tenant",
+
+		TraceSampleRate: 1.0,
+
+		LogLevel:            "info",
+		LogFormat:           "text",
+		LogSampleInitial:    10,
+		LogSampleThereafter: 100,
 	}
-	return fallback
 }
 
-func envInt(key string, fallback int) int {
-	if v := os.Getenv(key); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			return n
-		}
+// LogOptions returns the logging settings in the form logging.Setup takes.
+func (c *Config) LogOptions() logging.Options {
+	return logging.Options{
+		Level:            c.LogLevel,
+		Format:           c.LogFormat,
+		Components:       c.LogComponents,
+		SampleInitial:    c.LogSampleInitial,
+		SampleThereafter: c.LogSampleThereafter,
 	}
-	return fallback
 }
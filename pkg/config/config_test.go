@@ -0,0 +1,257 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolate clears CONFIG_FILE and every setting's environment variable for
+// the test, so only what the test sets applies.
+func isolate(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for _, f := range defaults().fields() {
+		if f.env != "" {
+			t.Setenv(f.env, "")
+		}
+	}
+}
+
+// writeConfig writes a config file for the test and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	isolate(t)
+	path := writeConfig(t, `{
+		"log": {"level": "debug"},
+		"router": {
+			"port": 6000,
+			"workers": ["gpu-a@10.0.0.1:50052", {"address": "10.0.0.2:50052", "weight": 2, "cordoned": true}],
+			"retries": {"max_attempts": 5, "forward_timeout_ms": 2500},
+			"dashboard": {"allowed_origins": ["https://a.example", "https://b.example"]}
+		}
+	}`)
+	t.Setenv("ROUTER_PORT", "7000")
+	t.Setenv("POLL_INTERVAL_MS", "250")
+
+	c, err := Load(Router, path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	def := defaults()
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"env over file", c.RouterPort, 7000},
+		{"env over default", c.PollInterval, 250 * time.Millisecond},
+		{"file over default", c.Retries.MaxAttempts, 5},
+		{"file duration in its unit", c.Retries.ForwardTimeout, 2500 * time.Millisecond},
+		{"file string", c.LogLevel, "debug"},
+		{"file list", c.WSAllowedOrigins, []string{"https://a.example", "https://b.example"}},
+		{"default", c.DashboardPort, def.DashboardPort},
+		{"workers", c.Workers, []WorkerConfig{
+			{ID: "gpu-a", Address: "10.0.0.1:50052", Weight: 1},
+			{Address: "10.0.0.2:50052", Weight: 2, Cordoned: true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !jsonEqual(tt.got, tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+
+	// An empty variable leaves the file's value
+	t.Setenv("ROUTER_PORT", "")
+	t.Setenv("CONFIG_FILE", path)
+	if c, err = Load(Router, ""); err != nil {
+		t.Fatalf("Load from CONFIG_FILE: %v", err)
+	}
+	if c.RouterPort != 6000 {
+		t.Errorf("router.port = %d with ROUTER_PORT empty, want the file's 6000", c.RouterPort)
+	}
+}
+
+func jsonEqual(a, b any) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		service string
+		file    string
+		env     map[string]string
+		want    []string // every one must be reported
+	}{
+		{
+			name: "unknown setting", service: Router,
+			file: `{"router": {"workers": ["10.0.0.1:1"], "prot": 6000, "retries": {"max_atempts": 2}}}`,
+			want: []string{"router.prot: unknown setting", "router.retries.max_atempts: unknown setting"},
+		},
+		{
+			name: "unknown worker field", service: Router,
+			file: `{"router": {"workers": [{"address": "10.0.0.1:1", "wieght": 2}]}}`,
+			want: []string{`router.workers[0]: unknown field "wieght"`},
+		},
+		{
+			name: "wrong types", service: Router,
+			file: `{"router": {"workers": ["10.0.0.1:1", 7], "port": "6000", "dashboard": {"allowed_origins": ["a", false]}}}`,
+			want: []string{
+				"router.workers[1]: want an object",
+				"router.port: want an integer, got a string",
+				"router.dashboard.allowed_origins[1]: want a string, got a boolean",
+			},
+		},
+		{
+			name: "malformed file", service: Router,
+			file: `{"router": `,
+			want: []string{"config.json: unexpected EOF"},
+		},
+		{
+			name: "bad env value", service: Router,
+			env:  map[string]string{"WORKER_ENDPOINTS": "10.0.0.1:1", "ROUTER_MAX_ATTEMPTS": "three"},
+			want: []string{`router.retries.max_attempts (ROUTER_MAX_ATTEMPTS): want an integer, got "three"`},
+		},
+		{
+			name: "invalid values", service: Router,
+			file: `{"router": {
+				"port": 70000,
+				"workers": ["10.0.0.1:1", {"address": "10.0.0.1:1", "weight": 11}, "nowhere"],
+				"metrics": {"stale_after_ms": 5000, "max_age_ms": 1000},
+				"scoring": {"queue_penalty": -1}
+			}}`,
+			want: []string{
+				"router.port: must be a port between 1 and 65535, got 70000",
+				"router.workers[1].address: 10.0.0.1:1 is also router.workers[0]",
+				"router.workers[1].weight: must be between 0 and 10",
+				`router.workers[2].address: want host:port, got "nowhere"`,
+				"router.metrics.max_age_ms: must be at least router.metrics.stale_after_ms",
+				"router.scoring.queue_penalty: must not be negative",
+			},
+		},
+		{
+			name: "no workers", service: Router,
+			want: []string{"router.workers: no workers configured"},
+		},
+		{
+			name: "settings that go together", service: Router,
+			env: map[string]string{
+				"WORKER_ENDPOINTS": "10.0.0.1:1",
+				"TLS_CERT_FILE":    "cert.pem",
+				"JWT_HS256_SECRET": "s3cret",
+				"DASHBOARD_AUTH":   "basic",
+			},
+			want: []string{
+				"tls.key_file: must be set along with tls.cert_file",
+				"router.auth.tenants_file: needed to map JWTs to tenants",
+				"router.dashboard.users: basic auth needs users",
+			},
+		},
+		{
+			name: "worker", service: Worker,
+			env: map[string]string{"GPU_MEMORY_SHARE": "1.5", "ROUTER_ADDRESS": "router:50051"},
+			want: []string{
+				"worker.gpu_memory_share: must be more than 0 and at most 1",
+				`worker.registration.advertise_address: want host:port, got ""`,
+				"registration.token: needed to register with the router",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeConfig(t, tt.file)
+			}
+			_, err := Load(tt.service, path)
+			if err == nil {
+				t.Fatal("Load succeeded")
+			}
+			lines := strings.Split(err.Error(), "\n")
+			for _, want := range tt.want {
+				if !slices.ContainsFunc(lines, func(l string) bool { return strings.Contains(l, want) }) {
+					t.Errorf("missing %q in:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	isolate(t)
+	secrets := map[string]string{
+		"REGISTRATION_TOKEN": "reg-s3cret",
+		"ADMIN_TOKEN":        "admin-s3cret",
+		"DASHBOARD_TOKENS":   "viewer:dash-s3cret",
+		"SESSION_SECRET":     "session-s3cret",
+		"JWT_HS256_SECRET":   "jwt-s3cret",
+	}
+	for k, v := range secrets {
+		t.Setenv(k, v)
+	}
+	t.Setenv("DASHBOARD_AUTH", "token")
+	t.Setenv("AUTH_TENANTS_FILE", "tenants.json")
+	c, err := Load(Router, "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := c.Print(&out, Router); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	for k, v := range secrets {
+		if strings.Contains(out.String(), strings.TrimPrefix(v, "viewer:")) {
+			t.Errorf("%s printed in the clear", k)
+		}
+	}
+
+	var tree struct {
+		Registration struct{ Token string } `json:"registration"`
+		Router       struct {
+			AdminToken string `json:"admin_token"`
+			Dashboard  struct {
+				Auth  string
+				Users string
+			}
+		}
+		Worker any
+	}
+	if err := json.Unmarshal(out.Bytes(), &tree); err != nil {
+		t.Fatalf("output isn't JSON: %v\n%s", err, out.String())
+	}
+	if tree.Registration.Token != "<redacted>" || tree.Router.AdminToken != "<redacted>" {
+		t.Errorf("secrets printed as %q and %q, want <redacted>", tree.Registration.Token, tree.Router.AdminToken)
+	}
+	if tree.Router.Dashboard.Users != "" {
+		t.Errorf("unset secret printed as %q, want empty", tree.Router.Dashboard.Users)
+	}
+	if tree.Router.Dashboard.Auth != "token" {
+		t.Errorf("router.dashboard.auth = %q, want it printed as is", tree.Router.Dashboard.Auth)
+	}
+	if tree.Worker != nil {
+		t.Error("the router's configuration includes worker settings")
+	}
+}
@@ -0,0 +1,454 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Services, for Load and Print.
+const (
+	Router = "router"
+	Worker = "worker"
+)
+
+// Load builds the configuration for service from defaults, then the JSON
+// file at path (or $CONFIG_FILE; none if both are empty), then environment
+// variables, and validates the result. The error lists every problem
+// found, each prefixed with the setting's path in the file.
+func Load(service, path string) (*Config, error) {
+	c := defaults()
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	var errs []error
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, c.loadEnv(service)...)
+	errs = append(errs, c.Validate(service))
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// field binds one setting to its path in the config file and, for most,
+// an environment variable.
+type field struct {
+	path    string // dotted path in the file
+	env     string // "" for file-only settings
+	service string // Router, Worker, or "" for both
+	secret  bool   // redacted by Print
+	live    bool   // may change in a running process; see Reloader
+	value   value
+}
+
+// value is a settable config field.
+type value interface {
+	set(raw any) error    // from decoded JSON (json.Number for numbers)
+	parse(s string) error // from an environment variable
+	get() any             // for Print
+	copyFrom(o value)     // from the same setting of another Config
+}
+
+// fields lists every setting. Durations are whole numbers in the unit
+// their name ends in (_ms, _s), in the file as in the environment. Live
+// settings are the ones the services know how to apply without a restart.
+func (c *Config) fields() []field {
+	ms, s := time.Millisecond, time.Second
+	return []field{
+		{path: "log.level", env: "LOG_LEVEL", value: str{&c.LogLevel}},
+		{path: "log.format", env: "LOG_FORMAT", value: str{&c.LogFormat}},
+		{path: "log.components", env: "LOG_COMPONENTS", value: str{&c.LogComponents}},
+		{path: "log.sample_initial", env: "LOG_SAMPLE_INITIAL", value: integer{&c.LogSampleInitial}},
+		{path: "log.sample_thereafter", env: "LOG_SAMPLE_THEREAFTER", value: integer{&c.LogSampleThereafter}},
+		{path: "trace.export", env: "TRACE_EXPORT", value: str{&c.TraceExport}},
+		{path: "trace.file", env: "TRACE_FILE", value: str{&c.TraceFile}},
+		{path: "trace.sample_rate", env: "TRACE_SAMPLE_RATE", value: float{&c.TraceSampleRate}},
+		{path: "tls.cert_file", env: "TLS_CERT_FILE", value: str{&c.TLSCertFile}},
+		{path: "tls.key_file", env: "TLS_KEY_FILE", value: str{&c.TLSKeyFile}},
+		{path: "tls.client_ca_file", env: "TLS_CLIENT_CA_FILE", value: str{&c.TLSClientCAFile}},
+		{path: "tls.reload_s", env: "TLS_RELOAD_S", value: duration{&c.TLSReload, s}},
+		{path: "config.watch_s", env: "CONFIG_WATCH_S", value: duration{&c.ConfigWatch, s}},
+		{path: "registration.token", env: "REGISTRATION_TOKEN", secret: true, value: str{&c.RegistrationToken}},
+
+		{path: "router.port", env: "ROUTER_PORT", service: Router, value: integer{&c.RouterPort}},
+		{path: "router.workers", env: "WORKER_ENDPOINTS", service: Router, live: true, value: workers{&c.Workers}},
+		{path: "router.worker_tls.ca_file", env: "WORKER_TLS_CA_FILE", service: Router, value: str{&c.WorkerTLSCAFile}},
+		{path: "router.worker_tls.cert_file", env: "WORKER_TLS_CERT_FILE", service: Router, value: str{&c.WorkerTLSCertFile}},
+		{path: "router.worker_tls.key_file", env: "WORKER_TLS_KEY_FILE", service: Router, value: str{&c.WorkerTLSKeyFile}},
+		{path: "router.metrics.mode", env: "METRICS_MODE", service: Router, value: str{&c.MetricsMode}},
+		{path: "router.metrics.poll_interval_ms", env: "POLL_INTERVAL_MS", service: Router, live: true, value: duration{&c.PollInterval, ms}},
+		{path: "router.metrics.heartbeat_ms", env: "METRICS_HEARTBEAT_MS", service: Router, value: duration{&c.MetricsHeartbeat, ms}},
+		{path: "router.metrics.stale_after_ms", env: "METRICS_STALE_AFTER_MS", service: Router, live: true, value: duration{&c.MetricsStaleAfter, ms}},
+		{path: "router.metrics.max_age_ms", env: "METRICS_MAX_AGE_MS", service: Router, live: true, value: duration{&c.MetricsMaxAge, ms}},
+		{path: "router.scoring.memory_weight", service: Router, live: true, value: float{&c.Scoring.MemoryWeight}},
+		{path: "router.scoring.queue_penalty", service: Router, live: true, value: float{&c.Scoring.QueuePenalty}},
+		{path: "router.scoring.latency_penalty", service: Router, live: true, value: float{&c.Scoring.LatencyPenalty}},
+		{path: "router.scoring.utilization_weight", service: Router, live: true, value: float{&c.Scoring.UtilizationWeight}},
+		{path: "router.scoring.thermal_limit_c", service: Router, live: true, value: float{&c.Scoring.ThermalLimitC}},
+		{path: "router.scoring.thermal_penalty", service: Router, live: true, value: float{&c.Scoring.ThermalPenalty}},
+		{path: "router.scoring.throttle_thermal_penalty", service: Router, live: true, value: float{&c.Scoring.ThrottleThermalPenalty}},
+		{path: "router.scoring.throttle_power_penalty", service: Router, live: true, value: float{&c.Scoring.ThrottlePowerPenalty}},
+		{path: "router.scoring.ecc_penalty", service: Router, live: true, value: float{&c.Scoring.ECCPenalty}},
+		{path: "router.scoring.stale_penalty", service: Router, live: true, value: float{&c.Scoring.StalePenalty}},
+		{path: "router.scoring.candidates", service: Router, live: true, value: integer{&c.Scoring.Candidates}},
+		{path: "router.retries.max_attempts", env: "ROUTER_MAX_ATTEMPTS", service: Router, live: true, value: integer{&c.Retries.MaxAttempts}},
+		{path: "router.retries.forward_timeout_ms", env: "FORWARD_TIMEOUT_MS", service: Router, live: true, value: duration{&c.Retries.ForwardTimeout, ms}},
+		{path: "router.discovery.mode", env: "DISCOVERY_MODE", service: Router, value: str{&c.DiscoveryMode}},
+		{path: "router.discovery.name", env: "DISCOVERY_NAME", service: Router, value: str{&c.DiscoveryName}},
+		{path: "router.discovery.interval_s", env: "DISCOVERY_INTERVAL_S", service: Router, value: duration{&c.DiscoveryInterval, s}},
+		{path: "router.registration.heartbeat_ms", env: "REGISTRATION_HEARTBEAT_MS", service: Router, value: duration{&c.RegistrationHeartbeat, ms}},
+		{path: "router.history.retention_s", env: "HISTORY_RETENTION_S", service: Router, value: duration{&c.HistoryRetention, s}},
+		{path: "router.history.interval_ms", env: "HISTORY_INTERVAL_MS", service: Router, value: duration{&c.HistoryInterval, ms}},
+		{path: "router.admin_token", env: "ADMIN_TOKEN", service: Router, secret: true, value: str{&c.AdminToken}},
+		{path: "router.dashboard.port", env: "DASHBOARD_PORT", service: Router, value: integer{&c.DashboardPort}},
+		{path: "router.dashboard.auth", env: "DASHBOARD_AUTH", service: Router, value: str{&c.DashboardAuth}},
+		{path: "router.dashboard.tokens", env: "DASHBOARD_TOKENS", service: Router, secret: true, value: str{&c.DashboardTokens}},
+		{path: "router.dashboard.users", env: "DASHBOARD_USERS", service: Router, secret: true, value: str{&c.DashboardUsers}},
+		{path: "router.dashboard.session_secret", env: "SESSION_SECRET", service: Router, secret: true, value: str{&c.SessionSecret}},
+		{path: "router.dashboard.session_ttl_s", env: "SESSION_TTL_S", service: Router, value: duration{&c.SessionTTL, s}},
+		{path: "router.dashboard.allowed_origins", env: "WS_ALLOWED_ORIGINS", service: Router, value: list{&c.WSAllowedOrigins}},
+		{path: "router.dashboard.push_interval_ms", env: "DASHBOARD_PUSH_MS", service: Router, value: duration{&c.DashboardPushInterval, ms}},
+		{path: "router.auth.tenants_file", env: "AUTH_TENANTS_FILE", service: Router, value: str{&c.AuthTenantsFile}},
+		{path: "router.auth.jwt_hs256_secret", env: "JWT_HS256_SECRET", service: Router, secret: true, value: str{&c.JWTSecret}},
+		{path: "router.auth.jwt_rs256_public_key_file", env: "JWT_RS256_PUBLIC_KEY_FILE", service: Router, value: str{&c.JWTPublicKeyFile}},
+		{path: "router.auth.jwt_issuer", env: "JWT_ISSUER", service: Router, value: str{&c.JWTIssuer}},
+		{path: "router.auth.jwt_audience", env: "JWT_AUDIENCE", service: Router, value: str{&c.JWTAudience}},
+		{path: "router.auth.jwt_tenant_claim", env: "JWT_TENANT_CLAIM", service: Router, value: str{&c.JWTTenantClaim}},
+
+		{path: "worker.id", env: "WORKER_ID", service: Worker, value: str{&c.WorkerID}},
+		{path: "worker.port", env: "WORKER_PORT", service: Worker, value: integer{&c.WorkerPort}},
+		{path: "worker.metrics_port", env: "METRICS_PORT", service: Worker, value: integer{&c.MetricsPort}},
+		{path: "worker.executor", env: "EXECUTOR_TYPE", service: Worker, value: str{&c.ExecutorType}},
+		{path: "worker.use_nvml", env: "USE_NVML", service: Worker, value: str{&c.UseNVML}},
+		{path: "worker.gpu_devices", env: "GPU_DEVICES", service: Worker, value: str{&c.GPUDevices}},
+		{path: "worker.gpu_memory_share", env: "GPU_MEMORY_SHARE", service: Worker, value: float{&c.GPUShare}},
+		{path: "worker.models", env: "WORKER_MODELS", service: Worker, value: list{&c.Models}},
+		{path: "worker.registration.router_address", env: "ROUTER_ADDRESS", service: Worker, value: str{&c.RouterAddress}},
+		{path: "worker.registration.advertise_address", env: "ADVERTISE_ADDRESS", service: Worker, value: str{&c.AdvertiseAddress}},
+		{path: "worker.registration.router_tls_ca_file", env: "ROUTER_TLS_CA_FILE", service: Worker, value: str{&c.RouterTLSCAFile}},
+		{path: "worker.batching.max_batch_size", env: "MAX_BATCH_SIZE", service: Worker, live: true, value: integer{&c.MaxBatchSize}},
+		{path: "worker.batching.max_wait_ms", env: "MAX_WAIT_MS", service: Worker, live: true, value: duration{&c.MaxWaitTime, ms}},
+		{path: "worker.batching.timeout_ms", env: "BATCH_TIMEOUT_MS", service: Worker, live: true, value: duration{&c.BatchTimeout, ms}},
+	}
+}
+
+// loadFile applies the settings in a JSON config file. Unknown settings
+// are errors, so a typo can't silently leave a default in place.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree map[string]any
+	if err := dec.Decode(&tree); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	byPath := make(map[string]value)
+	for _, f := range c.fields() {
+		byPath[f.path] = f.value
+	}
+	var errs []error
+	var walk func(prefix string, node map[string]any)
+	walk = func(prefix string, node map[string]any) {
+		keys := make([]string, 0, len(node))
+		for k := range node {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := prefix + k
+			if v, ok := byPath[p]; ok {
+				errs = append(errs, atPath(p, v.set(node[k]))...)
+				continue
+			}
+			if sub, ok := node[k].(map[string]any); ok {
+				walk(p+".", sub)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s: unknown setting", p))
+		}
+	}
+	walk("", tree)
+	return errors.Join(errs...)
+}
+
+// elemError is a problem with one element of a list setting.
+type elemError struct {
+	index int
+	err   error
+}
+
+func (e *elemError) Error() string { return fmt.Sprintf("[%d]: %v", e.index, e.err) }
+
+// atPath prefixes err, or each error joined in it, with path.
+func atPath(path string, err error) []error {
+	if err == nil {
+		return nil
+	}
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, atPath(path, e)...)
+		}
+		return errs
+	}
+	var ee *elemError
+	if errors.As(err, &ee) {
+		return []error{fmt.Errorf("%s[%d]: %w", path, ee.index, ee.err)}
+	}
+	return []error{fmt.Errorf("%s: %w", path, err)}
+}
+
+// loadEnv applies the environment variables service reads over whatever
+// is already set.
+func (c *Config) loadEnv(service string) []error {
+	var errs []error
+	for _, f := range c.fields() {
+		if f.env == "" || (f.service != "" && f.service != service) {
+			continue
+		}
+		if v := os.Getenv(f.env); v != "" {
+			if err := f.value.parse(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s (%s): %w", f.path, f.env, err))
+			}
+		}
+	}
+	return errs
+}
+
+// Print writes the configuration service runs with as a JSON config file,
+// with secrets redacted.
+func (c *Config) Print(w io.Writer, service string) error {
+	tree := make(map[string]any)
+	for _, f := range c.fields() {
+		if f.service != "" && f.service != service {
+			continue
+		}
+		v := f.value.get()
+		if f.secret && v != "" {
+			v = "<redacted>"
+		}
+		node := tree
+		parts := strings.Split(f.path, ".")
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := node[part].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				node[part] = next
+			}
+			node = next
+		}
+		node[parts[len(parts)-1]] = v
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(tree)
+}
+
+// --- values --------------------------------------------------------------------------
+
+type str struct{ p *string }
+
+func (v str) set(raw any) error {
+	s, ok := raw.(string)
+	if !ok {
+		return fmt.Errorf("want a string, got %s", jsonType(raw))
+	}
+	*v.p = s
+	return nil
+}
+func (v str) parse(s string) error { *v.p = s; return nil }
+func (v str) get() any             { return *v.p }
+func (v str) copyFrom(o value)     { *v.p = *o.(str).p }
+
+type integer struct{ p *int }
+
+func (v integer) set(raw any) error {
+	n, ok := raw.(json.Number)
+	if !ok {
+		return fmt.Errorf("want an integer, got %s", jsonType(raw))
+	}
+	return v.parse(n.String())
+}
+func (v integer) parse(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("want an integer, got %q", s)
+	}
+	*v.p = n
+	return nil
+}
+func (v integer) get() any         { return *v.p }
+func (v integer) copyFrom(o value) { *v.p = *o.(integer).p }
+
+type float struct{ p *float64 }
+
+func (v float) set(raw any) error {
+	n, ok := raw.(json.Number)
+	if !ok {
+		return fmt.Errorf("want a number, got %s", jsonType(raw))
+	}
+	return v.parse(n.String())
+}
+func (v float) parse(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("want a number, got %q", s)
+	}
+	*v.p = f
+	return nil
+}
+func (v float) get() any         { return *v.p }
+func (v float) copyFrom(o value) { *v.p = *o.(float).p }
+
+// duration is a whole number of unit.
+type duration struct {
+	p    *time.Duration
+	unit time.Duration
+}
+
+func (v duration) set(raw any) error {
+	n, ok := raw.(json.Number)
+	if !ok {
+		return fmt.Errorf("want an integer, got %s", jsonType(raw))
+	}
+	return v.parse(n.String())
+}
+func (v duration) parse(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("want an integer, got %q", s)
+	}
+	*v.p = time.Duration(n) * v.unit
+	return nil
+}
+func (v duration) get() any         { return int64(*v.p / v.unit) }
+func (v duration) copyFrom(o value) { *v.p = *o.(duration).p }
+
+// list is a JSON array of strings, or a comma-separated string.
+type list struct{ p *[]string }
+
+func (v list) set(raw any) error {
+	switch x := raw.(type) {
+	case string:
+		return v.parse(x)
+	case []any:
+		out := make([]string, 0, len(x))
+		var errs []error
+		for i, e := range x {
+			s, ok := e.(string)
+			if !ok {
+				errs = append(errs, &elemError{i, fmt.Errorf("want a string, got %s", jsonType(e))})
+				continue
+			}
+			out = append(out, s)
+		}
+		*v.p = out
+		return errors.Join(errs...)
+	}
+	return fmt.Errorf("want a list of strings, got %s", jsonType(raw))
+}
+func (v list) parse(s string) error { *v.p = SplitList(s); return nil }
+func (v list) get() any             { return append([]string{}, *v.p...) }
+func (v list) copyFrom(o value)     { *v.p = *o.(list).p }
+
+// workers is the router's worker list: in the file, an array of
+// WorkerConfig objects or "id@host:port" strings; in WORKER_ENDPOINTS, a
+// comma-separated list of the latter.
+type workers struct{ p *[]WorkerConfig }
+
+func (v workers) set(raw any) error {
+	arr, ok := raw.([]any)
+	if !ok {
+		return fmt.Errorf("want a list of workers, got %s", jsonType(raw))
+	}
+	out := make([]WorkerConfig, 0, len(arr))
+	var errs []error
+	for i, e := range arr {
+		switch x := e.(type) {
+		case string:
+			out = append(out, ParseEndpoint(x))
+		case map[string]any:
+			// Round-trip through JSON for strict field checking
+			data, _ := json.Marshal(x)
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.DisallowUnknownFields()
+			w := WorkerConfig{Weight: 1}
+			if err := dec.Decode(&w); err != nil {
+				errs = append(errs, &elemError{i, errors.New(strings.TrimPrefix(err.Error(), "json: "))})
+				continue
+			}
+			out = append(out, w)
+		default:
+			errs = append(errs, &elemError{i, fmt.Errorf("want an object or \"id@host:port\", got %s", jsonType(e))})
+		}
+	}
+	*v.p = out
+	return errors.Join(errs...)
+}
+func (v workers) parse(s string) error {
+	var out []WorkerConfig
+	for _, ep := range SplitList(s) {
+		out = append(out, ParseEndpoint(ep))
+	}
+	*v.p = out
+	return nil
+}
+func (v workers) get() any         { return append([]WorkerConfig{}, *v.p...) }
+func (v workers) copyFrom(o value) { *v.p = *o.(workers).p }
+
+// ParseEndpoint reads a worker endpoint, "host:port" or "id@host:port".
+func ParseEndpoint(ep string) WorkerConfig {
+	ep = strings.TrimSpace(ep)
+	w := WorkerConfig{Address: ep, Weight: 1}
+	if id, addr, ok := strings.Cut(ep, "@"); ok {
+		w.ID, w.Address = id, addr
+	}
+	return w
+}
+
+// SplitList splits a comma-separated setting, trimming spaces and
+// dropping empty entries.
+func SplitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", v)
+}
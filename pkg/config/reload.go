@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+)
+
+// Diff returns the paths of service's settings that differ between c and
+// next, split into those a running process can apply and those that need
+// a restart.
+func (c *Config) Diff(next *Config, service string) (live, restart []string) {
+	a, b := c.fields(), next.fields()
+	for i, f := range a {
+		if f.service != "" && f.service != service {
+			continue
+		}
+		if reflect.DeepEqual(f.value.get(), b[i].value.get()) {
+			continue
+		}
+		if f.live {
+			live = append(live, f.path)
+		} else {
+			restart = append(restart, f.path)
+		}
+	}
+	return live, restart
+}
+
+// withRunning returns a copy of next with every setting that needs a
+// restart put back to its value in c.
+func (c *Config) withRunning(next *Config) *Config {
+	merged := *next
+	from, to := c.fields(), merged.fields()
+	for i, f := range to {
+		if !f.live {
+			f.value.copyFrom(from[i].value)
+		}
+	}
+	return &merged
+}
+
+// Reloader loads a service's configuration again on request (SIGHUP) or
+// when its file changes, and passes it to apply. An invalid configuration
+// leaves the running one untouched. Changes to settings that need a
+// restart are refused and those settings keep their running values, but
+// the live settings changed alongside them are still applied.
+type Reloader struct {
+	service string
+	path    string // "" without a config file
+	apply   func(*Config) error
+	log     *slog.Logger
+
+	mu    sync.Mutex
+	cur   *Config
+	stamp fileStamp
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// NewReloader starts from cur, the configuration the service is running
+// with, loaded from path (or $CONFIG_FILE). With a file and a positive
+// cur.ConfigWatch, the file is checked for changes at that interval until
+// Close.
+func NewReloader(service, path string, cur *Config, apply func(*Config) error) *Reloader {
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	r := &Reloader{
+		service: service,
+		path:    path,
+		apply:   apply,
+		log:     logging.For("config"),
+		cur:     cur,
+		stamp:   stat(path),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if path != "" && cur.ConfigWatch > 0 {
+		go r.watch(cur.ConfigWatch)
+	} else {
+		close(r.done)
+	}
+	return r
+}
+
+// Close stops watching the file.
+func (r *Reloader) Close() {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	<-r.done
+}
+
+// Reload loads the configuration again and applies what changed live.
+// The outcome is logged; the error says what was not applied and why.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stamp = stat(r.path)
+
+	refused, err := r.reload()
+	switch {
+	case err != nil:
+		r.log.Warn("configuration reload refused; keeping the running configuration", "file", r.path, "err", err)
+	case len(refused) > 0:
+		err = fmt.Errorf("these settings can only change on restart: %s", strings.Join(refused, ", "))
+		r.log.Warn("configuration changes need a restart; keeping their running values", "file", r.path, "err", err)
+	}
+	return err
+}
+
+// reload applies the live changes and returns the restart-only settings
+// it left alone. An error means nothing was applied.
+func (r *Reloader) reload() (refused []string, err error) {
+	next, err := Load(r.service, r.path)
+	if err != nil {
+		return nil, err
+	}
+	live, restart := r.cur.Diff(next, r.service)
+	if len(restart) > 0 {
+		next = r.cur.withRunning(next)
+		// The live settings may have been checked against the refused ones
+		if err := next.Validate(r.service); err != nil {
+			return nil, err
+		}
+	}
+	if len(live) == 0 {
+		r.log.Info("configuration reloaded, nothing changed live", "file", r.path)
+		return restart, nil
+	}
+	if err := r.apply(next); err != nil {
+		return nil, err
+	}
+	r.cur = next
+	r.log.Info("configuration reloaded", "file", r.path, "changed", live)
+	return restart, nil
+}
+
+func (r *Reloader) watch(interval time.Duration) {
+	defer close(r.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-t.C:
+			if r.changed() {
+				r.Reload()
+			}
+		}
+	}
+}
+
+// changed reports whether the file's size or modification time differs
+// from when it was last loaded. A missing file (mid-rewrite) is not a change.
+func (r *Reloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := stat(r.path)
+	return !st.mod.IsZero() && (!st.mod.Equal(r.stamp.mod) || st.size != r.stamp.size)
+}
+
+// stat follows symlinks, so a Kubernetes ConfigMap update, which swaps the
+// link, shows up as a new modification time.
+func stat(path string) fileStamp {
+	if path == "" {
+		return fileStamp{}
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{mod: st.ModTime(), size: st.Size()}
+}
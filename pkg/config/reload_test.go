@@ -0,0 +1,146 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	cur, next := defaults(), defaults()
+	next.PollInterval = time.Second
+	next.Scoring.Candidates = 5
+	next.RouterPort = 6000
+	next.MaxBatchSize = 64
+	next.LogLevel = "debug"
+
+	tests := []struct {
+		service       string
+		live, restart []string
+	}{
+		{Router, []string{"router.metrics.poll_interval_ms", "router.scoring.candidates"}, []string{"log.level", "router.port"}},
+		{Worker, []string{"worker.batching.max_batch_size"}, []string{"log.level"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			live, restart := cur.Diff(next, tt.service)
+			if !slices.Equal(live, tt.live) || !slices.Equal(restart, tt.restart) {
+				t.Errorf("live %v, restart %v; want %v, %v", live, restart, tt.live, tt.restart)
+			}
+		})
+	}
+	if live, restart := cur.Diff(defaults(), Router); live != nil || restart != nil {
+		t.Errorf("no change: live %v, restart %v", live, restart)
+	}
+}
+
+// routerFile is a router config file with the given poll interval and
+// metrics mode (which needs a restart), checked on SIGHUP only.
+func routerFile(pollMs, mode string) string {
+	return `{"config": {"watch_s": 0}, "router": {
+		"workers": ["10.0.0.1:50052"],
+		"metrics": {"poll_interval_ms": ` + pollMs + `, "mode": "` + mode + `"}
+	}}`
+}
+
+func TestReloaderRefusesRestartChangesAndAppliesLive(t *testing.T) {
+	isolate(t)
+	path := writeConfig(t, routerFile("500", "stream"))
+	cur, err := Load(Router, path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var applied []*Config
+	applyErr := error(nil)
+	r := NewReloader(Router, path, cur, func(c *Config) error {
+		if applyErr != nil {
+			return applyErr
+		}
+		applied = append(applied, c)
+		return nil
+	})
+	defer r.Close()
+	rewrite := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A live and a restart-only change together: only the live one applies
+	rewrite(routerFile("250", "poll"))
+	err = r.Reload()
+	if err == nil || !strings.Contains(err.Error(), "router.metrics.mode") {
+		t.Fatalf("Reload: got %v, want the mode change refused", err)
+	}
+	if strings.Contains(err.Error(), "poll_interval") {
+		t.Errorf("the live change is named as refused: %v", err)
+	}
+	if len(applied) != 1 {
+		t.Fatalf("applied %d times, want 1", len(applied))
+	}
+	if got := applied[0]; got.PollInterval != 250*time.Millisecond || got.MetricsMode != "stream" {
+		t.Errorf("applied poll interval %v, mode %q; want 250ms and the running mode", got.PollInterval, got.MetricsMode)
+	}
+
+	// Nothing live left to change; the refusal is still reported
+	if err := r.Reload(); err == nil {
+		t.Error("reloading the same file again: the mode change wasn't refused")
+	}
+	if len(applied) != 1 {
+		t.Errorf("applied %d times, want still 1", len(applied))
+	}
+
+	// Back to the running mode, and another live change
+	rewrite(routerFile("100", "stream"))
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(applied) != 2 || applied[1].PollInterval != 100*time.Millisecond {
+		t.Fatalf("live change not applied: %d applied", len(applied))
+	}
+
+	// Refusals that apply nothing
+	tests := []struct {
+		name    string
+		content string
+		apply   error
+		want    string
+	}{
+		{name: "invalid file", content: routerFile("-1", "stream"), want: "router.metrics.poll_interval_ms: must be positive"},
+		{
+			// Valid only with the discovery mode it can't switch on live
+			name:    "invalid with the running values",
+			content: `{"config": {"watch_s": 0}, "router": {"workers": [], "discovery": {"mode": "dns", "name": "workers:50052"}}}`,
+			want:    "router.workers: no workers configured",
+		},
+		{name: "apply fails", content: routerFile("300", "stream"), apply: errors.New("worker still draining"), want: "worker still draining"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewrite(tt.content)
+			applyErr = tt.apply
+			defer func() { applyErr = nil }()
+			before := len(applied)
+			if err := r.Reload(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Reload: got %v, want an error containing %q", err, tt.want)
+			}
+			if len(applied) != before {
+				t.Error("a refused reload was applied")
+			}
+		})
+	}
+
+	// The running configuration is still the last one applied, so the
+	// same change applies once apply succeeds
+	rewrite(routerFile("300", "stream"))
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := applied[len(applied)-1].PollInterval; got != 300*time.Millisecond {
+		t.Errorf("poll interval = %v, want 300ms", got)
+	}
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Validate checks the settings service uses and returns every problem,
+// each prefixed with the setting's path in the config file.
+func (c *Config) Validate(service string) error {
+	v := &validator{}
+
+	v.oneOf("log.level", c.LogLevel, "debug", "info", "warn", "error")
+	v.oneOf("log.format", c.LogFormat, "text", "json")
+	v.check(c.LogSampleInitial >= 0, "log.sample_initial", "must not be negative")
+	v.check(c.LogSampleThereafter >= 0, "log.sample_thereafter", "must not be negative")
+	v.oneOf("trace.export", c.TraceExport, "", "json", "otlp")
+	v.check(c.TraceSampleRate >= 0 && c.TraceSampleRate <= 1, "trace.sample_rate", "must be between 0 and 1")
+	v.pair("tls.cert_file", c.TLSCertFile, "tls.key_file", c.TLSKeyFile)
+	v.check(c.TLSClientCAFile == "" || c.TLSCertFile != "", "tls.client_ca_file", "needs tls.cert_file: client certificates are only checked over TLS")
+	v.check(c.TLSReload >= 0, "tls.reload_s", "must not be negative")
+	v.check(c.ConfigWatch >= 0, "config.watch_s", "must not be negative")
+
+	switch service {
+	case Router:
+		c.validateRouter(v)
+	case Worker:
+		c.validateWorker(v)
+	}
+	return errors.Join(v.errs...)
+}
+
+func (c *Config) validateRouter(v *validator) {
+	v.port("router.port", c.RouterPort)
+	v.port("router.dashboard.port", c.DashboardPort)
+	v.check(c.RouterPort != c.DashboardPort, "router.dashboard.port", "must differ from router.port")
+
+	v.check(len(c.Workers) > 0 || c.RegistrationToken != "" || c.DiscoveryMode != "", "router.workers",
+		"no workers configured (set WORKER_ENDPOINTS or router.workers, DISCOVERY_MODE, or REGISTRATION_TOKEN to let workers register)")
+	seen := make(map[string]int)
+	for i, w := range c.Workers {
+		p := fmt.Sprintf("router.workers[%d]", i)
+		v.hostPort(p+".address", w.Address)
+		if j, dup := seen[w.Address]; dup {
+			v.add(p+".address", "%s is also router.workers[%d]", w.Address, j)
+		}
+		seen[w.Address] = i
+		v.check(w.Weight >= 0 && w.Weight <= 10, p+".weight", "must be between 0 and 10")
+	}
+	v.pair("router.worker_tls.cert_file", c.WorkerTLSCertFile, "router.worker_tls.key_file", c.WorkerTLSKeyFile)
+
+	v.oneOf("router.metrics.mode", c.MetricsMode, "stream", "poll")
+	v.positive("router.metrics.poll_interval_ms", c.PollInterval)
+	v.positive("router.metrics.heartbeat_ms", c.MetricsHeartbeat)
+	v.check(c.MetricsStaleAfter >= 0, "router.metrics.stale_after_ms", "must not be negative")
+	v.check(c.MetricsMaxAge >= c.MetricsStaleAfter, "router.metrics.max_age_ms", "must be at least router.metrics.stale_after_ms")
+
+	s := c.Scoring
+	for _, w := range []struct {
+		path  string
+		value float64
+	}{
+		{"memory_weight", s.MemoryWeight},
+		{"queue_penalty", s.QueuePenalty},
+		{"latency_penalty", s.LatencyPenalty},
+		{"utilization_weight", s.UtilizationWeight},
+		{"thermal_penalty", s.ThermalPenalty},
+		{"throttle_thermal_penalty", s.ThrottleThermalPenalty},
+		{"throttle_power_penalty", s.ThrottlePowerPenalty},
+		{"ecc_penalty", s.ECCPenalty},
+		{"stale_penalty", s.StalePenalty},
+	} {
+		v.check(w.value >= 0, "router.scoring."+w.path, "must not be negative")
+	}
+	v.check(s.Candidates >= 1, "router.scoring.candidates", "must be at least 1")
+
+	v.positive("router.registration.heartbeat_ms", c.RegistrationHeartbeat)
+
+	v.oneOf("router.discovery.mode", c.DiscoveryMode, "", "dns", "srv")
+	switch c.DiscoveryMode {
+	case "dns":
+		v.hostPort("router.discovery.name", c.DiscoveryName)
+	case "srv":
+		v.check(c.DiscoveryName != "", "router.discovery.name", "needed for srv discovery")
+	}
+	v.positive("router.discovery.interval_s", c.DiscoveryInterval)
+
+	v.check(c.Retries.MaxAttempts >= 1, "router.retries.max_attempts", "must be at least 1")
+	v.positive("router.retries.forward_timeout_ms", c.Retries.ForwardTimeout)
+
+	v.positive("router.history.interval_ms", c.HistoryInterval)
+	v.check(c.HistoryRetention >= c.HistoryInterval, "router.history.retention_s", "must be at least router.history.interval_ms")
+
+	v.oneOf("router.dashboard.auth", c.DashboardAuth, "none", "token", "basic")
+	v.check(c.DashboardAuth != "token" || c.DashboardTokens != "" || c.AdminToken != "",
+		"router.dashboard.tokens", "token auth needs tokens or router.admin_token")
+	v.check(c.DashboardAuth != "basic" || c.DashboardUsers != "", "router.dashboard.users", "basic auth needs users")
+	v.positive("router.dashboard.session_ttl_s", c.SessionTTL)
+	v.check(c.DashboardPushInterval >= 100*time.Millisecond && c.DashboardPushInterval <= 10*time.Second,
+		"router.dashboard.push_interval_ms", "must be between 100 and 10000")
+
+	jwt := c.JWTSecret != "" || c.JWTPublicKeyFile != ""
+	v.check(!jwt || c.AuthTenantsFile != "", "router.auth.tenants_file", "needed to map JWTs to tenants")
+	v.check(!jwt || c.JWTTenantClaim != "", "router.auth.jwt_tenant_claim", "must not be empty")
+}
+
+func (c *Config) validateWorker(v *validator) {
+	v.check(c.WorkerID != "", "worker.id", "must not be empty")
+	v.port("worker.port", c.WorkerPort)
+	v.port("worker.metrics_port", c.MetricsPort)
+	v.check(c.WorkerPort != c.MetricsPort, "worker.metrics_port", "must differ from worker.port")
+	v.oneOf("worker.executor", c.ExecutorType, "simulation", "onnx")
+	v.oneOf("worker.use_nvml", c.UseNVML, "auto", "true", "false")
+	v.check(c.GPUShare > 0 && c.GPUShare <= 1, "worker.gpu_memory_share", "must be more than 0 and at most 1")
+	v.check(c.MaxBatchSize >= 1, "worker.batching.max_batch_size", "must be at least 1")
+	v.check(c.MaxWaitTime >= 0, "worker.batching.max_wait_ms", "must not be negative")
+	v.positive("worker.batching.timeout_ms", c.BatchTimeout)
+
+	if c.RouterAddress != "" {
+		v.hostPort("worker.registration.router_address", c.RouterAddress)
+		v.hostPort("worker.registration.advertise_address", c.AdvertiseAddress)
+		v.check(c.RegistrationToken != "", "registration.token", "needed to register with the router")
+	}
+}
+
+// validator collects errors rather than stopping at the first.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(path, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) check(ok bool, path, msg string) {
+	if !ok {
+		v.add(path, "%s", msg)
+	}
+}
+
+func (v *validator) port(path string, p int) {
+	v.check(p > 0 && p <= 65535, path, "must be a port between 1 and 65535, got "+strconv.Itoa(p))
+}
+
+func (v *validator) hostPort(path, addr string) {
+	if _, _, err := net.SplitHostPort(addr); err != nil || addr == "" {
+		v.add(path, "want host:port, got %q", addr)
+	}
+}
+
+func (v *validator) positive(path string, d time.Duration) {
+	v.check(d > 0, path, "must be positive")
+}
+
+func (v *validator) oneOf(path, got string, allowed ...string) {
+	if !slices.Contains(allowed, got) {
+		v.add(path, "unknown value %q (want one of %q)", got, allowed)
+	}
+}
+
+// pair checks that two settings are either both set or both empty.
+func (v *validator) pair(pathA, a, pathB, b string) {
+	if a != "" && b == "" {
+		v.add(pathB, "must be set along with %s", pathA)
+	}
+	if b != "" && a == "" {
+		v.add(pathA, "must be set along with %s", pathB)
+	}
+}
@@ -0,0 +1,53 @@
+// Package gpu holds GPU facts shared by the worker, which reads them from
+// NVML or simulates them, and the router, which scores on them. It has no
+// build tags or cgo, so the router can use it without pulling in NVML.
+package gpu
+
+// Clock throttle reason bits, as reported by
+// nvmlDeviceGetCurrentClocksThrottleReasons.
+const (
+	ThrottleGpuIdle              uint64 = 0x1
+	ThrottleApplicationsClocks   uint64 = 0x2
+	ThrottleSwPowerCap           uint64 = 0x4
+	ThrottleHwSlowdown           uint64 = 0x8
+	ThrottleSyncBoost            uint64 = 0x10
+	ThrottleSwThermalSlowdown    uint64 = 0x20
+	ThrottleHwThermalSlowdown    uint64 = 0x40
+	ThrottleHwPowerBrakeSlowdown uint64 = 0x80
+	ThrottleDisplayClockSetting  uint64 = 0x100
+
+	// ThrottleThermal covers every reason caused by heat.
+	ThrottleThermal = ThrottleSwThermalSlowdown | ThrottleHwThermalSlowdown | ThrottleHwSlowdown
+	// ThrottlePower covers every reason caused by the power budget.
+	ThrottlePower = ThrottleSwPowerCap | ThrottleHwPowerBrakeSlowdown
+)
+
+// ThrottleReason names one clock-throttle bit.
+type ThrottleReason struct {
+	Bit  uint64
+	Name string
+}
+
+// ThrottleReasons lists every known reason in bit order.
+var ThrottleReasons = []ThrottleReason{
+	{ThrottleGpuIdle, "gpu_idle"},
+	{ThrottleApplicationsClocks, "applications_clocks"},
+	{ThrottleSwPowerCap, "sw_power_cap"},
+	{ThrottleHwSlowdown, "hw_slowdown"},
+	{ThrottleSyncBoost, "sync_boost"},
+	{ThrottleSwThermalSlowdown, "sw_thermal_slowdown"},
+	{ThrottleHwThermalSlowdown, "hw_thermal_slowdown"},
+	{ThrottleHwPowerBrakeSlowdown, "hw_power_brake_slowdown"},
+	{ThrottleDisplayClockSetting, "display_clock_setting"},
+}
+
+// ThrottleReasonNames returns the names of all known reasons set in mask.
+func ThrottleReasonNames(mask uint64) []string {
+	var names []string
+	for _, t := range ThrottleReasons {
+		if mask&t.Bit != 0 {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,220 @@
+// Package logging sets up structured, levelled logging on top of log/slog.
+//
+// Each subsystem gets its own logger from For, tagged component=<name> and
+// with an optional level override. Attributes attached to a context with
+// With (request_id, worker_id, ...) are added to every line logged with
+// that context. Repetitive sub-warning lines are sampled per message.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options configures Setup.
+type Options struct {
+	Level      string // debug, info, warn, error (default info)
+	Format     string // text or json (default text)
+	Components string // per-component levels, e.g. "batcher=debug,poller=warn"
+
+	// Sampling of debug/info lines, per message per second: the first
+	// SampleInitial are logged, then every SampleThereafter-th. Zero
+	// SampleInitial disables sampling.
+	SampleInitial    int
+	SampleThereafter int
+}
+
+type state struct {
+	base       slog.Handler
+	level      slog.Level
+	components map[string]slog.Level
+	sampler    *sampler
+}
+
+var current atomic.Pointer[state]
+
+func init() {
+	current.Store(&state{base: slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})})
+}
+
+// Setup configures logging for the process. attrs (key/value pairs) are
+// attached to every line, e.g. "worker_id", cfg.WorkerID. It also routes
+// the standard library's log package through the new handler.
+func Setup(opts Options, w io.Writer, attrs ...any) error {
+	level, err := parseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	components := make(map[string]slog.Level)
+	for _, part := range strings.Split(opts.Components, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, lvl, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("log component override %q: want name=level", part)
+		}
+		l, err := parseLevel(lvl)
+		if err != nil {
+			return fmt.Errorf("log component %s: %w", name, err)
+		}
+		components[strings.TrimSpace(name)] = l
+	}
+
+	// The base handler lets everything through; levels are enforced per component
+	hopts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: durationsAsText}
+	var base slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		base = slog.NewTextHandler(w, hopts)
+	case "json":
+		base = slog.NewJSONHandler(w, hopts)
+	default:
+		return fmt.Errorf("unknown log format %q (want text or json)", opts.Format)
+	}
+	if len(attrs) > 0 {
+		base = base.WithAttrs(argsToAttrs(attrs))
+	}
+
+	st := &state{base: base, level: level, components: components}
+	if opts.SampleInitial > 0 {
+		st.sampler = newSampler(opts.SampleInitial, opts.SampleThereafter, time.Second)
+	}
+	current.Store(st)
+	slog.SetDefault(slog.New(&handler{next: base, level: level, sampler: st.sampler}))
+	return nil
+}
+
+// For returns the logger for a component. Call it after Setup; loggers
+// keep the configuration that was current when they were created.
+func For(component string) *slog.Logger {
+	st := current.Load()
+	level := st.level
+	if l, ok := st.components[component]; ok {
+		level = l
+	}
+	h := &handler{next: st.base, level: level, sampler: st.sampler}
+	return slog.New(h).With("component", component)
+}
+
+// Fatal logs at error level and exits.
+func Fatal(l *slog.Logger, msg string, args ...any) {
+	l.Error(msg, args...)
+	os.Exit(1)
+}
+
+// --- context attributes ------------------------------------------------------------
+
+type ctxKey struct{}
+
+// With returns ctx carrying extra key/value attributes for log lines
+// written with it (logger.InfoContext(ctx, ...)).
+func With(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	attrs := append(append([]slog.Attr(nil), prev...), argsToAttrs(args)...)
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+func argsToAttrs(args []any) []slog.Attr {
+	var r slog.Record
+	r.Add(args...)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return attrs
+}
+
+// --- handler -----------------------------------------------------------------------
+
+// handler applies a component level, sampling and context attributes in
+// front of the shared base handler.
+type handler struct {
+	next    slog.Handler
+	level   slog.Level
+	sampler *sampler
+}
+
+func (h *handler) Enabled(_ context.Context, l slog.Level) bool { return l >= h.level }
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if h.sampler != nil && r.Level < slog.LevelWarn && !h.sampler.allow(r.Message) {
+		return nil
+	}
+	if ctx != nil {
+		if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+			r.AddAttrs(attrs...)
+		}
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{next: h.next.WithAttrs(attrs), level: h.level, sampler: h.sampler}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{next: h.next.WithGroup(name), level: h.level, sampler: h.sampler}
+}
+
+// --- sampling ----------------------------------------------------------------------
+
+type sampler struct {
+	initial, thereafter int
+	period              time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[string]int
+}
+
+func newSampler(initial, thereafter int, period time.Duration) *sampler {
+	return &sampler{initial: initial, thereafter: thereafter, period: period, counts: make(map[string]int)}
+}
+
+func (s *sampler) allow(msg string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.windowStart) >= s.period {
+		s.windowStart = now
+		clear(s.counts)
+	}
+	s.counts[msg]++
+	n := s.counts[msg]
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
+
+// durationsAsText renders durations as "50ms" rather than nanoseconds in JSON.
+func durationsAsText(_ []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindDuration {
+		return slog.String(a.Key, a.Value.Duration().String())
+	}
+	return a
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
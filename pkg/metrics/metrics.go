@@ -0,0 +1,353 @@
+// Package metrics is a small Prometheus text-exposition registry:
+// labelled counters, gauges and histograms with no external dependencies.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Labels are constant label pairs attached to every series in a registry.
+type Labels map[string]string
+
+// Default bucket layouts.
+var (
+	// LatencyBuckets covers 1ms–30s, in seconds.
+	LatencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+	// BatchSizeBuckets covers batch sizes 1–128.
+	BatchSizeBuckets = []float64{1, 2, 4, 8, 16, 32, 64, 128}
+)
+
+// Registry holds metric families and renders them in exposition format.
+type Registry struct {
+	mu       sync.Mutex
+	constLbl string // pre-rendered `k="v",...` for const labels
+	families []family
+}
+
+type family interface {
+	write(w io.Writer, constLbl string)
+}
+
+// NewRegistry creates a registry. constLabels are added to every series,
+// e.g. {"worker": "worker-1"}, so several processes can be scraped into
+// one Prometheus without series colliding.
+func NewRegistry(constLabels Labels) *Registry {
+	keys := make([]string, 0, len(constLabels))
+	for k := range constLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, k, escape(constLabels[k]))
+	}
+	return &Registry{constLbl: strings.Join(pairs, ",")}
+}
+
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families = append(r.families, f)
+}
+
+// WriteText writes every family in registration order.
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	families := append([]family(nil), r.families...)
+	r.mu.Unlock()
+	for _, f := range families {
+		f.write(w, r.constLbl)
+	}
+}
+
+// ServeHTTP serves the registry as a /metrics endpoint.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+// --- vectors ---------------------------------------------------------------
+
+// vec is the shared label → series bookkeeping for all metric kinds.
+type vec[T any] struct {
+	name, help, kind string
+	labels           []string
+	newSeries        func() *T
+
+	mu     sync.Mutex
+	series map[string]*T
+	values map[string][]string
+}
+
+func newVec[T any](name, help, kind string, labels []string, newSeries func() *T) *vec[T] {
+	return &vec[T]{
+		name: name, help: help, kind: kind, labels: labels, newSeries: newSeries,
+		series: make(map[string]*T),
+		values: make(map[string][]string),
+	}
+}
+
+func (v *vec[T]) with(values ...string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = v.newSeries()
+		v.series[key] = s
+		v.values[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// each visits series sorted by label values, for stable output.
+func (v *vec[T]) each(fn func(labels string, s *T)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	type entry struct {
+		lbl string
+		s   *T
+	}
+	entries := make([]entry, len(keys))
+	for i, k := range keys {
+		pairs := make([]string, len(v.labels))
+		for j, name := range v.labels {
+			pairs[j] = fmt.Sprintf(`%s="%s"`, name, escape(v.values[k][j]))
+		}
+		entries[i] = entry{strings.Join(pairs, ","), v.series[k]}
+	}
+	v.mu.Unlock()
+
+	for _, e := range entries {
+		fn(e.lbl, e.s)
+	}
+}
+
+func (v *vec[T]) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, strings.ReplaceAll(v.help, "\n", " "))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+}
+
+// --- counters & gauges -------------------------------------------------------
+
+// Value is a single float series used by both counters and gauges.
+type Value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+// Inc adds 1.
+func (c *Value) Inc() { c.Add(1) }
+
+// Add adds delta (must be >= 0 for counters).
+func (c *Value) Add(delta float64) {
+	c.mu.Lock()
+	c.v += delta
+	c.mu.Unlock()
+}
+
+// Set replaces the value (gauges only).
+func (c *Value) Set(v float64) {
+	c.mu.Lock()
+	c.v = v
+	c.mu.Unlock()
+}
+
+func (c *Value) get() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.v
+}
+
+// ValueVec is a labelled counter or gauge family.
+type ValueVec struct{ *vec[Value] }
+
+// CounterVec and GaugeVec differ only in their declared TYPE.
+type (
+	CounterVec = ValueVec
+	GaugeVec   = ValueVec
+)
+
+// NewCounterVec registers a counter family.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &ValueVec{newVec(name, help, "counter", labels, func() *Value { return &Value{} })}
+	r.register(v)
+	return v
+}
+
+// NewGaugeVec registers a gauge family.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &ValueVec{newVec(name, help, "gauge", labels, func() *Value { return &Value{} })}
+	r.register(v)
+	return v
+}
+
+// With returns the series for the given label values (in declaration order).
+func (v *ValueVec) With(values ...string) *Value { return v.with(values...) }
+
+// Delete drops the series for the given label values, e.g. when a worker
+// leaves the cluster and its gauges should stop being exported.
+func (v *ValueVec) Delete(values ...string) {
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	delete(v.series, key)
+	delete(v.values, key)
+	v.mu.Unlock()
+}
+
+// DeleteMatching drops every series whose label has value, whatever its
+// other labels, e.g. all of a departed worker's per-code failure counts.
+func (v *ValueVec) DeleteMatching(label, value string) {
+	i := slices.Index(v.labels, label)
+	if i < 0 {
+		return
+	}
+	v.mu.Lock()
+	for key, values := range v.values {
+		if values[i] == value {
+			delete(v.series, key)
+			delete(v.values, key)
+		}
+	}
+	v.mu.Unlock()
+}
+
+func (v *ValueVec) write(w io.Writer, constLbl string) {
+	v.header(w)
+	v.each(func(lbl string, s *Value) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, joinLabels(constLbl, lbl), formatFloat(s.get()))
+	})
+}
+
+// funcValue is an unlabelled series read at scrape time.
+type funcValue struct {
+	name, help, kind string
+	fn               func() float64
+}
+
+// NewCounterFunc registers a counter whose value is read from fn at scrape time.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&funcValue{name, help, "counter", fn})
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn at scrape time.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&funcValue{name, help, "gauge", fn})
+}
+
+func (f *funcValue) write(w io.Writer, constLbl string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+	fmt.Fprintf(w, "%s%s %s\n", f.name, joinLabels(constLbl, ""), formatFloat(f.fn()))
+}
+
+// --- histograms --------------------------------------------------------------
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	bounds  []float64
+	buckets []uint64 // non-cumulative, len(bounds)+1 (last is +Inf)
+	sum     float64
+	count   uint64
+}
+
+// Observe records one value.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v) // first bound >= v
+	h.mu.Lock()
+	h.buckets[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+// HistogramVec is a labelled histogram family.
+type HistogramVec struct{ *vec[Histogram] }
+
+// NewHistogramVec registers a histogram family with the given upper bounds.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	v := &HistogramVec{newVec(name, help, "histogram", labels, func() *Histogram {
+		return &Histogram{bounds: bounds, buckets: make([]uint64, len(bounds)+1)}
+	})}
+	r.register(v)
+	return v
+}
+
+// With returns the histogram for the given label values.
+func (v *HistogramVec) With(values ...string) *Histogram { return v.with(values...) }
+
+func (v *HistogramVec) write(w io.Writer, constLbl string) {
+	v.header(w)
+	v.each(func(lbl string, h *Histogram) {
+		h.mu.Lock()
+		buckets := append([]uint64(nil), h.buckets...)
+		sum, count := h.sum, h.count
+		h.mu.Unlock()
+
+		base := joinLabels(constLbl, lbl)
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += buckets[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, withLe(base, formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, withLe(base, "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.name, base, formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.name, base, count)
+	})
+}
+
+// --- formatting --------------------------------------------------------------
+
+func joinLabels(a, b string) string {
+	switch {
+	case a == "" && b == "":
+		return ""
+	case a == "":
+		return "{" + b + "}"
+	case b == "":
+		return "{" + a + "}"
+	}
+	return "{" + a + "," + b + "}"
+}
+
+func withLe(labels, le string) string {
+	if labels == "" {
+		return `{le="` + le + `"}`
+	}
+	return labels[:len(labels)-1] + `,le="` + le + `"}`
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escape applies the exposition-format escaping for label values.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,152 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxWeight bounds operator weights so one worker can't starve the rest
+// by accident (a typo of 100 instead of 1.0).
+const maxWeight = 10.0
+
+// AdminAction describes an operator change, broadcast to dashboards with
+// the state that resulted from it.
+type AdminAction struct {
+	Action string    `json:"action"` // drain, cordon, uncordon, weight
+	Worker string    `json:"worker"` // address
+	Weight float64   `json:"weight,omitempty"`
+	User   string    `json:"user"`
+	Remote string    `json:"remote"`
+	At     time.Time `json:"at"`
+}
+
+// adminWorker is a worker's operator-facing state in admin API responses.
+type adminWorker struct {
+	Address  string   `json:"address"`
+	ID       string   `json:"id"`
+	Healthy  bool     `json:"healthy"`
+	Cordoned bool     `json:"cordoned"`
+	Draining bool     `json:"draining"`
+	Drained  bool     `json:"drained"`
+	Weight   float64  `json:"weight"`
+	InFlight int64    `json:"in_flight"`
+	Source   string   `json:"source"`
+	Models   []string `json:"models,omitempty"`
+	Capacity int32    `json:"capacity,omitempty"`
+}
+
+func toAdminWorker(w WorkerView) adminWorker {
+	return adminWorker{
+		Address:  w.Entry.Address,
+		ID:       w.Metrics.GetWorkerId(),
+		Healthy:  w.Healthy,
+		Cordoned: w.Cordoned,
+		Draining: w.Draining,
+		Drained:  w.Drained(),
+		Weight:   w.Weight,
+		InFlight: w.Entry.InFlight(),
+		Source:   w.Entry.Source,
+		Models:   w.Models,
+		Capacity: w.Capacity,
+	}
+}
+
+// registerAdmin adds the operator API. Every route needs the admin role:
+// an admin dashboard user, or "Authorization: Bearer $ADMIN_TOKEN". With
+// dashboard auth off and no ADMIN_TOKEN the API is disabled.
+//
+//	GET  /api/admin/workers
+//	POST /api/admin/workers/{worker}/drain
+//	POST /api/admin/workers/{worker}/cordon
+//	POST /api/admin/workers/{worker}/uncordon
+//	PUT  /api/admin/workers/{worker}/weight   {"weight": 0.5}
+//
+// {worker} is a worker address or ID.
+func (r *Router) registerAdmin(mux *http.ServeMux) {
+	mux.Handle("GET /api/admin/workers", r.auth.require(roleAdmin, http.HandlerFunc(r.handleListWorkers)))
+	mux.Handle("POST /api/admin/workers/{worker}/drain", r.auth.require(roleAdmin, r.adminChange("drain", func(w *WorkerEntry, _ float64) {
+		w.Draining = true
+	})))
+	mux.Handle("POST /api/admin/workers/{worker}/cordon", r.auth.require(roleAdmin, r.adminChange("cordon", func(w *WorkerEntry, _ float64) {
+		w.Cordoned = true
+	})))
+	mux.Handle("POST /api/admin/workers/{worker}/uncordon", r.auth.require(roleAdmin, r.adminChange("uncordon", func(w *WorkerEntry, _ float64) {
+		w.Cordoned = false
+		w.Draining = false
+	})))
+	mux.Handle("PUT /api/admin/workers/{worker}/weight", r.auth.require(roleAdmin, r.adminChange("weight", func(w *WorkerEntry, weight float64) {
+		w.Weight = weight
+	})))
+}
+
+func (r *Router) handleListWorkers(w http.ResponseWriter, req *http.Request) {
+	workers := r.registry.Views()
+	out := make([]adminWorker, 0, len(workers))
+	for _, wk := range workers {
+		out = append(out, toAdminWorker(wk))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// adminChange returns a handler applying change to the {worker} in the
+// path, then pushing the new state to every dashboard. Workers being
+// removed are left alone.
+func (r *Router) adminChange(action string, change func(w *WorkerEntry, weight float64)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var weight float64
+		if action == "weight" {
+			var body struct {
+				Weight *float64 `json:"weight"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Weight == nil {
+				http.Error(w, `body must be {"weight": <number>}`, http.StatusBadRequest)
+				return
+			}
+			weight = *body.Weight
+			if weight < 0 || weight > maxWeight {
+				http.Error(w, fmt.Sprintf("weight must be between 0 and %g", maxWeight), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var result adminWorker
+		var removing bool
+		entry := r.registry.Control(req.PathValue("worker"), func(wk *WorkerEntry) {
+			// A removed worker drains until it's forgotten; undoing that
+			// would route requests to it that then fail
+			if removing = wk.Removing; removing {
+				return
+			}
+			change(wk, weight)
+			result = toAdminWorker(wk.view())
+		})
+		if entry == nil {
+			http.Error(w, "unknown worker "+req.PathValue("worker"), http.StatusNotFound)
+			return
+		}
+		if removing {
+			http.Error(w, entry.Address+" is being removed", http.StatusConflict)
+			return
+		}
+
+		user := principalFrom(req.Context()).name
+		act := &AdminAction{Action: action, Worker: entry.Address, User: user, Remote: req.RemoteAddr, At: time.Now()}
+		if action == "weight" {
+			act.Weight = weight
+		}
+		r.log.Info("admin action", "action", action, "worker", entry.Address, "weight", result.Weight,
+			"cordoned", result.Cordoned, "draining", result.Draining, "user", user, "remote", req.RemoteAddr)
+		r.tel.adminActions.With(action).Inc()
+		r.notifyDashboards(act)
+
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// adminRequest sends an admin API request with ADMIN_TOKEN "adm" and
+// returns the response code.
+func adminRequest(mux http.Handler, method, path, body string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer adm")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestAdminLeavesRemovingWorkerAlone(t *testing.T) {
+	r := newTestRouter(t, map[string]string{
+		"WORKER_ENDPOINTS": "127.0.0.1:1,127.0.0.1:2",
+		"ADMIN_TOKEN":      "adm",
+	})
+	defer r.Stop()
+	mux := http.NewServeMux()
+	r.RegisterHTTP(mux)
+
+	// Hold a request on the worker so its removal waits for it to drain
+	entry := r.registry.Control("127.0.0.1:1", func(*WorkerEntry) {})
+	done := entry.beginRequest()
+	go r.removeWorker("127.0.0.1:1", 5*time.Second)
+	eventually(t, "the worker to start draining", func() bool {
+		var removing bool
+		r.registry.Control("127.0.0.1:1", func(w *WorkerEntry) { removing = w.Removing })
+		return removing
+	})
+
+	tests := []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"uncordon", "POST", "/api/admin/workers/127.0.0.1:1/uncordon", "", http.StatusConflict},
+		{"cordon", "POST", "/api/admin/workers/127.0.0.1:1/cordon", "", http.StatusConflict},
+		{"drain", "POST", "/api/admin/workers/127.0.0.1:1/drain", "", http.StatusConflict},
+		{"weight", "PUT", "/api/admin/workers/127.0.0.1:1/weight", `{"weight": 2}`, http.StatusConflict},
+		{"another worker", "POST", "/api/admin/workers/127.0.0.1:2/uncordon", "", http.StatusOK},
+		{"unknown worker", "POST", "/api/admin/workers/127.0.0.1:3/uncordon", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := adminRequest(mux, tt.method, tt.path, tt.body); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	var draining bool
+	var weight float64
+	r.registry.Control("127.0.0.1:1", func(w *WorkerEntry) { draining, weight = w.Draining, w.Weight })
+	if !draining || weight != 1 {
+		t.Errorf("draining %v, weight %v; want the removal's drain and the weight untouched", draining, weight)
+	}
+	for range 20 {
+		if w := r.pickBestWorker(""); w == nil || w.Entry.Address != "127.0.0.1:2" {
+			t.Fatalf("routed to %v while the other worker is being removed", w)
+		}
+	}
+
+	done()
+	eventually(t, "the worker to be removed", func() bool { return sourceOf(r, "127.0.0.1:1") == "" })
+}
@@ -0,0 +1,351 @@
+package router
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kunal/gpu-batch-router/pkg/config"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+)
+
+// Dashboard auth modes (DASHBOARD_AUTH).
+const (
+	AuthNone  = "none"
+	AuthToken = "token"
+	AuthBasic = "basic"
+)
+
+const sessionCookie = "gbr_session"
+
+// role orders what a dashboard user may do; each includes the ones below.
+type role int
+
+const (
+	roleNone  role = iota
+	roleRead       // dashboard, /ws, history, /metrics
+	roleAdmin      // plus the admin API
+)
+
+func (r role) String() string {
+	switch r {
+	case roleRead:
+		return "read"
+	case roleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+func parseRole(s string) (role, error) {
+	switch strings.TrimSpace(s) {
+	case "read":
+		return roleRead, nil
+	case "admin":
+		return roleAdmin, nil
+	}
+	return roleNone, fmt.Errorf("unknown role %q (want read or admin)", s)
+}
+
+// principal is an authenticated dashboard user.
+type principal struct {
+	name string
+	role role
+}
+
+type credential struct {
+	secret []byte // plaintext, or a SHA-256 digest when hashed
+	hashed bool
+	role   role
+}
+
+// parseCredential reads a configured secret: plaintext, or "sha256:<hex>"
+// to keep only its digest in config.
+func parseCredential(s string, r role) (credential, error) {
+	digest, ok := strings.CutPrefix(s, "sha256:")
+	if !ok {
+		return credential{secret: []byte(s), role: r}, nil
+	}
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != sha256.Size {
+		return credential{}, fmt.Errorf("bad sha256 digest")
+	}
+	return credential{secret: sum, hashed: true, role: r}, nil
+}
+
+func (c credential) matches(given string) bool {
+	if c.hashed {
+		sum := sha256.Sum256([]byte(given))
+		return subtle.ConstantTimeCompare(sum[:], c.secret) == 1
+	}
+	return subtle.ConstantTimeCompare([]byte(given), c.secret) == 1
+}
+
+// authenticator guards the dashboard HTTP side. In token mode requests
+// carry "Authorization: Bearer <token>" (or ?token= for the first page
+// load and WebSocket upgrades, which can't set headers); in basic mode,
+// HTTP basic auth. With a session secret, a successful login also sets a
+// signed cookie so later requests need no credentials. ADMIN_TOKEN is
+// accepted as an admin bearer token in every mode.
+type authenticator struct {
+	mode       string
+	tokens     map[string]credential // token mode, keyed by a display name
+	users      map[string]credential // basic mode, keyed by username
+	adminToken string
+	secret     []byte
+	ttl        time.Duration
+	origins    map[string]bool
+	log        *slog.Logger
+}
+
+func newAuthenticator(cfg *config.Config) (*authenticator, error) {
+	a := &authenticator{
+		mode:       cfg.DashboardAuth,
+		tokens:     make(map[string]credential),
+		users:      make(map[string]credential),
+		adminToken: cfg.AdminToken,
+		secret:     []byte(cfg.SessionSecret),
+		ttl:        cfg.SessionTTL,
+		origins:    make(map[string]bool),
+		log:        logging.For("auth"),
+	}
+	if a.mode == "" {
+		a.mode = AuthNone
+	}
+
+	switch a.mode {
+	case AuthNone:
+	case AuthToken:
+		for i, entry := range config.SplitList(cfg.DashboardTokens) {
+			r, token, ok := strings.Cut(entry, ":")
+			if !ok || token == "" {
+				return nil, fmt.Errorf("DASHBOARD_TOKENS entry %d: want role:token", i+1)
+			}
+			rl, err := parseRole(r)
+			if err != nil {
+				return nil, fmt.Errorf("DASHBOARD_TOKENS entry %d: %w", i+1, err)
+			}
+			cred, err := parseCredential(token, rl)
+			if err != nil {
+				return nil, fmt.Errorf("DASHBOARD_TOKENS entry %d: %w", i+1, err)
+			}
+			a.tokens[fmt.Sprintf("token-%d", i+1)] = cred
+		}
+		if len(a.tokens) == 0 && a.adminToken == "" {
+			return nil, fmt.Errorf("DASHBOARD_AUTH=token needs DASHBOARD_TOKENS or ADMIN_TOKEN")
+		}
+	case AuthBasic:
+		for i, entry := range config.SplitList(cfg.DashboardUsers) {
+			parts := strings.SplitN(entry, ":", 3)
+			if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+				return nil, fmt.Errorf("DASHBOARD_USERS entry %d: want user:role:password", i+1)
+			}
+			rl, err := parseRole(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("DASHBOARD_USERS user %s: %w", parts[0], err)
+			}
+			cred, err := parseCredential(parts[2], rl)
+			if err != nil {
+				return nil, fmt.Errorf("DASHBOARD_USERS user %s: %w", parts[0], err)
+			}
+			a.users[parts[0]] = cred
+		}
+		if len(a.users) == 0 {
+			return nil, fmt.Errorf("DASHBOARD_AUTH=basic needs DASHBOARD_USERS")
+		}
+	default:
+		return nil, fmt.Errorf("unknown DASHBOARD_AUTH %q (want %s, %s or %s)", a.mode, AuthNone, AuthToken, AuthBasic)
+	}
+
+	for _, o := range cfg.WSAllowedOrigins {
+		a.origins[strings.TrimRight(strings.TrimSpace(o), "/")] = true
+	}
+	if a.ttl <= 0 {
+		a.ttl = 12 * time.Hour
+	}
+	return a, nil
+}
+
+// identify returns who made req, if anyone, and whether the credentials
+// came from a session cookie. Explicit credentials win over the cookie, so
+// an admin token still works in a browser holding a read-only session.
+func (a *authenticator) identify(req *http.Request) (p principal, fromCookie, ok bool) {
+	token, hasBearer := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !hasBearer && a.mode == AuthToken {
+		token = req.URL.Query().Get("token")
+	}
+	if token != "" {
+		if a.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) == 1 {
+			return principal{name: "admin-token", role: roleAdmin}, false, true
+		}
+		if a.mode == AuthToken {
+			for name, cred := range a.tokens {
+				if cred.matches(token) {
+					return principal{name: name, role: cred.role}, false, true
+				}
+			}
+		}
+	}
+
+	if a.mode == AuthBasic {
+		if user, pass, ok := req.BasicAuth(); ok {
+			if cred, known := a.users[user]; known && cred.matches(pass) {
+				return principal{name: user, role: cred.role}, false, true
+			}
+		}
+	}
+
+	if p, ok := a.fromSession(req); ok {
+		return p, true, true
+	}
+	return principal{}, false, false
+}
+
+// require wraps next so it only runs for callers holding at least min.
+// With auth off, everyone may read and only ADMIN_TOKEN may administer.
+func (a *authenticator) require(min role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p, fromCookie, ok := a.identify(req)
+		if !ok && a.mode == AuthNone && min == roleRead {
+			p, ok = principal{name: "anonymous", role: roleRead}, true
+		}
+		if !ok {
+			if a.mode == AuthNone && a.adminToken == "" {
+				http.Error(w, "admin API disabled (set ADMIN_TOKEN)", http.StatusForbidden)
+				return
+			}
+			if min >= roleAdmin {
+				a.log.Warn("rejected admin request", "remote", req.RemoteAddr, "path", req.URL.Path)
+			}
+			a.challenge(w, req)
+			return
+		}
+		if p.role < min {
+			a.log.Warn("forbidden", "user", p.name, "role", p.role, "path", req.URL.Path, "remote", req.RemoteAddr)
+			http.Error(w, "forbidden: needs "+min.String()+" role", http.StatusForbidden)
+			return
+		}
+		if !fromCookie && len(a.secret) > 0 && a.mode != AuthNone {
+			a.setSession(w, req, p)
+		}
+		next.ServeHTTP(w, req.WithContext(withPrincipal(req.Context(), p)))
+	})
+}
+
+func (a *authenticator) challenge(w http.ResponseWriter, req *http.Request) {
+	a.log.Debug("unauthenticated request", "path", req.URL.Path, "remote", req.RemoteAddr)
+	if a.mode == AuthBasic {
+		w.Header().Set("WWW-Authenticate", `Basic realm="gpu-batch-router", charset="UTF-8"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gpu-batch-router"`)
+	}
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+// checkOrigin allows WebSocket upgrades from the dashboard's own origin,
+// from WS_ALLOWED_ORIGINS, and from non-browser clients (no Origin header).
+func (a *authenticator) checkOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" || a.origins["*"] || a.origins[origin] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+	a.log.Warn("WebSocket origin rejected", "origin", origin, "remote", req.RemoteAddr)
+	return false
+}
+
+// --- sessions ----------------------------------------------------------------------
+//
+// Cookie value: base64url("name|role|expiry-unix") "." base64url(HMAC-SHA256).
+
+func (a *authenticator) setSession(w http.ResponseWriter, req *http.Request, p principal) {
+	exp := time.Now().Add(a.ttl)
+	payload := base64.RawURLEncoding.EncodeToString(
+		[]byte(p.name + "|" + p.role.String() + "|" + strconv.FormatInt(exp.Unix(), 10)))
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    payload + "." + a.sign(payload),
+		Path:     "/",
+		Expires:  exp,
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func (a *authenticator) fromSession(req *http.Request) (principal, bool) {
+	if len(a.secret) == 0 || a.mode == AuthNone {
+		return principal{}, false
+	}
+	c, err := req.Cookie(sessionCookie)
+	if err != nil {
+		return principal{}, false
+	}
+	payload, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(a.sign(payload))) {
+		return principal{}, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return principal{}, false
+	}
+	fields := strings.Split(string(raw), "|")
+	if len(fields) != 3 {
+		return principal{}, false
+	}
+	rl, err := parseRole(fields[1])
+	exp, err2 := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || err2 != nil || time.Now().Unix() > exp {
+		return principal{}, false
+	}
+	return principal{name: fields[0], role: rl}, true
+}
+
+func (a *authenticator) sign(payload string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// handleLogout clears the session cookie.
+func (a *authenticator) handleLogout(w http.ResponseWriter, req *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleWhoami tells the dashboard who it is signed in as, so it can hide
+// controls the user can't use.
+func (a *authenticator) handleWhoami(w http.ResponseWriter, req *http.Request) {
+	p := principalFrom(req.Context())
+	writeJSON(w, http.StatusOK, map[string]any{
+		"user": p.name,
+		"role": p.role.String(),
+		"auth": a.mode,
+		// Without auth the admin API takes ADMIN_TOKEN directly
+		"can_admin": p.role >= roleAdmin || (a.mode == AuthNone && a.adminToken != ""),
+	})
+}
+
+type principalKey struct{}
+
+func withPrincipal(ctx context.Context, p principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func principalFrom(ctx context.Context) principal {
+	p, _ := ctx.Value(principalKey{}).(principal)
+	return p
+}
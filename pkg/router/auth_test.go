@@ -0,0 +1,294 @@
+package router
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kunal/gpu-batch-router/pkg/config"
+)
+
+const (
+	testViewerToken = "viewer-token"
+	testAdminToken  = "admin-token"
+	testRootToken   = "root-token" // ADMIN_TOKEN
+)
+
+func newTestAuth(t *testing.T, cfg *config.Config) *authenticator {
+	t.Helper()
+	a, err := newAuthenticator(cfg)
+	if err != nil {
+		t.Fatalf("newAuthenticator: %v", err)
+	}
+	return a
+}
+
+func TestIdentify(t *testing.T) {
+	token := newTestAuth(t, &config.Config{
+		DashboardAuth:   AuthToken,
+		DashboardTokens: "read:" + testViewerToken + ",admin:sha256:" + sha256Hex(testAdminToken),
+		AdminToken:      testRootToken,
+	})
+	basic := newTestAuth(t, &config.Config{
+		DashboardAuth:  AuthBasic,
+		DashboardUsers: "alice:read:alice-pw,bob:admin:sha256:" + sha256Hex("bob-pw"),
+		AdminToken:     testRootToken,
+	})
+	bearer := func(tok string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+tok) }
+	}
+	basicAuth := func(user, pass string) func(*http.Request) {
+		return func(req *http.Request) { req.SetBasicAuth(user, pass) }
+	}
+
+	tests := []struct {
+		name  string
+		auth  *authenticator
+		path  string
+		creds func(*http.Request)
+		want  role // roleNone: not identified
+	}{
+		{"plaintext token", token, "/", bearer(testViewerToken), roleRead},
+		{"hashed token", token, "/", bearer(testAdminToken), roleAdmin},
+		{"token in the query", token, "/ws?token=" + testViewerToken, nil, roleRead},
+		{"ADMIN_TOKEN", token, "/", bearer(testRootToken), roleAdmin},
+		{"wrong token", token, "/", bearer("guess"), roleNone},
+		{"token prefix", token, "/", bearer(testViewerToken[:4]), roleNone},
+		{"sha256 digest as the token", token, "/", bearer(sha256Hex(testAdminToken)), roleNone},
+		{"no credentials", token, "/", nil, roleNone},
+
+		{"plaintext password", basic, "/", basicAuth("alice", "alice-pw"), roleRead},
+		{"hashed password", basic, "/", basicAuth("bob", "bob-pw"), roleAdmin},
+		{"wrong password", basic, "/", basicAuth("alice", "bob-pw"), roleNone},
+		{"unknown user", basic, "/", basicAuth("mallory", "alice-pw"), roleNone},
+		{"ADMIN_TOKEN in basic mode", basic, "/", bearer(testRootToken), roleAdmin},
+		{"query token in basic mode", basic, "/?token=alice-pw", nil, roleNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.creds != nil {
+				tt.creds(req)
+			}
+			p, _, ok := tt.auth.identify(req)
+			if ok != (tt.want != roleNone) || p.role != tt.want {
+				t.Errorf("got %v (identified %v), want %v", p.role, ok, tt.want)
+			}
+		})
+	}
+}
+
+// sessionCookieFor is a session cookie for name and role that expires at
+// exp, signed with a's secret.
+func sessionCookieFor(a *authenticator, name string, r role, exp time.Time) *http.Cookie {
+	payload := base64.RawURLEncoding.EncodeToString(
+		[]byte(name + "|" + r.String() + "|" + strconv.FormatInt(exp.Unix(), 10)))
+	return &http.Cookie{Name: sessionCookie, Value: payload + "." + a.sign(payload)}
+}
+
+func TestSessionCookie(t *testing.T) {
+	cfg := &config.Config{
+		DashboardAuth:   AuthToken,
+		DashboardTokens: "read:" + testViewerToken,
+		SessionSecret:   "session-secret",
+	}
+	a := newTestAuth(t, cfg)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(principalFrom(req.Context()).name))
+	})
+	h := a.require(roleRead, ok)
+
+	// Logging in with a token sets a session cookie
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+testViewerToken)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != sessionCookie {
+		t.Fatalf("login: status %d, cookies %v; want 200 and a session cookie", rec.Code, cookies)
+	}
+	issued := cookies[0]
+	if !issued.HttpOnly || issued.SameSite != http.SameSiteStrictMode {
+		t.Errorf("cookie HttpOnly %v, SameSite %v; want HttpOnly and Strict", issued.HttpOnly, issued.SameSite)
+	}
+
+	payload, sig, _ := strings.Cut(issued.Value, ".")
+	raw, _ := base64.RawURLEncoding.DecodeString(payload)
+	promoted := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(raw), "|read|", "|admin|", 1)))
+	otherKey := newTestAuth(t, &config.Config{
+		DashboardAuth:   AuthToken,
+		DashboardTokens: "read:" + testViewerToken,
+		SessionSecret:   "another-secret",
+	})
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"issued cookie", issued, http.StatusOK},
+		{"role changed, old signature", &http.Cookie{Name: sessionCookie, Value: promoted + "." + sig}, http.StatusUnauthorized},
+		{"signature dropped", &http.Cookie{Name: sessionCookie, Value: payload}, http.StatusUnauthorized},
+		{"signed with another secret", sessionCookieFor(otherKey, "token-1", roleRead, time.Now().Add(time.Hour)), http.StatusUnauthorized},
+		{"expired", sessionCookieFor(a, "token-1", roleRead, time.Now().Add(-time.Second)), http.StatusUnauthorized},
+		{"unknown role", &http.Cookie{Name: sessionCookie, Value: func() string {
+			p := base64.RawURLEncoding.EncodeToString([]byte("x|root|" + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)))
+			return p + "." + a.sign(p)
+		}()}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.AddCookie(tt.cookie)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && len(rec.Result().Cookies()) != 0 {
+				t.Error("a request with a valid session was issued a new cookie")
+			}
+		})
+	}
+
+	// A read session can't use admin routes
+	req = httptest.NewRequest("POST", "/", nil)
+	req.AddCookie(issued)
+	rec = httptest.NewRecorder()
+	a.require(roleAdmin, ok).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("read session on an admin route: status %d, want 403", rec.Code)
+	}
+}
+
+// Router settings for token auth, and for auth off with ADMIN_TOKEN set.
+var (
+	tokenEnv = map[string]string{
+		"DASHBOARD_AUTH":   AuthToken,
+		"DASHBOARD_TOKENS": "read:" + testViewerToken + ",admin:" + testAdminToken,
+		"ADMIN_TOKEN":      testRootToken,
+	}
+	noAuthEnv = map[string]string{"ADMIN_TOKEN": testRootToken}
+)
+
+func TestRequireRoles(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		// Token mode: a viewer reads, only admins administer
+		{"viewer reads", tokenEnv, "GET", "/api/history", testViewerToken, http.StatusOK},
+		{"anonymous reads", tokenEnv, "GET", "/api/history", "", http.StatusUnauthorized},
+		{"viewer lists workers", tokenEnv, "GET", "/api/admin/workers", testViewerToken, http.StatusForbidden},
+		{"viewer drains", tokenEnv, "POST", "/api/admin/workers/127.0.0.1:1/drain", testViewerToken, http.StatusForbidden},
+		{"viewer sets weight", tokenEnv, "PUT", "/api/admin/workers/127.0.0.1:1/weight", testViewerToken, http.StatusForbidden},
+		{"admin drains", tokenEnv, "POST", "/api/admin/workers/127.0.0.1:1/drain", testAdminToken, http.StatusOK},
+		{"ADMIN_TOKEN cordons", tokenEnv, "POST", "/api/admin/workers/127.0.0.1:1/cordon", testRootToken, http.StatusOK},
+		{"health needs nothing", tokenEnv, "GET", "/health", "", http.StatusOK},
+
+		// Auth off: anyone reads, the admin API needs ADMIN_TOKEN
+		{"auth off, anonymous reads", noAuthEnv, "GET", "/api/history", "", http.StatusOK},
+		{"auth off, anonymous drains", noAuthEnv, "POST", "/api/admin/workers/127.0.0.1:1/drain", "", http.StatusUnauthorized},
+		{"auth off, ADMIN_TOKEN drains", noAuthEnv, "POST", "/api/admin/workers/127.0.0.1:1/drain", testRootToken, http.StatusOK},
+		{"auth off, no ADMIN_TOKEN", map[string]string{}, "GET", "/api/admin/workers", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"WORKER_ENDPOINTS": "127.0.0.1:1", "DASHBOARD_AUTH": AuthNone, "ADMIN_TOKEN": ""}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			r := newTestRouter(t, env)
+			defer r.Stop()
+			mux := http.NewServeMux()
+			r.RegisterHTTP(mux)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"weight": 2}`))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	a := newTestAuth(t, &config.Config{WSAllowedOrigins: []string{"https://dash.example/", " https://ops.example"}})
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true}, // not a browser
+		{"http://router.local:8080", true},
+		{"https://dash.example", true}, // listed with a trailing slash
+		{"https://ops.example", true},
+		{"https://evil.example", false},
+		{"https://dash.example.evil.example", false},
+		{"http://router.local:9090", false}, // same host, another port
+		{"null", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://router.local:8080/ws", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := a.checkOrigin(req); got != tt.want {
+			t.Errorf("origin %q: got %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+
+	wildcard := newTestAuth(t, &config.Config{WSAllowedOrigins: []string{"*"}})
+	req := httptest.NewRequest("GET", "http://router.local:8080/ws", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	if !wildcard.checkOrigin(req) {
+		t.Error("* didn't allow every origin")
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	r := newTestRouter(t, map[string]string{
+		"WORKER_ENDPOINTS":   "127.0.0.1:1",
+		"DASHBOARD_AUTH":     AuthNone,
+		"WS_ALLOWED_ORIGINS": "https://dash.example",
+	})
+	defer r.Stop()
+	mux := http.NewServeMux()
+	r.RegisterHTTP(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{"https://dash.example", http.StatusSwitchingProtocols},
+		{srv.URL, http.StatusSwitchingProtocols}, // the dashboard's own origin
+		{"https://evil.example", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {tt.origin}})
+		if conn != nil {
+			conn.Close()
+		}
+		if resp == nil {
+			t.Fatalf("origin %s: %v", tt.origin, err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Errorf("origin %s: status %d, want %d", tt.origin, resp.StatusCode, tt.want)
+		}
+	}
+}
@@ -2,58 +2,176 @@ package router
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
-}
+// WebSocket client tuning.
+const (
+	clientSendBuffer = 16               // queued messages before a client counts as slow
+	writeWait        = 10 * time.Second // per-write deadline
+	pongWait         = 60 * time.Second // a client silent this long is gone
+	pingPeriod       = pongWait * 9 / 10
+	maxClientMessage = 4096 // dashboards only send small control messages
+)
 
-// Broadcaster pushes cluster state to connected dashboard clients via WebSocket.
+// Broadcaster pushes cluster state to connected dashboard clients via
+// WebSocket (see subscribe.go for the protocol). Each client has a bounded
+// send queue drained by its own writer goroutine, so one slow browser
+// can't hold up the others; a client whose queue is full is disconnected.
 type Broadcaster struct {
-	mu      sync.RWMutex
-	clients map[*websocket.Conn]bool
+	mu       sync.RWMutex
+	clients  map[*client]struct{}
+	upgrader websocket.Upgrader
+	interval time.Duration // default push interval
+	tel      *telemetry
+	log      *slog.Logger
+
+	recentMu sync.Mutex
+	recent   []Event // last recentEvents events, replayed in snapshots
 }
 
-func NewBroadcaster() *Broadcaster {
-	return &Broadcaster{
-		clients: make(map[*websocket.Conn]bool),
+// client is one dashboard connection.
+type client struct {
+	conn      *websocket.Conn
+	remote    string
+	send      chan []byte
+	sub       *subscription
+	done      chan struct{} // closed when the client is removed
+	closeOnce sync.Once
+}
+
+// NewBroadcaster creates a broadcaster accepting WebSocket upgrades for
+// which checkOrigin returns true. Clients are pushed to every interval
+// unless they ask for another.
+func NewBroadcaster(checkOrigin func(*http.Request) bool, interval time.Duration, tel *telemetry) *Broadcaster {
+	b := &Broadcaster{
+		clients:  make(map[*client]struct{}),
+		upgrader: websocket.Upgrader{CheckOrigin: checkOrigin},
+		interval: min(max(interval, minPushInterval), maxPushInterval),
+		tel:      tel,
+		log:      logging.For("broadcast"),
 	}
+	tel.registry.NewGaugeFunc("router_dashboard_clients", "Connected dashboard WebSocket clients",
+		func() float64 { return float64(b.ClientCount()) })
+	return b
 }
 
 // HandleWS is the WebSocket upgrade handler for /ws.
 func (b *Broadcaster) HandleWS(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := b.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("⚠️  WebSocket upgrade failed: %v", err)
+		b.log.Warn("WebSocket upgrade failed", "remote", r.RemoteAddr, "err", err)
 		return
 	}
 
+	c := &client{
+		conn:   conn,
+		remote: r.RemoteAddr,
+		send:   make(chan []byte, clientSendBuffer),
+		sub:    newSubscription(b.interval),
+		done:   make(chan struct{}),
+	}
 	b.mu.Lock()
-	b.clients[conn] = true
+	b.clients[c] = struct{}{}
+	n := len(b.clients)
 	b.mu.Unlock()
 
-	log.Printf("📊 Dashboard client connected (%d total)", len(b.clients))
-
-	// Read loop (to detect disconnect)
-	go func() {
-		defer func() {
-			b.mu.Lock()
-			delete(b.clients, conn)
-			b.mu.Unlock()
-			conn.Close()
-			log.Printf("📊 Dashboard client disconnected (%d remain)", len(b.clients))
-		}()
-		for {
-			if _, _, err := conn.ReadMessage(); err != nil {
+	b.log.Info("dashboard client connected", "remote", r.RemoteAddr, "clients", n)
+
+	go b.writeLoop(c)
+	go b.readLoop(c)
+}
+
+// ClientCount returns the number of connected dashboard clients.
+func (b *Broadcaster) ClientCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.clients)
+}
+
+// readLoop handles subscribe requests and pongs, removing the client when
+// the connection fails or stays silent past pongWait.
+func (b *Broadcaster) readLoop(c *client) {
+	defer b.remove(c, "disconnected")
+	c.conn.SetReadLimit(maxClientMessage)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req clientRequest
+		if err := json.Unmarshal(data, &req); err != nil || req.Type != "subscribe" {
+			b.log.Debug("ignoring dashboard message", "remote", c.remote, "err", err)
+			continue
+		}
+		c.sub.apply(req, b.interval)
+		b.log.Debug("dashboard client subscribed", "remote", c.remote, "topics", req.Topics, "interval_ms", req.IntervalMs)
+	}
+}
+
+// writeLoop is the only goroutine writing to c.conn: queued messages and
+// keepalive pings.
+func (b *Broadcaster) writeLoop(c *client) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	defer c.conn.Close()
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				b.remove(c, "write failed")
 				return
 			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				b.remove(c, "ping failed")
+				return
+			}
+		case <-c.done:
+			c.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(time.Second))
+			return
 		}
-	}()
+	}
+}
+
+// remove drops c and closes its connection. Safe to call more than once.
+func (b *Broadcaster) remove(c *client, reason string) {
+	c.closeOnce.Do(func() {
+		b.mu.Lock()
+		delete(b.clients, c)
+		n := len(b.clients)
+		b.mu.Unlock()
+		close(c.done)
+		// Unblock a read or write in progress; writeLoop sends the close frame if it can
+		c.conn.SetReadDeadline(time.Now())
+		b.log.Info("dashboard client disconnected", "remote", c.remote, "reason", reason, "clients", n)
+	})
+}
+
+// Close disconnects every client.
+func (b *Broadcaster) Close() {
+	b.mu.RLock()
+	clients := make([]*client, 0, len(b.clients))
+	for c := range b.clients {
+		clients = append(clients, c)
+	}
+	b.mu.RUnlock()
+	for _, c := range clients {
+		b.remove(c, "router stopping")
+	}
 }
 
 // ClusterState is the JSON payload pushed to the dashboard.
@@ -61,36 +179,106 @@ type ClusterState struct {
 	Workers             []WorkerState    `json:"workers"`
 	RoutingDistribution map[string]int64 `json:"routing_distribution"`
 	TotalRequests       int64            `json:"total_requests"`
+	DashboardClients    int              `json:"dashboard_clients"`
+	Action              *AdminAction     `json:"action,omitempty"` // operator change that triggered this push
 }
 
 type WorkerState struct {
-	ID             string  `json:"id"`
-	Address        string  `json:"address"`
-	Score          float64 `json:"score"`
+	ID             string   `json:"id"`
+	Address        string   `json:"address"`
+	Score          float64  `json:"score"`
+	VRAMFreeGB     float64  `json:"vram_free_gb"`
+	VRAMTotalGB    float64  `json:"vram_total_gb"`
+	GPUUtilization float64  `json:"gpu_utilization"`
+	TemperatureC   float64  `json:"temperature_c"`
+	QueueDepth     int32    `json:"queue_depth"`
+	EstimatedQueue int32    `json:"estimated_queue"` // queue_depth plus requests routed since the report
+	InFlight       int64    `json:"in_flight"`       // requests this router has outstanding on the worker
+	AvgLatencyMs   float64  `json:"avg_latency_ms"`
+	CurrentBatch   int32    `json:"current_batch"`
+	Healthy        bool     `json:"healthy"`
+	MetricsSource  string   `json:"metrics_source"` // "nvml" or "simulated"
+	PowerDrawW     float64  `json:"power_draw_w"`
+	PowerLimitW    float64  `json:"power_limit_w"`
+	SMClockMHz     float64  `json:"sm_clock_mhz"`
+	Throttling     []string `json:"throttling,omitempty"` // active NVML throttle reasons
+	ECCUncorrected uint64   `json:"ecc_uncorrected"`
+	ProcessVRAMGB  float64  `json:"process_vram_gb"` // held by the worker process itself
+	DeviceFreeGB   float64  `json:"device_vram_free_gb"`
+	GPUShare       float64  `json:"gpu_share"`
+	MetricsAgeMs   int64    `json:"metrics_age_ms"`     // -1 if the worker has never reported
+	MetricsUpdated int64    `json:"metrics_updated_at"` // unix ms, 0 if never
+	Stale          bool     `json:"stale"`
+	Restarts       int      `json:"restarts"`
+	Cordoned       bool     `json:"cordoned"`
+	Draining       bool     `json:"draining"`
+	Drained        bool     `json:"drained"` // draining and no requests left in flight
+	Weight         float64  `json:"weight"`
+	Source         string   `json:"source"`           // "config" or "registered"
+	Models         []string `json:"models,omitempty"` // registered workers: models served (empty: any)
+
+	Devices []DeviceState `json:"devices,omitempty"` // per-GPU breakdown (multi-GPU workers)
+}
+
+type DeviceState struct {
+	Index          int32   `json:"index"`
+	Executor       string  `json:"executor"`
 	VRAMFreeGB     float64 `json:"vram_free_gb"`
 	VRAMTotalGB    float64 `json:"vram_total_gb"`
 	GPUUtilization float64 `json:"gpu_utilization"`
 	TemperatureC   float64 `json:"temperature_c"`
-	QueueDepth     int32   `json:"queue_depth"`
-	AvgLatencyMs   float64 `json:"avg_latency_ms"`
 	CurrentBatch   int32   `json:"current_batch"`
-	Healthy        bool    `json:"healthy"`
 }
 
-// Broadcast sends the cluster state to all connected WebSocket clients.
-func (b *Broadcaster) Broadcast(state *ClusterState) {
-	data, err := json.Marshal(state)
+// Publish sends each client what it is due of state and events: the full
+// state for clients on the original protocol, otherwise a snapshot or
+// delta on its topics. Clients whose queue is full are evicted rather
+// than waited on.
+func (b *Broadcaster) Publish(state *ClusterState, events []Event) {
+	recent := b.remember(events)
+	if b.ClientCount() == 0 {
+		return
+	}
+	f, err := newFrame(state, events, recent)
 	if err != nil {
+		b.log.Warn("cluster state marshal failed", "err", err)
 		return
 	}
 
+	var slow []*client
 	b.mu.RLock()
-	defer b.mu.RUnlock()
-
-	for conn := range b.clients {
-		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-			conn.Close()
-			delete(b.clients, conn)
+	for c := range b.clients {
+		data, kind, err := c.sub.message(f)
+		if err != nil {
+			b.log.Warn("dashboard update marshal failed", "err", err)
+			continue
+		}
+		if data == nil {
+			continue
+		}
+		select {
+		case c.send <- data:
+			b.tel.dashboardBytes.With(kind).Add(float64(len(data)))
+		default:
+			slow = append(slow, c)
 		}
 	}
+	b.mu.RUnlock()
+
+	for _, c := range slow {
+		b.tel.dashboardEvictions.With().Inc()
+		b.log.Warn("evicting slow dashboard client", "remote", c.remote, "queued", len(c.send))
+		b.remove(c, "too slow")
+	}
+}
+
+// remember adds events to the replay buffer and returns a copy of it.
+func (b *Broadcaster) remember(events []Event) []Event {
+	b.recentMu.Lock()
+	defer b.recentMu.Unlock()
+	b.recent = append(b.recent, events...)
+	if over := len(b.recent) - recentEvents; over > 0 {
+		b.recent = slices.Delete(b.recent, 0, over)
+	}
+	return slices.Clone(b.recent)
 }
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kunal/gpu-batch-router/pkg/config"
+)
+
+// Resolver is the part of *net.Resolver that discovery uses, so tests can
+// substitute a stub.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Discovery turns a DNS name into worker addresses. In "dns" mode the name
+// is host:port and every A/AAAA record of host (the pods behind a headless
+// Service) becomes ip:port. In "srv" mode the name is a full SRV name such
+// as _grpc._tcp.workers, and each record becomes target:port, which for a
+// StatefulSet is the pod's stable hostname.
+type Discovery struct {
+	mode     string
+	name     string
+	resolver Resolver
+}
+
+// NewDiscovery returns a Discovery for mode and name, resolving through
+// resolver (net.DefaultResolver if nil).
+func NewDiscovery(mode, name string, resolver Resolver) *Discovery {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &Discovery{mode: mode, name: name, resolver: resolver}
+}
+
+// Lookup returns the sorted worker addresses the name resolves to. An
+// empty answer is an error: it's more often a DNS hiccup than every
+// worker going away, and draining them all on one would be worse.
+func (d *Discovery) Lookup(ctx context.Context) ([]string, error) {
+	var addrs []string
+	switch d.mode {
+	case "dns":
+		host, port, err := net.SplitHostPort(d.name)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := d.resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip, port))
+		}
+	case "srv":
+		_, records, err := d.resolver.LookupSRV(ctx, "", "", d.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			host := strings.TrimSuffix(rec.Target, ".")
+			addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(int(rec.Port))))
+		}
+	default:
+		return nil, fmt.Errorf("unknown discovery mode %q", d.mode)
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no records")
+	}
+	slices.Sort(addrs)
+	return slices.Compact(addrs), nil
+}
+
+// discover looks the worker name up every interval and reconciles the
+// registry with the answer, until the router stops. A failed lookup leaves
+// the workers as they are.
+func (r *Router) discover(d *Discovery, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	log := r.log.With("mode", d.mode, "name", d.name)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		addrs, err := d.Lookup(ctx)
+		cancel()
+		if err != nil {
+			r.tel.discoveryLookups.With("error").Inc()
+			log.Warn("worker discovery failed; keeping the current workers", "err", err)
+		} else {
+			r.tel.discoveryLookups.With("ok").Inc()
+			r.syncDiscovered(addrs)
+		}
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncDiscovered adds discovered workers the registry doesn't know and
+// drains discovered workers that are no longer in addrs. Addresses already
+// known from the config or registration are left to their own source; one
+// still draining from an earlier removal is added back once it's gone.
+func (r *Router) syncDiscovered(addrs []string) {
+	want := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		want[addr] = true
+		if r.registry.Get(addr) == nil {
+			r.addWorker(config.WorkerConfig{Address: addr, Weight: 1}, SourceDiscovered)
+		}
+	}
+	cfg := r.cfg.Load()
+	for _, addr := range r.registry.BySource(SourceDiscovered) {
+		if !want[addr] {
+			r.log.Info("worker no longer in DNS, removing", "worker", addr)
+			go r.removeWorker(addr, cfg.Retries.ForwardTimeout)
+		}
+	}
+}
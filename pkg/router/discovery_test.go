@@ -0,0 +1,170 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubResolver answers lookups from fixed records, which tests may swap
+// while discovery is running.
+type stubResolver struct {
+	mu    sync.Mutex
+	hosts map[string][]string
+	srv   map[string][]*net.SRV
+	err   error
+}
+
+func (s *stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hosts[host], s.err
+}
+
+func (s *stubResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return name, s.srv[name], s.err
+}
+
+func (s *stubResolver) setHosts(host string, addrs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hosts = map[string][]string{host: addrs}
+}
+
+func TestDiscoveryLookup(t *testing.T) {
+	res := &stubResolver{
+		hosts: map[string][]string{
+			"workers": {"10.0.0.2", "10.0.0.1", "fd00::1", "10.0.0.2"},
+		},
+		srv: map[string][]*net.SRV{
+			"_grpc._tcp.workers": {
+				{Target: "worker-1.workers.default.svc.cluster.local.", Port: 50052},
+				{Target: "worker-0.workers.default.svc.cluster.local.", Port: 50052},
+				{Target: "worker-0.workers.default.svc.cluster.local.", Port: 50052},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		mode    string
+		lookup  string
+		res     Resolver
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "a and aaaa records, sorted and deduped", mode: "dns", lookup: "workers:50052", res: res,
+			want: []string{"10.0.0.1:50052", "10.0.0.2:50052", "[fd00::1]:50052"},
+		},
+		{
+			name: "srv records with their port, sorted and deduped", mode: "srv", lookup: "_grpc._tcp.workers", res: res,
+			want: []string{
+				"worker-0.workers.default.svc.cluster.local:50052",
+				"worker-1.workers.default.svc.cluster.local:50052",
+			},
+		},
+		{name: "empty a answer", mode: "dns", lookup: "nothing:50052", res: res, wantErr: true},
+		{name: "empty srv answer", mode: "srv", lookup: "_grpc._tcp.nothing", res: res, wantErr: true},
+		{name: "lookup error", mode: "dns", lookup: "workers:50052", res: &stubResolver{err: errors.New("servfail")}, wantErr: true},
+		{name: "name without a port", mode: "dns", lookup: "workers", res: res, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewDiscovery(tt.mode, tt.lookup, tt.res).Lookup(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Lookup: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncDiscovered(t *testing.T) {
+	r := newTestRouter(t, map[string]string{
+		"WORKER_ENDPOINTS":   "127.0.0.1:1",
+		"DISCOVERY_MODE":     "dns",
+		"DISCOVERY_NAME":     "workers:2",
+		"FORWARD_TIMEOUT_MS": "100",
+	})
+	defer r.Stop()
+
+	r.syncDiscovered([]string{"127.0.0.1:1", "127.0.0.2:2", "127.0.0.3:2"})
+	discovered := func() []string {
+		addrs := r.registry.BySource(SourceDiscovered)
+		slices.Sort(addrs)
+		return addrs
+	}
+	if got, want := discovered(), []string{"127.0.0.2:2", "127.0.0.3:2"}; !slices.Equal(got, want) {
+		t.Fatalf("discovered = %v, want %v", got, want)
+	}
+	if got := sourceOf(r, "127.0.0.1:1"); got != SourceConfig {
+		t.Errorf("config worker source = %q, want %q", got, SourceConfig)
+	}
+
+	// 127.0.0.2 leaves the answer; a new one joins
+	r.syncDiscovered([]string{"127.0.0.3:2", "127.0.0.4:2"})
+	eventually(t, "the dropped worker to be removed", func() bool { return r.registry.Get("127.0.0.2:2") == nil })
+	if got, want := discovered(), []string{"127.0.0.3:2", "127.0.0.4:2"}; !slices.Equal(got, want) {
+		t.Errorf("discovered = %v, want %v", got, want)
+	}
+	if r.registry.Get("127.0.0.1:1") == nil {
+		t.Error("the config worker was removed though it isn't discovered")
+	}
+}
+
+func TestDiscoverLoop(t *testing.T) {
+	r := newTestRouter(t, map[string]string{
+		"WORKER_ENDPOINTS":   "",
+		"DISCOVERY_MODE":     "dns",
+		"DISCOVERY_NAME":     "workers:2",
+		"FORWARD_TIMEOUT_MS": "100",
+	})
+	res := &stubResolver{}
+	res.setHosts("workers", "127.0.0.2", "127.0.0.3")
+
+	done := make(chan struct{})
+	go func() {
+		r.discover(NewDiscovery("dns", "workers:2", res), 10*time.Millisecond)
+		close(done)
+	}()
+	eventually(t, "both workers to be discovered", func() bool {
+		return len(r.registry.BySource(SourceDiscovered)) == 2
+	})
+
+	// A failing lookup keeps them
+	res.mu.Lock()
+	res.err = errors.New("servfail")
+	res.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if got := len(r.registry.BySource(SourceDiscovered)); got != 2 {
+		t.Fatalf("after a failed lookup, %d workers left, want 2", got)
+	}
+
+	res.mu.Lock()
+	res.err = nil
+	res.mu.Unlock()
+	res.setHosts("workers", "127.0.0.3")
+	eventually(t, "the dropped worker to be removed", func() bool { return r.registry.Get("127.0.0.2:2") == nil })
+
+	r.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("discovery loop still running after Stop")
+	}
+}
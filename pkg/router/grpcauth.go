@@ -0,0 +1,405 @@
+package router
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/config"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// jwtLeeway absorbs clock skew between the token issuer and the router.
+const jwtLeeway = 30 * time.Second
+
+// Tenant is an API client of the router, as configured in AUTH_TENANTS_FILE.
+type Tenant struct {
+	Name        string
+	Models      map[string]bool // allowed models; empty allows any
+	MaxPriority pb.Priority
+}
+
+// tenantsFile is the AUTH_TENANTS_FILE format:
+//
+//	{"tenants": [{"name": "acme", "api_keys": ["sha256:<hex>"], "models": ["resnet50"], "max_priority": "MEDIUM"}]}
+//
+// Keys may be plaintext or "sha256:<hex>". No models means any model; no
+// max_priority means HIGH.
+type tenantsFile struct {
+	Tenants []struct {
+		Name        string   `json:"name"`
+		APIKeys     []string `json:"api_keys"`
+		Models      []string `json:"models"`
+		MaxPriority string   `json:"max_priority"`
+	} `json:"tenants"`
+}
+
+type apiKey struct {
+	cred   credential
+	tenant *Tenant
+}
+
+// apiAuth authenticates gRPC callers. A call carries an API key in
+// "x-api-key" or "authorization: Bearer <key>", or a JWT in the latter.
+// JWTs are HS256 (JWT_HS256_SECRET) or RS256 (JWT_RS256_PUBLIC_KEY_FILE),
+// must not be expired, and name their tenant in the JWT_TENANT_CLAIM claim.
+type apiAuth struct {
+	tenants     map[string]*Tenant
+	keys        []apiKey
+	hmacKey     []byte
+	rsaKey      *rsa.PublicKey
+	issuer      string
+	audience    string
+	tenantClaim string
+	tel         *telemetry
+	log         *slog.Logger
+}
+
+// newAPIAuth loads the tenants file. It returns nil if gRPC auth is off
+// (no AUTH_TENANTS_FILE).
+func newAPIAuth(cfg *config.Config, tel *telemetry) (*apiAuth, error) {
+	if cfg.AuthTenantsFile == "" {
+		if cfg.JWTSecret != "" || cfg.JWTPublicKeyFile != "" {
+			return nil, errors.New("JWT verification needs AUTH_TENANTS_FILE to map tokens to tenants")
+		}
+		return nil, nil
+	}
+	data, err := os.ReadFile(cfg.AuthTenantsFile)
+	if err != nil {
+		return nil, err
+	}
+	var file tenantsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.AuthTenantsFile, err)
+	}
+
+	a := &apiAuth{
+		tenants:     make(map[string]*Tenant),
+		hmacKey:     []byte(cfg.JWTSecret),
+		issuer:      cfg.JWTIssuer,
+		audience:    cfg.JWTAudience,
+		tenantClaim: cfg.JWTTenantClaim,
+		tel:         tel,
+		log:         logging.For("grpcauth"),
+	}
+	seen := make(map[string]string) // key -> tenant, to catch reuse
+	for i, tf := range file.Tenants {
+		if tf.Name == "" {
+			return nil, fmt.Errorf("tenant %d: missing name", i+1)
+		}
+		if _, dup := a.tenants[tf.Name]; dup {
+			return nil, fmt.Errorf("tenant %s: defined twice", tf.Name)
+		}
+		t := &Tenant{Name: tf.Name, Models: make(map[string]bool), MaxPriority: pb.Priority_HIGH}
+		for _, m := range tf.Models {
+			t.Models[m] = true
+		}
+		if tf.MaxPriority != "" {
+			p, ok := pb.Priority_value[strings.ToUpper(tf.MaxPriority)]
+			if !ok {
+				return nil, fmt.Errorf("tenant %s: unknown max_priority %q (want LOW, MEDIUM or HIGH)", tf.Name, tf.MaxPriority)
+			}
+			t.MaxPriority = pb.Priority(p)
+		}
+		for j, k := range tf.APIKeys {
+			if other, dup := seen[k]; dup {
+				return nil, fmt.Errorf("tenant %s: API key %d is also used by %s", tf.Name, j+1, other)
+			}
+			seen[k] = tf.Name
+			cred, err := parseCredential(k, roleNone)
+			if err != nil {
+				return nil, fmt.Errorf("tenant %s: API key %d: %w", tf.Name, j+1, err)
+			}
+			a.keys = append(a.keys, apiKey{cred: cred, tenant: t})
+		}
+		a.tenants[t.Name] = t
+	}
+	if len(a.tenants) == 0 {
+		return nil, fmt.Errorf("%s: no tenants defined", cfg.AuthTenantsFile)
+	}
+
+	if cfg.JWTPublicKeyFile != "" {
+		if a.rsaKey, err = loadRSAPublicKey(cfg.JWTPublicKeyFile); err != nil {
+			return nil, fmt.Errorf("JWT_RS256_PUBLIC_KEY_FILE: %w", err)
+		}
+	}
+	a.log.Info("gRPC auth enabled",
+		"tenants", len(a.tenants),
+		"api_keys", len(a.keys),
+		"jwt_hs256", len(a.hmacKey) > 0,
+		"jwt_rs256", a.rsaKey != nil)
+	return a, nil
+}
+
+func loadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var key any
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA public key (%T)", key)
+	}
+	return rsaKey, nil
+}
+
+// authenticate finds the tenant making the call.
+func (a *apiAuth) authenticate(ctx context.Context) (*Tenant, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := first(md, "x-api-key")
+	if token == "" {
+		if bearer, ok := strings.CutPrefix(first(md, "authorization"), "Bearer "); ok {
+			token = strings.TrimSpace(bearer)
+		}
+	}
+	if token == "" {
+		return nil, a.reject(ctx, codes.Unauthenticated, "missing", "", "missing credentials: send x-api-key or authorization: Bearer")
+	}
+
+	if strings.Count(token, ".") == 2 && (len(a.hmacKey) > 0 || a.rsaKey != nil) {
+		name, err := a.verifyJWT(token)
+		if err != nil {
+			reason := "invalid_token"
+			if errors.Is(err, errTokenExpired) {
+				reason = "expired"
+			}
+			return nil, a.reject(ctx, codes.Unauthenticated, reason, "", "invalid token: "+err.Error())
+		}
+		t, ok := a.tenants[name]
+		if !ok {
+			return nil, a.reject(ctx, codes.PermissionDenied, "unknown_tenant", name, "unknown tenant "+name)
+		}
+		return t, nil
+	}
+
+	for _, k := range a.keys {
+		if k.cred.matches(token) {
+			return k.tenant, nil
+		}
+	}
+	return nil, a.reject(ctx, codes.Unauthenticated, "invalid_key", "", "invalid API key")
+}
+
+// authorize checks a request message against what t may do.
+func (a *apiAuth) authorize(ctx context.Context, t *Tenant, msg any) error {
+	req, ok := msg.(*pb.InferRequest)
+	if !ok {
+		return nil
+	}
+	if len(t.Models) > 0 && !t.Models[req.ModelName] {
+		return a.reject(ctx, codes.PermissionDenied, "model", t.Name,
+			fmt.Sprintf("tenant %s may not use model %q", t.Name, req.ModelName))
+	}
+	if req.Priority > t.MaxPriority {
+		return a.reject(ctx, codes.PermissionDenied, "priority", t.Name,
+			fmt.Sprintf("tenant %s may not send %s priority requests (max %s)", t.Name, req.Priority, t.MaxPriority))
+	}
+	return nil
+}
+
+func (a *apiAuth) reject(ctx context.Context, code codes.Code, reason, tenant, msg string) error {
+	a.tel.authRejections.With(reason).Inc()
+	remote := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+	a.log.InfoContext(ctx, "rejected gRPC call", "reason", reason, "tenant", tenant, "remote", remote)
+	return status.Error(code, msg)
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+var errTokenExpired = errors.New("token expired")
+
+// verifyJWT checks a compact JWS and its registered claims, returning the
+// tenant it names.
+func (a *apiAuth) verifyJWT(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", fmt.Errorf("header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("signature: %w", err)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	// The algorithm must be one we were configured for; trusting the
+	// header alone would let "none" or HS256-with-the-public-key through.
+	switch {
+	case header.Alg == "HS256" && len(a.hmacKey) > 0:
+		mac := hmac.New(sha256.New, a.hmacKey)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return "", errors.New("bad signature")
+		}
+	case header.Alg == "RS256" && a.rsaKey != nil:
+		sum := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(a.rsaKey, crypto.SHA256, sum[:], sig); err != nil {
+			return "", errors.New("bad signature")
+		}
+	default:
+		return "", fmt.Errorf("algorithm %q not accepted", header.Alg)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("claims: %w", err)
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return "", errors.New("missing exp")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return "", errTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return "", errors.New("token not valid yet")
+	}
+	if a.issuer != "" && claims["iss"] != a.issuer {
+		return "", fmt.Errorf("issuer %v not accepted", claims["iss"])
+	}
+	if a.audience != "" && !hasAudience(claims["aud"], a.audience) {
+		return "", errors.New("audience not accepted")
+	}
+	tenant, _ := claims[a.tenantClaim].(string)
+	if tenant == "" {
+		return "", fmt.Errorf("missing %s claim", a.tenantClaim)
+	}
+	return tenant, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// hasAudience reports whether an aud claim, a string or a list of them, includes want.
+func hasAudience(aud any, want string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == want
+	case []any:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type tenantKey struct{}
+
+// TenantFromContext returns the tenant that made a gRPC call, or nil if
+// gRPC auth is off.
+func TenantFromContext(ctx context.Context) *Tenant {
+	t, _ := ctx.Value(tenantKey{}).(*Tenant)
+	return t
+}
+
+func withTenant(ctx context.Context, t *Tenant) context.Context {
+	ctx = context.WithValue(ctx, tenantKey{}, t)
+	return logging.With(ctx, "tenant", t.Name)
+}
+
+// UnaryAuthInterceptor rejects calls without valid credentials, or whose
+// request the caller's tenant may not make. It passes everything through
+// when gRPC auth is off, and worker registration calls always: those
+// check the registration token themselves.
+func (r *Router) UnaryAuthInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if r.apiAuth == nil || strings.HasPrefix(info.FullMethod, registrationMethods) {
+			return handler(ctx, req)
+		}
+		t, err := r.apiAuth.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.apiAuth.authorize(ctx, t, req); err != nil {
+			return nil, err
+		}
+		return handler(withTenant(ctx, t), req)
+	}
+}
+
+// StreamAuthInterceptor authenticates a stream when it opens and checks
+// each message received on it.
+func (r *Router) StreamAuthInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if r.apiAuth == nil {
+			return handler(srv, ss)
+		}
+		t, err := r.apiAuth.authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authStream{ServerStream: ss, ctx: withTenant(ss.Context(), t), auth: r.apiAuth, tenant: t})
+	}
+}
+
+type authStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	auth   *apiAuth
+	tenant *Tenant
+}
+
+func (s *authStream) Context() context.Context { return s.ctx }
+
+func (s *authStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.auth.authorize(s.ServerStream.Context(), s.tenant, m)
+}
@@ -0,0 +1,258 @@
+package router
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testHMACSecret = "hs256-secret"
+	testAPIKey     = "acme-key"
+	testHashedKey  = "ops-key"
+)
+
+var testTenants = `{"tenants": [
+	{"name": "acme", "api_keys": ["` + testAPIKey + `"], "models": ["resnet50"], "max_priority": "MEDIUM"},
+	{"name": "ops", "api_keys": ["sha256:` + sha256Hex(testHashedKey) + `"]}
+]}`
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAuthRouter builds a router with gRPC auth from testTenants, plus env.
+// JWT settings not in env are cleared.
+func newAuthRouter(t *testing.T, env map[string]string) *Router {
+	t.Helper()
+	for _, k := range []string{"JWT_HS256_SECRET", "JWT_RS256_PUBLIC_KEY_FILE", "JWT_ISSUER", "JWT_AUDIENCE"} {
+		if _, ok := env[k]; !ok {
+			env[k] = ""
+		}
+	}
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte(testTenants), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env["AUTH_TENANTS_FILE"] = path
+	env["WORKER_ENDPOINTS"] = "127.0.0.1:1"
+	r := newTestRouter(t, env)
+	t.Cleanup(r.Stop)
+	return r
+}
+
+// writeRSAPublicKey writes key's public half as PEM and returns the file
+// and its contents.
+func writeRSAPublicKey(t *testing.T, key *rsa.PrivateKey) (string, []byte) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+// makeJWT returns a compact JWS over claims. key is an HMAC secret for
+// HS256 and an *rsa.PrivateKey for RS256; other algorithms are unsigned.
+func makeJWT(t *testing.T, alg string, key any, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := enc(map[string]string{"alg": alg, "typ": "JWT"}) + "." + enc(claims)
+	var sig []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "RS256":
+		sum := sha256.Sum256([]byte(signed))
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, sum[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifyJWT(t *testing.T) {
+	rsaKey := mustRSAKey(t)
+	keyFile, publicPEM := writeRSAPublicKey(t, rsaKey)
+	both := newAuthRouter(t, map[string]string{
+		"JWT_HS256_SECRET":          testHMACSecret,
+		"JWT_RS256_PUBLIC_KEY_FILE": keyFile,
+		"JWT_ISSUER":                "https://issuer.example",
+		"JWT_AUDIENCE":              "gpu-router",
+	}).apiAuth
+	rsaOnly := newAuthRouter(t, map[string]string{"JWT_RS256_PUBLIC_KEY_FILE": keyFile}).apiAuth
+
+	now := time.Now()
+	claims := func(change func(c map[string]any)) map[string]any {
+		c := map[string]any{
+			"tenant": "acme",
+			"iss":    "https://issuer.example",
+			"aud":    "gpu-router",
+			"exp":    now.Add(time.Hour).Unix(),
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+	hs := func(change func(c map[string]any)) string {
+		return makeJWT(t, "HS256", []byte(testHMACSecret), claims(change))
+	}
+	tampered := func() string {
+		parts := strings.Split(hs(nil), ".")
+		parts[1] = strings.Split(hs(func(c map[string]any) { c["tenant"] = "ops" }), ".")[1]
+		return strings.Join(parts, ".")
+	}
+
+	tests := []struct {
+		name    string
+		auth    *apiAuth
+		token   string
+		want    string // tenant
+		wantErr string // substring of the error, if one is expected
+	}{
+		{name: "hs256", auth: both, token: hs(nil), want: "acme"},
+		{name: "rs256", auth: both, token: makeJWT(t, "RS256", rsaKey, claims(nil)), want: "acme"},
+		{name: "audience list", auth: both, token: hs(func(c map[string]any) { c["aud"] = []string{"other", "gpu-router"} }), want: "acme"},
+		{name: "expired within the leeway", auth: both, token: hs(func(c map[string]any) { c["exp"] = now.Add(-10 * time.Second).Unix() }), want: "acme"},
+
+		// Algorithm confusion: the RSA public key is no secret, so an HS256
+		// token keyed with it must not pass where only RS256 is configured
+		{name: "hs256 keyed with the rsa public key", auth: rsaOnly, token: makeJWT(t, "HS256", publicPEM, claims(nil)), wantErr: `algorithm "HS256" not accepted`},
+		{name: "alg none", auth: both, token: makeJWT(t, "none", nil, claims(nil)), wantErr: `algorithm "none" not accepted`},
+		{name: "unknown alg", auth: both, token: makeJWT(t, "HS512", nil, claims(nil)), wantErr: `algorithm "HS512" not accepted`},
+
+		{name: "expired", auth: both, token: hs(func(c map[string]any) { c["exp"] = now.Add(-time.Hour).Unix() }), wantErr: errTokenExpired.Error()},
+		{name: "missing exp", auth: both, token: hs(func(c map[string]any) { delete(c, "exp") }), wantErr: "missing exp"},
+		{name: "nbf in the future", auth: both, token: hs(func(c map[string]any) { c["nbf"] = now.Add(time.Hour).Unix() }), wantErr: "not valid yet"},
+		{name: "wrong aud", auth: both, token: hs(func(c map[string]any) { c["aud"] = "other" }), wantErr: "audience not accepted"},
+		{name: "wrong iss", auth: both, token: hs(func(c map[string]any) { c["iss"] = "https://evil.example" }), wantErr: "issuer"},
+		{name: "no tenant claim", auth: both, token: hs(func(c map[string]any) { delete(c, "tenant") }), wantErr: "missing tenant claim"},
+
+		{name: "wrong hmac secret", auth: both, token: makeJWT(t, "HS256", []byte("guess"), claims(nil)), wantErr: "bad signature"},
+		{name: "claims changed after signing", auth: both, token: tampered(), wantErr: "bad signature"},
+		{name: "rs256 from another key", auth: both, token: makeJWT(t, "RS256", mustRSAKey(t), claims(nil)), wantErr: "bad signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.auth.verifyJWT(tt.token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %q, %v; want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyJWT: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("tenant = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func mustRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := newAuthRouter(t, map[string]string{"JWT_HS256_SECRET": testHMACSecret}).apiAuth
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want string // tenant
+		code codes.Code
+	}{
+		{name: "x-api-key", md: metadata.Pairs("x-api-key", testAPIKey), want: "acme"},
+		{name: "bearer api key", md: metadata.Pairs("authorization", "Bearer "+testAPIKey), want: "acme"},
+		{name: "hashed api key", md: metadata.Pairs("x-api-key", testHashedKey), want: "ops"},
+		{name: "jwt", md: metadata.Pairs("authorization", "Bearer "+makeJWT(t, "HS256", []byte(testHMACSecret), map[string]any{"tenant": "ops", "exp": exp})), want: "ops"},
+
+		{name: "no credentials", md: metadata.MD{}, code: codes.Unauthenticated},
+		{name: "unknown api key", md: metadata.Pairs("x-api-key", "guess"), code: codes.Unauthenticated},
+		{name: "the hash of a key", md: metadata.Pairs("x-api-key", "sha256:"+sha256Hex(testHashedKey)), code: codes.Unauthenticated},
+		{name: "basic auth", md: metadata.Pairs("authorization", "Basic "+testAPIKey), code: codes.Unauthenticated},
+		{name: "bad jwt", md: metadata.Pairs("authorization", "Bearer "+makeJWT(t, "none", nil, map[string]any{"tenant": "ops", "exp": exp})), code: codes.Unauthenticated},
+		{name: "jwt for an unknown tenant", md: metadata.Pairs("authorization", "Bearer "+makeJWT(t, "HS256", []byte(testHMACSecret), map[string]any{"tenant": "nobody", "exp": exp})), code: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant, err := a.authenticate(metadata.NewIncomingContext(context.Background(), tt.md))
+			if tt.code != codes.OK {
+				if status.Code(err) != tt.code {
+					t.Fatalf("got %v, want %v", err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authenticate: %v", err)
+			}
+			if tenant.Name != tt.want {
+				t.Errorf("tenant = %q, want %q", tenant.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	a := newAuthRouter(t, map[string]string{}).apiAuth
+	acme := a.tenants["acme"]
+
+	tests := []struct {
+		name string
+		req  *pb.InferRequest
+		code codes.Code
+	}{
+		{"allowed", &pb.InferRequest{ModelName: "resnet50", Priority: pb.Priority_MEDIUM}, codes.OK},
+		{"other model", &pb.InferRequest{ModelName: "bert", Priority: pb.Priority_LOW}, codes.PermissionDenied},
+		{"above max priority", &pb.InferRequest{ModelName: "resnet50", Priority: pb.Priority_HIGH}, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := a.authorize(context.Background(), acme, tt.req); status.Code(err) != tt.code {
+				t.Errorf("got %v, want %v", err, tt.code)
+			}
+		})
+	}
+}
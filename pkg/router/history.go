@@ -0,0 +1,194 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HistorySample is one point in the router's metrics history.
+type HistorySample struct {
+	At          time.Time
+	RequestRate float64 // requests/s received by the router since the previous sample
+	Workers     map[string]WorkerSample
+}
+
+// WorkerSample is a worker's state at one history point.
+type WorkerSample struct {
+	Healthy        bool    `json:"healthy"`
+	Score          float64 `json:"score"`
+	RoutedRate     float64 `json:"routed_rate"` // requests/s served since the previous sample
+	QueueDepth     float64 `json:"queue_depth"`
+	EstimatedQueue float64 `json:"estimated_queue"`
+	GPUUtilization float64 `json:"gpu_utilization"`
+	VRAMUsedGB     float64 `json:"vram_used_gb"`
+	TemperatureC   float64 `json:"temperature_c"`
+	AvgLatencyMs   float64 `json:"avg_latency_ms"`
+}
+
+// History is a fixed-size ring buffer of samples, oldest overwritten first.
+type History struct {
+	mu      sync.RWMutex
+	samples []HistorySample
+	next    int // slot for the next sample
+	full    bool
+
+	interval time.Duration
+}
+
+// NewHistory keeps retention worth of samples taken every interval.
+func NewHistory(retention, interval time.Duration) *History {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	n := max(int(retention/interval), 1)
+	return &History{samples: make([]HistorySample, n), interval: interval}
+}
+
+// Add records a sample.
+func (h *History) Add(s HistorySample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.samples[h.next] = s
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Since returns samples taken at or after from, oldest first.
+func (h *History) Since(from time.Time) []HistorySample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var ordered []HistorySample
+	if h.full {
+		ordered = append(ordered, h.samples[h.next:]...)
+	}
+	ordered = append(ordered, h.samples[:h.next]...)
+
+	i := sort.Search(len(ordered), func(i int) bool { return !ordered[i].At.Before(from) })
+	return ordered[i:]
+}
+
+// Retention is how far back the buffer reaches when full.
+func (h *History) Retention() time.Duration {
+	return time.Duration(len(h.samples)) * h.interval
+}
+
+// historyPoint is one step of an /api/history response. Values are the
+// mean over the samples in the step; healthy is false if the worker was
+// unhealthy at any of them.
+type historyPoint struct {
+	T           int64                   `json:"t"` // unix ms at the start of the step
+	RequestRate float64                 `json:"request_rate"`
+	Workers     map[string]WorkerSample `json:"workers"`
+}
+
+type historyResponse struct {
+	RangeMs    int64          `json:"range_ms"`
+	StepMs     int64          `json:"step_ms"`
+	IntervalMs int64          `json:"interval_ms"`
+	Points     []historyPoint `json:"points"`
+}
+
+// ServeHTTP handles GET /api/history?range=15m&step=10s. range defaults to
+// 15m and is capped at the retention; step defaults to the sample interval
+// and can't be finer than it.
+func (h *History) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rng, err := durationParam(req, "range", 15*time.Minute)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	step, err := durationParam(req, "step", h.interval)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rng = min(rng, h.Retention())
+	step = max(step, h.interval)
+
+	samples := h.Since(time.Now().Add(-rng))
+	resp := historyResponse{
+		RangeMs:    rng.Milliseconds(),
+		StepMs:     step.Milliseconds(),
+		IntervalMs: h.interval.Milliseconds(),
+		Points:     downsample(samples, step),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func durationParam(req *http.Request, name string, def time.Duration) (time.Duration, error) {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: want a positive duration such as 30s or 15m", name, v)
+	}
+	return d, nil
+}
+
+// downsample averages samples into step-aligned buckets.
+func downsample(samples []HistorySample, step time.Duration) []historyPoint {
+	var points []historyPoint
+	var counts map[string]int
+	var n int
+	flush := func() {
+		if n == 0 {
+			return
+		}
+		p := &points[len(points)-1]
+		p.RequestRate /= float64(n)
+		for addr, ws := range p.Workers {
+			c := float64(counts[addr])
+			ws.Score /= c
+			ws.RoutedRate /= c
+			ws.QueueDepth /= c
+			ws.EstimatedQueue /= c
+			ws.GPUUtilization /= c
+			ws.VRAMUsedGB /= c
+			ws.TemperatureC /= c
+			ws.AvgLatencyMs /= c
+			p.Workers[addr] = ws
+		}
+	}
+
+	for _, s := range samples {
+		t := s.At.Truncate(step).UnixMilli()
+		if len(points) == 0 || points[len(points)-1].T != t {
+			flush()
+			points = append(points, historyPoint{T: t, Workers: make(map[string]WorkerSample)})
+			counts = make(map[string]int)
+			n = 0
+		}
+		p := &points[len(points)-1]
+		p.RequestRate += s.RequestRate
+		n++
+		for addr, ws := range s.Workers {
+			acc, seen := p.Workers[addr]
+			if !seen {
+				acc.Healthy = true
+			}
+			acc.Healthy = acc.Healthy && ws.Healthy
+			acc.Score += ws.Score
+			acc.RoutedRate += ws.RoutedRate
+			acc.QueueDepth += ws.QueueDepth
+			acc.EstimatedQueue += ws.EstimatedQueue
+			acc.GPUUtilization += ws.GPUUtilization
+			acc.VRAMUsedGB += ws.VRAMUsedGB
+			acc.TemperatureC += ws.TemperatureC
+			acc.AvgLatencyMs += ws.AvgLatencyMs
+			p.Workers[addr] = acc
+			counts[addr]++
+		}
+	}
+	flush()
+	return points
+}
@@ -0,0 +1,163 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHistoryWraparound(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(i int) time.Time { return base.Add(time.Duration(i) * time.Second) }
+
+	tests := []struct {
+		name  string
+		added int // samples, one a second from base
+		since int
+		want  []int // seconds after base of the samples returned
+	}{
+		{"empty", 0, 0, nil},
+		{"partly full", 3, 0, []int{0, 1, 2}},
+		{"exactly full", 5, 0, []int{0, 1, 2, 3, 4}},
+		{"wrapped once", 7, 0, []int{2, 3, 4, 5, 6}},
+		{"wrapped to the start", 10, 0, []int{5, 6, 7, 8, 9}},
+		{"since inside the buffer", 7, 4, []int{4, 5, 6}},
+		{"since before the oldest kept", 7, 1, []int{2, 3, 4, 5, 6}},
+		{"since after the newest", 7, 9, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistory(5*time.Second, time.Second)
+			for i := range tt.added {
+				h.Add(HistorySample{At: at(i), RequestRate: float64(i)})
+			}
+			got := h.Since(at(tt.since))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d samples, want %d", len(got), len(tt.want))
+			}
+			for i, s := range got {
+				if !s.At.Equal(at(tt.want[i])) || s.RequestRate != float64(tt.want[i]) {
+					t.Errorf("sample %d is from +%v, want +%ds", i, s.At.Sub(base), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	tests := []struct {
+		retention, interval, want time.Duration
+	}{
+		{time.Hour, time.Second, time.Hour},
+		{10 * time.Second, 3 * time.Second, 9 * time.Second}, // whole samples only
+		{time.Second, 5 * time.Second, 5 * time.Second},      // at least one sample
+		{time.Minute, 0, time.Minute},                        // interval defaults to 1s
+	}
+	for _, tt := range tests {
+		if got := NewHistory(tt.retention, tt.interval).Retention(); got != tt.want {
+			t.Errorf("NewHistory(%v, %v).Retention() = %v, want %v", tt.retention, tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	// Step-aligned buckets start on whole multiples of the step
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	sample := func(offset time.Duration, rate float64, workers map[string]WorkerSample) HistorySample {
+		return HistorySample{At: base.Add(offset), RequestRate: rate, Workers: workers}
+	}
+	w := func(healthy bool, queue float64) WorkerSample {
+		return WorkerSample{Healthy: healthy, QueueDepth: queue, Score: queue * 10}
+	}
+	samples := []HistorySample{
+		// [0s, 10s): the first and last instants of the bucket
+		sample(0, 10, map[string]WorkerSample{"a": w(true, 2), "b": w(true, 4)}),
+		sample(9999*time.Millisecond, 20, map[string]WorkerSample{"a": w(false, 4)}),
+		// [10s, 20s): starts exactly on the boundary
+		sample(10*time.Second, 30, map[string]WorkerSample{"a": w(true, 6)}),
+		// [20s, 30s) is empty; [30s, 40s)
+		sample(35*time.Second, 40, map[string]WorkerSample{"b": w(true, 8)}),
+	}
+	points := downsample(samples, 10*time.Second)
+
+	want := []struct {
+		t       time.Duration
+		rate    float64
+		workers map[string]WorkerSample
+	}{
+		{0, 15, map[string]WorkerSample{
+			"a": {Healthy: false, QueueDepth: 3, Score: 30}, // unhealthy once is unhealthy
+			"b": {Healthy: true, QueueDepth: 4, Score: 40},  // averaged over its own samples
+		}},
+		{10 * time.Second, 30, map[string]WorkerSample{"a": w(true, 6)}},
+		{30 * time.Second, 40, map[string]WorkerSample{"b": w(true, 8)}},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("got %d points, want %d: %+v", len(points), len(want), points)
+	}
+	for i, p := range points {
+		if p.T != base.Add(want[i].t).UnixMilli() {
+			t.Errorf("point %d at +%dms, want +%v", i, p.T-base.UnixMilli(), want[i].t)
+		}
+		if p.RequestRate != want[i].rate {
+			t.Errorf("point %d request rate = %v, want %v", i, p.RequestRate, want[i].rate)
+		}
+		if len(p.Workers) != len(want[i].workers) {
+			t.Errorf("point %d workers = %v, want %v", i, p.Workers, want[i].workers)
+		}
+		for addr, ws := range want[i].workers {
+			if p.Workers[addr] != ws {
+				t.Errorf("point %d worker %s = %+v, want %+v", i, addr, p.Workers[addr], ws)
+			}
+		}
+	}
+
+	if got := downsample(nil, time.Second); len(got) != 0 {
+		t.Errorf("no samples gave %d points", len(got))
+	}
+}
+
+func TestHistoryServeHTTP(t *testing.T) {
+	h := NewHistory(time.Minute, time.Second)
+	now := time.Now()
+	for i := range 10 {
+		h.Add(HistorySample{At: now.Add(time.Duration(i-10) * time.Second), RequestRate: 1})
+	}
+
+	tests := []struct {
+		query        string
+		code         int
+		rangeMs      int64
+		stepMs       int64
+		pointsAtMost int
+	}{
+		{"", 200, time.Minute.Milliseconds(), 1000, 10},
+		{"?range=1h&step=5s", 200, time.Minute.Milliseconds(), 5000, 3}, // range capped at retention
+		{"?range=5s&step=100ms", 200, 5000, 1000, 6},                    // step no finer than the interval
+		{"?range=-5s", 400, 0, 0, 0},
+		{"?step=soon", 400, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/history"+tt.query, nil))
+			if rec.Code != tt.code {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if tt.code != 200 {
+				return
+			}
+			var resp historyResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.RangeMs != tt.rangeMs || resp.StepMs != tt.stepMs || resp.IntervalMs != 1000 {
+				t.Errorf("range %d, step %d, interval %d; want %d, %d, 1000", resp.RangeMs, resp.StepMs, resp.IntervalMs, tt.rangeMs, tt.stepMs)
+			}
+			if n := len(resp.Points); n == 0 || n > tt.pointsAtMost {
+				t.Errorf("%d points, want 1 to %d", n, tt.pointsAtMost)
+			}
+		})
+	}
+}
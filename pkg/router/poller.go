@@ -2,85 +2,258 @@ package router
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// Poller periodically fetches metrics from all registered workers.
+// Metrics modes for the poller.
+const (
+	MetricsModeStream = "stream"
+	MetricsModePoll   = "poll"
+)
+
+// streamMinInterval caps how often a worker may push during bursts of change.
+const streamMinInterval = 100 * time.Millisecond
+
+var errStreamStalled = errors.New("metrics stream stalled (no heartbeat)")
+
+// Poller keeps the registry's worker metrics fresh. Each worker gets its
+// own goroutine holding a WatchMetrics stream, reconnecting with backoff
+// when it breaks. Workers that don't implement WatchMetrics (older builds),
+// or every worker in poll mode, are polled with GetMetrics instead.
 type Poller struct {
-	registry *Registry
-	interval time.Duration
-	stopCh   chan struct{}
-	wg       sync.WaitGroup
+	registry  *Registry
+	interval  atomic.Int64 // unary poll interval; see SetInterval
+	stream    bool
+	heartbeat time.Duration // requested push heartbeat for streams
+	tel       *telemetry
+	log       *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	watches map[string]*watch // by worker address
 }
 
-func NewPoller(registry *Registry, interval time.Duration) *Poller {
-	return &Poller{
-		registry: registry,
-		interval: interval,
-		stopCh:   make(chan struct{}),
+// watch is one worker's running stream or polling loop.
+type watch struct {
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the loop has returned
+}
+
+func NewPoller(registry *Registry, interval time.Duration, mode string, heartbeat time.Duration, tel *telemetry) *Poller {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Poller{
+		registry:  registry,
+		stream:    mode != MetricsModePoll,
+		heartbeat: heartbeat,
+		tel:       tel,
+		log:       logging.For("poller"),
+		ctx:       ctx,
+		cancel:    cancel,
+		watches:   make(map[string]*watch),
 	}
+	p.interval.Store(int64(interval))
+	return p
 }
 
-// Start begins the polling loop.
+// Start begins watching every registered worker.
 func (p *Poller) Start() {
-	p.wg.Add(1)
-	go p.loop()
-	log.Printf("📡 Poller started: interval=%v", p.interval)
+	for _, w := range p.registry.GetAll() {
+		p.Watch(w)
+	}
+	p.log.Info("poller started", "stream", p.stream, "heartbeat", p.heartbeat, "poll_interval", p.pollInterval())
 }
 
-// Stop gracefully shuts down the poller.
+// Stop closes all streams and polling loops.
 func (p *Poller) Stop() {
-	close(p.stopCh)
+	p.cancel()
 	p.wg.Wait()
 }
 
-func (p *Poller) loop() {
+// Watch starts keeping entry's metrics fresh, unless it is already
+// watched or has no connection.
+func (p *Poller) Watch(entry *WorkerEntry) {
+	if entry.MetricsClient == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.watches[entry.Address]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	wt := &watch{cancel: cancel, done: make(chan struct{})}
+	p.watches[entry.Address] = wt
+	p.wg.Add(1)
+	go func() {
+		defer close(wt.done)
+		p.watch(ctx, entry)
+	}()
+}
+
+// Unwatch stops the stream or polling loop for the worker at addr and
+// waits for it to return, so it records nothing more for the worker.
+func (p *Poller) Unwatch(addr string) {
+	p.mu.Lock()
+	wt, ok := p.watches[addr]
+	delete(p.watches, addr)
+	p.mu.Unlock()
+	if ok {
+		wt.cancel()
+		<-wt.done
+	}
+}
+
+// SetInterval changes the unary poll interval. Polling loops pick it up
+// after their next poll.
+func (p *Poller) SetInterval(d time.Duration) {
+	p.interval.Store(int64(d))
+}
+
+func (p *Poller) pollInterval() time.Duration { return time.Duration(p.interval.Load()) }
+
+func (p *Poller) watch(ctx context.Context, entry *WorkerEntry) {
 	defer p.wg.Done()
+	if p.stream && !p.streamLoop(ctx, entry) {
+		return
+	}
+	p.pollLoop(ctx, entry)
+}
 
-	ticker := time.NewTicker(p.interval)
-	defer ticker.Stop()
+// streamLoop keeps a WatchMetrics stream open to entry until ctx ends
+// (returns false) or the worker turns out not to support streaming
+// (returns true, so the caller falls back to polling).
+func (p *Poller) streamLoop(ctx context.Context, entry *WorkerEntry) bool {
+	backoff := newBackoff(250*time.Millisecond, 10*time.Second)
+	for {
+		err := p.runStream(ctx, entry, backoff)
+		if ctx.Err() != nil {
+			return false
+		}
+		if status.Code(err) == codes.Unimplemented {
+			p.log.Info("worker does not support WatchMetrics, polling instead", "worker", entry.Address)
+			p.tel.streaming.With(entry.Address).Set(0)
+			return true
+		}
+
+		delay := backoff.next()
+		p.log.Debug("metrics stream lost, reconnecting", "worker", entry.Address, "err", err, "retry_in", delay)
+		p.tel.streaming.With(entry.Address).Set(0)
+		p.tel.streamErrors.With(entry.Address).Inc()
+		p.registry.MarkFailed(entry.Address)
+		if !sleepCtx(ctx, delay) {
+			return false
+		}
+	}
+}
+
+// runStream reads one stream until it fails. A stream that goes quiet for
+// three heartbeats is cancelled, since a half-open tunnel won't error by itself.
+func (p *Poller) runStream(parent context.Context, entry *WorkerEntry, backoff *backoff) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	timeout := 3 * p.heartbeat
+	watchdog := time.AfterFunc(timeout, cancel)
+	defer watchdog.Stop()
+
+	stream, err := entry.MetricsClient.WatchMetrics(ctx, &pb.WatchMetricsRequest{
+		HeartbeatMs:   int32(p.heartbeat.Milliseconds()),
+		MinIntervalMs: int32(streamMinInterval.Milliseconds()),
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		m, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil && parent.Err() == nil {
+				return errStreamStalled
+			}
+			return err
+		}
+		watchdog.Reset(timeout)
+		backoff.reset()
+		p.tel.streaming.With(entry.Address).Set(1)
+		p.registry.UpdateMetrics(entry.Address, m)
+	}
+}
 
-	// Do an immediate first poll
-	p.pollAll()
+// pollLoop fetches metrics with unary GetMetrics every interval.
+func (p *Poller) pollLoop(ctx context.Context, entry *WorkerEntry) {
+	interval := p.pollInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
 	for {
+		p.poll(ctx, entry)
+		if d := p.pollInterval(); d != interval {
+			interval = d
+			ticker.Reset(d)
+		}
 		select {
-		case <-p.stopCh:
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			p.pollAll()
 		}
 	}
 }
 
-func (p *Poller) pollAll() {
-	workers := p.registry.GetAll()
-	var wg sync.WaitGroup
+func (p *Poller) poll(parent context.Context, entry *WorkerEntry) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
 
-	for _, w := range workers {
-		if w.MetricsClient == nil {
-			continue
+	metrics, err := entry.MetricsClient.GetMetrics(ctx, &pb.MetricsRequest{})
+	if err != nil {
+		if parent.Err() != nil {
+			return
 		}
-		wg.Add(1)
-		go func(entry *WorkerEntry) {
-			defer wg.Done()
+		p.log.Debug("metrics poll failed", "worker", entry.Address, "err", err)
+		p.tel.pollFailures.With(entry.Address).Inc()
+		p.registry.MarkFailed(entry.Address)
+		return
+	}
 
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
+	p.registry.UpdateMetrics(entry.Address, metrics)
+}
 
-			metrics, err := entry.MetricsClient.GetMetrics(ctx, &pb.MetricsRequest{})
-			if err != nil {
-				p.registry.MarkFailed(entry.Address)
-				return
-			}
+// backoff is a capped exponential delay with ±20% jitter.
+type backoff struct {
+	base, max, cur time.Duration
+}
 
-			p.registry.UpdateMetrics(entry.Address, metrics)
-		}(w)
-	}
+func newBackoff(base, max time.Duration) *backoff {
+	return &backoff{base: base, max: max, cur: base}
+}
+
+func (b *backoff) next() time.Duration {
+	d := b.cur
+	b.cur = min(b.cur*2, b.max)
+	jitter := (rand.Float64()*0.4 - 0.2) * float64(d)
+	return d + time.Duration(jitter)
+}
+
+func (b *backoff) reset() { b.cur = b.base }
 
-	wg.Wait()
+// sleepCtx waits for d or until ctx is done; it reports whether d elapsed.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
 }
@@ -0,0 +1,157 @@
+package router
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"strings"
+	"time"
+
+	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// registrationMethods prefixes the RegistrationService's gRPC methods.
+// Workers authenticate those with REGISTRATION_TOKEN, not tenant keys.
+var registrationMethods = "/" + pb.RegistrationService_ServiceDesc.ServiceName + "/"
+
+// Register adds a self-registering worker, or refreshes one already known.
+// A worker that comes back under its ID at a new address (a restarted
+// tunnel) replaces its old entry, which is drained and dropped.
+func (r *Router) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	if err := r.checkRegistrationToken(ctx); err != nil {
+		return nil, err
+	}
+	if req.WorkerId == "" {
+		return nil, status.Error(codes.InvalidArgument, "worker_id is required")
+	}
+	if _, _, err := net.SplitHostPort(req.Address); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "address: want host:port, got %q", req.Address)
+	}
+	cfg := r.cfg.Load()
+
+	for _, w := range r.registry.GetAll() {
+		if w.Source == SourceRegistered && w.ExpectedID == req.WorkerId && w.Address != req.Address {
+			r.log.Info("worker registered from a new address, replacing the old one",
+				"worker_id", req.WorkerId, "old", w.Address, "new", req.Address)
+			go r.removeWorker(w.Address, cfg.Retries.ForwardTimeout)
+		}
+	}
+
+	refresh := func(w *WorkerEntry) {
+		w.Models = req.Models
+		w.Capacity = req.Capacity
+		w.LastHeartbeat = time.Now()
+	}
+	var conflict error
+	w := r.registry.Control(req.Address, func(w *WorkerEntry) {
+		switch {
+		case w.Removing:
+			conflict = status.Errorf(codes.Unavailable, "%s is still draining after being removed; retry shortly", req.Address)
+		case w.Source == SourceRegistered && w.ExpectedID != req.WorkerId:
+			conflict = status.Errorf(codes.AlreadyExists, "%s is registered to worker %s", req.Address, w.ExpectedID)
+		default:
+			refresh(w)
+		}
+	})
+	if conflict != nil {
+		return nil, conflict
+	}
+	if w == nil {
+		wc := config.WorkerConfig{Address: req.Address, ID: req.WorkerId, Weight: 1}
+		if r.addWorker(wc, SourceRegistered) == nil {
+			return nil, status.Errorf(codes.Unavailable, "%s was registered concurrently; retry", req.Address)
+		}
+		r.registry.Control(req.Address, refresh)
+	}
+
+	r.tel.registrations.With("register").Inc()
+	r.log.Info("worker registered",
+		"worker", req.Address,
+		"worker_id", req.WorkerId,
+		"models", req.Models,
+		"capacity", req.Capacity)
+	return &pb.RegisterResponse{HeartbeatMs: int32(cfg.RegistrationHeartbeat.Milliseconds())}, nil
+}
+
+// Heartbeat keeps a registered worker from expiring. A worker the router
+// doesn't know, after a router restart say, gets NOT_FOUND and registers
+// again.
+func (r *Router) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.HeartbeatResponse, error) {
+	if err := r.checkRegistrationToken(ctx); err != nil {
+		return nil, err
+	}
+	var known bool
+	r.registry.Control(req.Address, func(w *WorkerEntry) {
+		if !w.Removing && (w.Source == SourceConfig || w.ExpectedID == req.WorkerId) {
+			w.LastHeartbeat = time.Now()
+			known = true
+		}
+	})
+	if !known {
+		return nil, status.Errorf(codes.NotFound, "worker %s at %s is not registered; register again", req.WorkerId, req.Address)
+	}
+	return &pb.HeartbeatResponse{}, nil
+}
+
+// Deregister drains a registered worker and then drops it. Workers listed
+// in the config stay; they are removed by editing it.
+func (r *Router) Deregister(ctx context.Context, req *pb.DeregisterRequest) (*pb.DeregisterResponse, error) {
+	if err := r.checkRegistrationToken(ctx); err != nil {
+		return nil, err
+	}
+	var source, id string
+	w := r.registry.Control(req.Address, func(w *WorkerEntry) { source, id = w.Source, w.ExpectedID })
+	switch {
+	case w == nil:
+		return &pb.DeregisterResponse{}, nil
+	case source == SourceConfig:
+		return nil, status.Errorf(codes.FailedPrecondition, "%s is in the router's config; remove it there", req.Address)
+	case id != req.WorkerId:
+		return nil, status.Errorf(codes.PermissionDenied, "%s is registered to worker %s", req.Address, id)
+	}
+	r.tel.registrations.With("deregister").Inc()
+	r.log.Info("worker deregistered", "worker", req.Address, "worker_id", req.WorkerId)
+	go r.removeWorker(req.Address, r.cfg.Load().Retries.ForwardTimeout)
+	return &pb.DeregisterResponse{}, nil
+}
+
+// checkRegistrationToken accepts calls carrying "authorization: Bearer
+// $REGISTRATION_TOKEN".
+func (r *Router) checkRegistrationToken(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	if v := md.Get("authorization"); len(v) > 0 {
+		token, _ = strings.CutPrefix(v[0], "Bearer ")
+	}
+	want := r.cfg.Load().RegistrationToken
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+		return status.Error(codes.Unauthenticated, "invalid registration token")
+	}
+	return nil
+}
+
+// expireRegistrations drops registered workers that miss three heartbeats
+// in a row, until the router stops.
+func (r *Router) expireRegistrations() {
+	interval := r.cfg.Load().RegistrationHeartbeat
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-r.stop:
+			return
+		case now = <-ticker.C:
+		}
+		cfg := r.cfg.Load()
+		for _, addr := range r.registry.Expired(now.Add(-3 * interval)) {
+			r.tel.registrations.With("expire").Inc()
+			r.log.Warn("registered worker missed its heartbeats, removing", "worker", addr)
+			go r.removeWorker(addr, cfg.Retries.ForwardTimeout)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package router
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testToken = "s3cret"
+
+func newRegistrationRouter(t *testing.T) *Router {
+	t.Helper()
+	return newTestRouter(t, map[string]string{
+		"WORKER_ENDPOINTS":          "static@127.0.0.1:1",
+		"REGISTRATION_TOKEN":        testToken,
+		"REGISTRATION_HEARTBEAT_MS": "20",
+		"FORWARD_TIMEOUT_MS":        "100",
+	})
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestRegistrationToken(t *testing.T) {
+	r := newRegistrationRouter(t)
+	defer r.Stop()
+
+	for name, ctx := range map[string]context.Context{
+		"missing": context.Background(),
+		"wrong":   withToken("guess"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := r.Register(ctx, &pb.RegisterRequest{WorkerId: "w1", Address: "127.0.0.1:2"})
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("Register: got %v, want Unauthenticated", err)
+			}
+			_, err = r.Heartbeat(ctx, &pb.HeartbeatRequest{WorkerId: "w1", Address: "127.0.0.1:2"})
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("Heartbeat: got %v, want Unauthenticated", err)
+			}
+		})
+	}
+}
+
+func TestRegisterHeartbeatDeregister(t *testing.T) {
+	r := newRegistrationRouter(t)
+	defer r.Stop()
+	ctx := withToken(testToken)
+	const addr = "127.0.0.1:2"
+
+	if _, err := r.Heartbeat(ctx, &pb.HeartbeatRequest{WorkerId: "w1", Address: addr}); status.Code(err) != codes.NotFound {
+		t.Fatalf("heartbeat before registering: got %v, want NotFound", err)
+	}
+
+	resp, err := r.Register(ctx, &pb.RegisterRequest{WorkerId: "w1", Address: addr, Models: []string{"bert"}, Capacity: 32})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if resp.HeartbeatMs != 20 {
+		t.Errorf("heartbeat_ms = %d, want 20", resp.HeartbeatMs)
+	}
+	var models []string
+	var capacity int32
+	r.registry.Control(addr, func(w *WorkerEntry) { models, capacity = w.Models, w.Capacity })
+	if got := sourceOf(r, addr); got != SourceRegistered {
+		t.Errorf("source = %q, want %q", got, SourceRegistered)
+	}
+	if !slices.Equal(models, []string{"bert"}) || capacity != 32 {
+		t.Errorf("models, capacity = %v, %d", models, capacity)
+	}
+
+	if _, err := r.Heartbeat(ctx, &pb.HeartbeatRequest{WorkerId: "w1", Address: addr}); err != nil {
+		t.Errorf("Heartbeat: %v", err)
+	}
+	if _, err := r.Heartbeat(ctx, &pb.HeartbeatRequest{WorkerId: "w2", Address: addr}); status.Code(err) != codes.NotFound {
+		t.Errorf("heartbeat under another ID: got %v, want NotFound", err)
+	}
+
+	if _, err := r.Deregister(ctx, &pb.DeregisterRequest{WorkerId: "w2", Address: addr}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("deregister under another ID: got %v, want PermissionDenied", err)
+	}
+	if _, err := r.Deregister(ctx, &pb.DeregisterRequest{WorkerId: "w1", Address: addr}); err != nil {
+		t.Fatalf("Deregister: %v", err)
+	}
+	eventually(t, "the deregistered worker to be removed", func() bool { return r.registry.Get(addr) == nil })
+}
+
+func TestRegisterConflicts(t *testing.T) {
+	r := newRegistrationRouter(t)
+	defer r.Stop()
+	ctx := withToken(testToken)
+
+	tests := []struct {
+		name string
+		req  *pb.RegisterRequest
+		want codes.Code
+	}{
+		{"no id", &pb.RegisterRequest{Address: "127.0.0.1:3"}, codes.InvalidArgument},
+		{"bad address", &pb.RegisterRequest{WorkerId: "w1", Address: "nowhere"}, codes.InvalidArgument},
+		{"taken by another id", &pb.RegisterRequest{WorkerId: "w2", Address: "127.0.0.1:2"}, codes.AlreadyExists},
+	}
+	if _, err := r.Register(ctx, &pb.RegisterRequest{WorkerId: "w1", Address: "127.0.0.1:2"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.Register(ctx, tt.req); status.Code(err) != tt.want {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	_, err := r.Deregister(ctx, &pb.DeregisterRequest{WorkerId: "static", Address: "127.0.0.1:1"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("deregister a config worker: got %v, want FailedPrecondition", err)
+	}
+}
+
+func TestRegisterFromNewAddressReplacesOld(t *testing.T) {
+	r := newRegistrationRouter(t)
+	defer r.Stop()
+	ctx := withToken(testToken)
+
+	for _, addr := range []string{"127.0.0.1:2", "127.0.0.1:3"} {
+		if _, err := r.Register(ctx, &pb.RegisterRequest{WorkerId: "w1", Address: addr}); err != nil {
+			t.Fatalf("Register %s: %v", addr, err)
+		}
+	}
+	eventually(t, "the old address to be removed", func() bool { return r.registry.Get("127.0.0.1:2") == nil })
+	if got := sourceOf(r, "127.0.0.1:3"); got != SourceRegistered {
+		t.Errorf("new address source = %q, want %q", got, SourceRegistered)
+	}
+}
+
+func TestRegistrationExpiry(t *testing.T) {
+	r := newRegistrationRouter(t)
+	ctx := withToken(testToken)
+
+	done := make(chan struct{})
+	go func() {
+		r.expireRegistrations()
+		close(done)
+	}()
+
+	if _, err := r.Register(ctx, &pb.RegisterRequest{WorkerId: "w1", Address: "127.0.0.1:2"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := r.Register(ctx, &pb.RegisterRequest{WorkerId: "w2", Address: "127.0.0.1:3"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// w2 keeps heartbeating; w1 goes quiet and should expire
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		if _, err := r.Heartbeat(ctx, &pb.HeartbeatRequest{WorkerId: "w2", Address: "127.0.0.1:3"}); err != nil {
+			t.Fatalf("Heartbeat: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	eventually(t, "w1 to expire", func() bool { return r.registry.Get("127.0.0.1:2") == nil })
+	if r.registry.Get("127.0.0.1:3") == nil {
+		t.Error("w2 expired despite heartbeating")
+	}
+	if r.registry.Get("127.0.0.1:1") == nil {
+		t.Error("the config worker expired")
+	}
+
+	r.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expiry loop still running after Stop")
+	}
+}
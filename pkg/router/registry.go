@@ -1,47 +1,190 @@
 package router
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/config"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+	"github.com/kunal/gpu-batch-router/pkg/tlsutil"
+	"github.com/kunal/gpu-batch-router/pkg/tracing"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
-// WorkerEntry tracks a single worker's state.
+// Where a worker entry came from.
+const (
+	SourceConfig     = "config"     // router.workers / WORKER_ENDPOINTS
+	SourceRegistered = "registered" // the worker called RegistrationService.Register
+	SourceDiscovered = "dns"        // found by DNS discovery (DISCOVERY_MODE)
+)
+
+// WorkerEntry tracks a single worker's state. The fields listed here are
+// set when the entry is created and never change; the embedded entryState
+// does, under Registry.mu, so read it through a WorkerView.
 type WorkerEntry struct {
 	Address       string
+	ExpectedID    string // from an "id@host:port" endpoint; the worker's TLS identity
+	Source        string // SourceConfig, SourceRegistered or SourceDiscovered
 	Conn          *grpc.ClientConn
 	InferClient   pb.InferenceServiceClient
 	MetricsClient pb.WorkerMetricsServiceClient
-	Metrics       *pb.WorkerMetrics
-	FailCount     int
-	Healthy       bool
+
+	entryState
+
+	// Requests this router has forwarded to the worker and not yet seen
+	// finish, and that count when Metrics last arrived. Between reports the
+	// difference is queued work the worker's numbers don't show yet.
+	inFlight           atomic.Int64
+	inFlightAtSnapshot atomic.Int64
+}
+
+// entryState is the part of a WorkerEntry that changes after it is
+// created. Registry.mu guards it. Metrics and Models are replaced whole,
+// never modified, so a copy may keep them.
+type entryState struct {
+	Metrics   *pb.WorkerMetrics
+	FailCount int
+	Healthy   bool
+
+	// UpdatedAt is when Metrics last arrived, on the router's clock (zero
+	// until the worker first reports). Restarts counts worker process
+	// restarts seen through a changed started_at.
+	UpdatedAt time.Time
+	Restarts  int
+
+	// Operator controls, set through the admin API. Cordoned and draining
+	// workers get no new requests; a draining worker is drained once its
+	// in-flight count reaches zero. Weight scales the worker's share of
+	// traffic among the top candidates (1 = normal, 0 = none).
+	Cordoned bool
+	Draining bool
+	Weight   float64
+
+	// Removing is set once the worker is dropped (from the config, or by
+	// deregistering or missing heartbeats); it is forgotten when drained.
+	Removing bool
+
+	// From the worker's registration: the models it serves (empty: any),
+	// how many requests it can run at once, and when it last heartbeated.
+	Models        []string
+	Capacity      int32
+	LastHeartbeat time.Time
+}
+
+// Serves reports whether the worker accepts requests for model.
+func (s *entryState) Serves(model string) bool {
+	return len(s.Models) == 0 || slices.Contains(s.Models, model)
+}
+
+// MetricsAge returns how old the cached metrics are. Workers that have
+// never reported are infinitely stale.
+func (s *entryState) MetricsAge(now time.Time) time.Duration {
+	if s.UpdatedAt.IsZero() {
+		return time.Duration(math.MaxInt64)
+	}
+	return now.Sub(s.UpdatedAt)
+}
+
+// available reports whether the worker's state lets it take new requests.
+func (s *entryState) available() bool {
+	return s.Healthy && !s.Cordoned && !s.Draining && s.Weight > 0
+}
+
+// InFlight returns the number of requests the router has outstanding on w.
+func (w *WorkerEntry) InFlight() int64 { return w.inFlight.Load() }
+
+// beginRequest counts a request forwarded to w until the returned func is called.
+func (w *WorkerEntry) beginRequest() (done func()) {
+	w.inFlight.Add(1)
+	return func() { w.inFlight.Add(-1) }
+}
+
+// view copies w's state. Caller holds the registry lock.
+func (w *WorkerEntry) view() WorkerView {
+	return WorkerView{Entry: w, entryState: w.entryState}
+}
+
+// WorkerView is a worker's state as copied under the registry lock, so
+// scoring and reporting can read it while the entry keeps changing.
+// Entry gives the fields that never change and the in-flight counts.
+type WorkerView struct {
+	Entry *WorkerEntry
+	entryState
+}
+
+// Routable reports whether new requests may be sent to the worker.
+func (v WorkerView) Routable() bool {
+	return v.available() && v.Entry.InferClient != nil
+}
+
+// Drained reports whether a draining worker has finished its in-flight requests.
+func (v WorkerView) Drained() bool {
+	return v.Draining && v.Entry.InFlight() == 0
+}
+
+// EstimatedQueue is the last reported queue depth plus requests forwarded
+// since that report. Requests finishing since the report are not
+// subtracted: the reported depth excludes work already executing, so the
+// estimate errs towards a longer queue only by what this router added.
+func (v WorkerView) EstimatedQueue() int32 {
+	extra := v.Entry.inFlight.Load() - v.Entry.inFlightAtSnapshot.Load()
+	return v.Metrics.GetQueueDepth() + int32(max(0, extra))
 }
 
 // Registry manages the set of known workers.
 type Registry struct {
 	mu      sync.RWMutex
 	workers map[string]*WorkerEntry // key: address
+	tls     *tlsutil.Reloader       // nil: plaintext to workers
+	tel     *telemetry
+	log     *slog.Logger
+	events  []Event // pending for the dashboard, see TakeEvents
 }
 
-func NewRegistry(addrs []string) *Registry {
+// NewRegistry creates entries for the configured workers. With clientTLS,
+// connections to workers use TLS and each worker must present a
+// certificate for its ID, or its host if none is given.
+func NewRegistry(workers []config.WorkerConfig, clientTLS *tlsutil.Reloader, tel *telemetry) *Registry {
 	r := &Registry{
-		workers: make(map[string]*WorkerEntry, len(addrs)),
+		workers: make(map[string]*WorkerEntry, len(workers)),
+		tls:     clientTLS,
+		tel:     tel,
+		log:     logging.For("registry"),
 	}
-	for _, addr := range addrs {
-		r.workers[addr] = &WorkerEntry{
-			Address: addr,
-			Healthy: true,
+	for _, wc := range workers {
+		r.workers[wc.Address] = newEntry(wc, SourceConfig)
+	}
+	return r
+}
+
+func newEntry(wc config.WorkerConfig, source string) *WorkerEntry {
+	return &WorkerEntry{
+		Address:    wc.Address,
+		ExpectedID: wc.ID,
+		Source:     source,
+		entryState: entryState{
+			Healthy:  true,
+			Weight:   wc.Weight,
+			Cordoned: wc.Cordoned,
 			Metrics: &pb.WorkerMetrics{
 				Healthy:     true,
 				VramFreeGb:  5.0,
 				VramTotalGb: 5.0,
 			},
-		}
+
+			// A registered worker has just called in; expiry counts from here
+			LastHeartbeat: time.Now(),
+		},
 	}
-	return r
 }
 
 // Connect establishes gRPC connections to all workers.
@@ -49,32 +192,140 @@ func (r *Registry) Connect() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	for addr, entry := range r.workers {
-		conn, err := grpc.NewClient(addr,
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
-		)
-		if err != nil {
-			log.Printf("⚠️  Failed to connect to worker %s: %v", addr, err)
-			entry.Healthy = false
-			continue
-		}
-		entry.Conn = conn
-		entry.InferClient = pb.NewInferenceServiceClient(conn)
-		entry.MetricsClient = pb.NewWorkerMetricsServiceClient(conn)
-		log.Printf("✅ Connected to worker %s", addr)
+	for _, entry := range r.workers {
+		r.dial(entry)
 	}
 	return nil
 }
 
-// GetHealthy returns all healthy worker entries.
-func (r *Registry) GetHealthy() []*WorkerEntry {
+// dial creates entry's connection and clients. Caller holds r.mu.
+func (r *Registry) dial(entry *WorkerEntry) {
+	conn, err := grpc.NewClient(entry.Address,
+		grpc.WithTransportCredentials(r.credentials(entry)),
+		grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		r.log.Warn("failed to connect to worker", "worker", entry.Address, "err", err)
+		r.setHealthy(entry, false)
+		return
+	}
+	entry.Conn = conn
+	entry.InferClient = pb.NewInferenceServiceClient(conn)
+	entry.MetricsClient = pb.NewWorkerMetricsServiceClient(conn)
+	r.log.Info("connected to worker", "worker", entry.Address, "tls", r.tls != nil)
+}
+
+// Add registers and connects a new worker. It returns the new entry, or
+// nil if a worker with that address is already registered.
+func (r *Registry) Add(wc config.WorkerConfig, source string) *WorkerEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.workers[wc.Address]; ok {
+		return nil
+	}
+	entry := newEntry(wc, source)
+	r.workers[wc.Address] = entry
+	r.dial(entry)
+	r.emit(Event{Type: "config", Worker: wc.Address, Message: "worker " + wc.Address + " added"})
+	return entry
+}
+
+// Remove forgets the worker at addr and closes its connection. Requests
+// still in flight on it fail; drain it first.
+func (r *Registry) Remove(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.workers[addr]
+	if !ok {
+		return
+	}
+	delete(r.workers, addr)
+	if w.Conn != nil {
+		w.Conn.Close()
+	}
+	r.emit(Event{Type: "config", Worker: addr, Message: "worker " + addr + " removed"})
+	r.log.Info("worker removed", "worker", addr)
+}
+
+// Expired returns the addresses of registered workers, not already being
+// removed, whose last heartbeat is before cutoff.
+func (r *Registry) Expired(cutoff time.Time) []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	var addrs []string
+	for addr, w := range r.workers {
+		if w.Source == SourceRegistered && !w.Removing && w.LastHeartbeat.Before(cutoff) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
 
-	result := make([]*WorkerEntry, 0)
+// BySource returns the addresses of workers from source that aren't
+// already being removed.
+func (r *Registry) BySource(source string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var addrs []string
+	for addr, w := range r.workers {
+		if w.Source == source && !w.Removing {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// View returns a copy of the state of the worker at addr, if there is one.
+func (r *Registry) View(addr string) (WorkerView, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if w, ok := r.workers[addr]; ok {
+		return w.view(), true
+	}
+	return WorkerView{}, false
+}
+
+// Get returns the worker registered at addr, or nil.
+func (r *Registry) Get(addr string) *WorkerEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.workers[addr]
+}
+
+// credentials returns the transport credentials for dialling w.
+func (r *Registry) credentials(w *WorkerEntry) credentials.TransportCredentials {
+	if r.tls == nil {
+		return insecure.NewCredentials()
+	}
+	name := w.ExpectedID
+	if name == "" {
+		name, _, _ = net.SplitHostPort(w.Address)
+	}
+	return credentials.NewTLS(r.tls.ClientConfig(name))
+}
+
+// Views returns a copy of every worker's state.
+func (r *Registry) Views() []WorkerView {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]WorkerView, 0, len(r.workers))
+	for _, w := range r.workers {
+		result = append(result, w.view())
+	}
+	return result
+}
+
+// Routable returns the workers that may take new requests: healthy,
+// connected, not cordoned or draining, and with a non-zero weight.
+func (r *Registry) Routable() []WorkerView {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]WorkerView, 0)
 	for _, w := range r.workers {
-		if w.Healthy && w.InferClient != nil {
-			result = append(result, w)
+		if v := w.view(); v.Routable() {
+			result = append(result, v)
 		}
 	}
 	return result
@@ -92,15 +343,60 @@ func (r *Registry) GetAll() []*WorkerEntry {
 	return result
 }
 
-// UpdateMetrics updates the cached metrics for a worker.
+// UpdateMetrics updates the cached metrics for a worker. Snapshots older
+// than the cached one (a slow poll overtaken by a stream push) are
+// dropped; a changed process start time is recorded as a restart.
 func (r *Registry) UpdateMetrics(addr string, m *pb.WorkerMetrics) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if w, ok := r.workers[addr]; ok {
-		w.Metrics = m
-		w.FailCount = 0
-		w.Healthy = m.Healthy
+	w, ok := r.workers[addr]
+	if !ok {
+		return
+	}
+	if prev := w.Metrics; prev.GetStartedAtUnixMs() != 0 && m.StartedAtUnixMs != 0 {
+		switch {
+		case m.StartedAtUnixMs != prev.StartedAtUnixMs:
+			w.Restarts++
+			r.tel.restarts.With(addr).Inc()
+			r.emit(Event{Type: "restart", Worker: addr, Message: fmt.Sprintf("worker %s restarted (%d so far)", addr, w.Restarts)})
+			r.log.Warn("worker restarted",
+				"worker", addr,
+				"worker_id", m.WorkerId,
+				"started_at", time.UnixMilli(m.StartedAtUnixMs),
+				"restarts", w.Restarts)
+		case m.Seq <= prev.Seq:
+			r.tel.outOfOrder.With(addr).Inc()
+			return
+		}
+	}
+	if w.ExpectedID != "" && m.WorkerId != w.ExpectedID && m.WorkerId != w.Metrics.GetWorkerId() {
+		r.log.Warn("worker reports a different ID than its endpoint", "worker", addr, "expected", w.ExpectedID, "worker_id", m.WorkerId)
 	}
+	w.Metrics = m
+	w.inFlightAtSnapshot.Store(w.inFlight.Load())
+	w.UpdatedAt = time.Now()
+	w.FailCount = 0
+	r.setHealthy(w, m.Healthy)
+}
+
+// Control applies an operator change to the worker with the given address
+// or worker ID and returns it, or nil if there's no such worker.
+func (r *Registry) Control(key string, change func(w *WorkerEntry)) *WorkerEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.workers[key]
+	if !ok {
+		for _, cand := range r.workers {
+			if cand.Metrics.GetWorkerId() == key {
+				w = cand
+				break
+			}
+		}
+	}
+	if w != nil {
+		change(w)
+	}
+	return w
 }
 
 // MarkFailed increments the fail count for a worker.
@@ -110,9 +406,9 @@ func (r *Registry) MarkFailed(addr string) {
 	defer r.mu.Unlock()
 	if w, ok := r.workers[addr]; ok {
 		w.FailCount++
-		if w.FailCount >= 3 {
-			w.Healthy = false
-			log.Printf("❌ Worker %s marked UNHEALTHY (3 consecutive failures)", addr)
+		if w.FailCount >= 3 && w.Healthy {
+			r.setHealthy(w, false)
+			r.log.Warn("worker marked unhealthy", "worker", addr, "consecutive_failures", w.FailCount)
 		}
 	}
 }
@@ -123,8 +419,39 @@ func (r *Registry) MarkHealthy(addr string) {
 	defer r.mu.Unlock()
 	if w, ok := r.workers[addr]; ok {
 		w.FailCount = 0
-		w.Healthy = true
+		r.setHealthy(w, true)
+	}
+}
+
+// setHealthy updates a worker's health, counting transitions. Caller holds r.mu.
+func (r *Registry) setHealthy(w *WorkerEntry, healthy bool) {
+	if w.Healthy != healthy {
+		r.tel.healthChanged(w.Address, healthy)
+		msg := "worker " + w.Address + " unhealthy"
+		if healthy {
+			msg = "worker " + w.Address + " healthy again"
+		}
+		r.emit(Event{Type: "health", Worker: w.Address, Message: msg})
+		if healthy {
+			r.log.Info("worker healthy again", "worker", w.Address)
+		}
 	}
+	w.Healthy = healthy
+}
+
+// emit queues an event for dashboards. Caller holds r.mu.
+func (r *Registry) emit(e Event) {
+	e.At = time.Now()
+	r.events = append(r.events, e)
+}
+
+// TakeEvents returns and clears the events since the last call.
+func (r *Registry) TakeEvents() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	return events
 }
 
 // Close shuts down all gRPC connections.
@@ -0,0 +1,111 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/kunal/gpu-batch-router/pkg/config"
+)
+
+// Reload switches the running router to next, which differs from the
+// current configuration only in live settings (config.Reloader refuses
+// the rest). Scoring, retries and staleness apply from the next request
+// and the poll interval from each worker's next poll. The worker list is
+// reconciled: new workers are dialled and watched, dropped ones drained
+// and then forgotten, and weights or cordons that changed in the file
+// are applied over whatever an operator set.
+func (r *Router) Reload(next *config.Config) error {
+	cur := r.cfg.Load()
+	old := make(map[string]config.WorkerConfig, len(cur.Workers))
+	for _, w := range cur.Workers {
+		old[w.Address] = w
+	}
+
+	// Check everything first, so a refused reload changes nothing
+	var errs []error
+	kept := make(map[string]bool, len(next.Workers))
+	for i, w := range next.Workers {
+		kept[w.Address] = true
+		prev, ok := old[w.Address]
+		existing, known := r.registry.View(w.Address)
+		switch {
+		case !ok && known && existing.Removing:
+			errs = append(errs, fmt.Errorf("router.workers[%d]: %s is still draining after being removed; reload again once it is gone", i, w.Address))
+		case !ok && known:
+			errs = append(errs, fmt.Errorf("router.workers[%d]: %s is already known to the router (%s)", i, w.Address, existing.Entry.Source))
+		case ok && prev.ID != w.ID:
+			errs = append(errs, fmt.Errorf("router.workers[%d].id: a worker's ID can't change in place; remove the worker, reload, then add it back", i))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	r.cfg.Store(next)
+	r.poller.SetInterval(next.PollInterval)
+
+	for _, wc := range next.Workers {
+		prev, ok := old[wc.Address]
+		if !ok {
+			r.addWorker(wc, SourceConfig)
+			continue
+		}
+		if prev.Weight != wc.Weight || prev.Cordoned != wc.Cordoned {
+			r.registry.Control(wc.Address, func(w *WorkerEntry) {
+				w.Weight, w.Cordoned = wc.Weight, wc.Cordoned
+			})
+			r.log.Info("worker settings changed by config", "worker", wc.Address, "weight", wc.Weight, "cordoned", wc.Cordoned)
+		}
+	}
+	for _, wc := range cur.Workers {
+		if !kept[wc.Address] {
+			go r.removeWorker(wc.Address, next.Retries.ForwardTimeout)
+		}
+	}
+	return nil
+}
+
+// addWorker registers, dials and starts watching a worker that was added
+// after startup. It returns nil if the address is already taken.
+func (r *Router) addWorker(wc config.WorkerConfig, source string) *WorkerEntry {
+	w := r.registry.Add(wc, source)
+	if w == nil {
+		return nil
+	}
+	r.mu.Lock()
+	if _, ok := r.routingDistribution[wc.Address]; !ok {
+		r.routingDistribution[wc.Address] = &atomic.Int64{}
+	}
+	r.mu.Unlock()
+	r.poller.Watch(w)
+	r.log.Info("worker added", "worker", wc.Address, "source", source)
+	return w
+}
+
+// removeWorker drains a worker that was dropped, then stops watching it
+// and forgets it, along with its metric series. Forwards end within the
+// forward timeout, so it waits no longer than that for them.
+func (r *Router) removeWorker(addr string, forwardTimeout time.Duration) {
+	var already bool
+	w := r.registry.Control(addr, func(w *WorkerEntry) {
+		already = w.Removing
+		w.Draining, w.Removing = true, true
+	})
+	if w == nil || already {
+		return
+	}
+	r.log.Info("draining removed worker", "worker", addr, "in_flight", w.InFlight())
+
+	deadline := time.Now().Add(forwardTimeout + time.Second)
+	for w.InFlight() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	r.poller.Unwatch(addr)
+	r.registry.Remove(addr)
+	r.mu.Lock()
+	delete(r.routingDistribution, addr)
+	r.mu.Unlock()
+	r.tel.forgetWorker(addr)
+}
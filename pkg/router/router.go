@@ -3,11 +3,13 @@ package router
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
 	"math/rand"
 	"net/http"
+	"slices"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -15,6 +17,10 @@ import (
 
 	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
 	"github.com/kunal/gpu-batch-router/pkg/config"
+	"github.com/kunal/gpu-batch-router/pkg/gpu"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+	"github.com/kunal/gpu-batch-router/pkg/tlsutil"
+	"github.com/kunal/gpu-batch-router/pkg/tracing"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -23,16 +29,29 @@ import (
 //go:embed dashboard/*
 var dashboardFS embed.FS
 
+var errNoHealthyWorkers = errors.New("no healthy workers available")
+
 // Router is the main routing service.
 type Router struct {
 	pb.UnimplementedInferenceServiceServer
+	pb.UnimplementedRegistrationServiceServer
 
-	cfg         *config.Config
+	cfg         atomic.Pointer[config.Config] // replaced whole by Reload
 	registry    *Registry
 	poller      *Poller
 	broadcaster *Broadcaster
-
-	// Routing stats
+	history     *History
+	auth        *authenticator
+	apiAuth     *apiAuth          // nil: gRPC callers aren't authenticated
+	workerTLS   *tlsutil.Reloader // nil: plaintext to workers
+	discovery   *Discovery        // nil: no DNS discovery
+	actions     chan *AdminAction // pushed to dashboards ahead of the next tick
+	stop        chan struct{}     // closed by Stop; ends registration expiry and discovery
+	tel         *telemetry
+	log         *slog.Logger
+
+	// Routing stats; mu guards the map, which changes as workers are
+	// added and removed
 	mu                  sync.RWMutex
 	routingDistribution map[string]*atomic.Int64
 	totalRequests       atomic.Int64
@@ -40,23 +59,58 @@ type Router struct {
 
 // New creates a new Router.
 func New(cfg *config.Config) (*Router, error) {
-	if len(cfg.WorkerEndpoints) == 0 {
-		return nil, fmt.Errorf("no worker endpoints configured (set WORKER_ENDPOINTS)")
+	if len(cfg.Workers) == 0 && cfg.RegistrationToken == "" && cfg.DiscoveryMode == "" {
+		return nil, fmt.Errorf("no worker endpoints configured (set WORKER_ENDPOINTS, DISCOVERY_MODE, or REGISTRATION_TOKEN to let workers register)")
 	}
 
-	registry := NewRegistry(cfg.WorkerEndpoints)
-	broadcaster := NewBroadcaster()
+	tracer, err := tracing.Setup("router", cfg.TraceExport, cfg.TraceFile, cfg.TraceSampleRate)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := newAuthenticator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var workerTLS *tlsutil.Reloader
+	if cfg.WorkerTLSCAFile != "" || cfg.WorkerTLSCertFile != "" {
+		workerTLS, err = tlsutil.NewReloader(tlsutil.Files{
+			Cert: cfg.WorkerTLSCertFile,
+			Key:  cfg.WorkerTLSKeyFile,
+			CA:   cfg.WorkerTLSCAFile,
+		}, cfg.TLSReload)
+		if err != nil {
+			return nil, fmt.Errorf("worker TLS: %w", err)
+		}
+	}
+	tel := newTelemetry(tracer)
+	apiAuth, err := newAPIAuth(cfg, tel)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC auth: %w", err)
+	}
+	registry := NewRegistry(cfg.Workers, workerTLS, tel)
+	broadcaster := NewBroadcaster(auth.checkOrigin, cfg.DashboardPushInterval, tel)
 
 	r := &Router{
-		cfg:                 cfg,
 		registry:            registry,
 		broadcaster:         broadcaster,
+		history:             NewHistory(cfg.HistoryRetention, cfg.HistoryInterval),
+		auth:                auth,
+		apiAuth:             apiAuth,
+		workerTLS:           workerTLS,
+		actions:             make(chan *AdminAction, 16),
+		stop:                make(chan struct{}),
+		tel:                 tel,
+		log:                 logging.For("router"),
 		routingDistribution: make(map[string]*atomic.Int64),
 	}
+	r.cfg.Store(cfg)
+	if cfg.DiscoveryMode != "" {
+		r.discovery = NewDiscovery(cfg.DiscoveryMode, cfg.DiscoveryName, nil)
+	}
 
 	// Initialize routing distribution counters
-	for _, addr := range cfg.WorkerEndpoints {
-		r.routingDistribution[addr] = &atomic.Int64{}
+	for _, w := range cfg.Workers {
+		r.routingDistribution[w.Address] = &atomic.Int64{}
 	}
 
 	// Connect to all workers
@@ -64,20 +118,41 @@ func New(cfg *config.Config) (*Router, error) {
 		return nil, fmt.Errorf("failed to connect to workers: %w", err)
 	}
 
-	r.poller = NewPoller(registry, cfg.PollInterval)
+	r.poller = NewPoller(registry, cfg.PollInterval, cfg.MetricsMode, cfg.MetricsHeartbeat, tel)
 
 	return r, nil
 }
 
-// RegisterGRPC registers the router's gRPC service.
+// RegisterGRPC registers the router's gRPC services. Workers can only
+// register themselves when a registration token is configured.
 func (r *Router) RegisterGRPC(s *grpc.Server) {
 	pb.RegisterInferenceServiceServer(s, r)
+	if r.cfg.Load().RegistrationToken != "" {
+		pb.RegisterRegistrationServiceServer(s, r)
+	}
 }
 
-// RegisterHTTP registers the dashboard and WebSocket endpoints.
+// RegisterHTTP registers the dashboard, WebSocket and /metrics endpoints.
+// Everything but /health and /logout needs the read role when dashboard
+// auth is on.
 func (r *Router) RegisterHTTP(mux *http.ServeMux) {
+	read := func(h http.Handler) http.Handler { return r.auth.require(roleRead, h) }
+
 	// WebSocket endpoint
-	mux.HandleFunc("/ws", r.broadcaster.HandleWS)
+	mux.Handle("/ws", read(http.HandlerFunc(r.broadcaster.HandleWS)))
+
+	// Prometheus metrics
+	mux.Handle("/metrics", read(http.HandlerFunc(r.ServePrometheus)))
+
+	// Metrics history for dashboard charts
+	mux.Handle("/api/history", read(r.history))
+
+	// Session
+	mux.Handle("GET /api/whoami", read(http.HandlerFunc(r.auth.handleWhoami)))
+	mux.HandleFunc("POST /logout", r.auth.handleLogout)
+
+	// Operator controls
+	r.registerAdmin(mux)
 
 	// Health check
 	mux.HandleFunc("/health", func(w http.ResponseWriter, req *http.Request) {
@@ -88,82 +163,213 @@ func (r *Router) RegisterHTTP(mux *http.ServeMux) {
 	// Serve embedded dashboard files
 	dashContent, err := fs.Sub(dashboardFS, "dashboard")
 	if err != nil {
-		log.Printf("⚠️  Dashboard files not found, skipping")
+		r.log.Warn("dashboard files not found, skipping")
 		return
 	}
-	mux.Handle("/", http.FileServer(http.FS(dashContent)))
+	mux.Handle("/", read(http.FileServer(http.FS(dashContent))))
 }
 
 // StartPoller starts the metrics polling loop and broadcast loop.
 func (r *Router) StartPoller() {
 	r.poller.Start()
 
-	// Start broadcast loop (push state to dashboard every 500ms)
+	// Start broadcast loop. It ticks at the fastest interval a client may
+	// ask for; the broadcaster decides who is due. Operator actions go out
+	// right away.
 	go func() {
-		ticker := time.NewTicker(500 * time.Millisecond)
+		ticker := time.NewTicker(minPushInterval)
 		defer ticker.Stop()
-		for range ticker.C {
-			r.broadcastState()
+		for {
+			select {
+			case <-ticker.C:
+				r.broadcastState(nil)
+			case act := <-r.actions:
+				r.broadcastState(act)
+			}
 		}
 	}()
+
+	go r.recordHistory()
+	if r.cfg.Load().RegistrationToken != "" {
+		go r.expireRegistrations()
+	}
+	if r.discovery != nil {
+		go r.discover(r.discovery, r.cfg.Load().DiscoveryInterval)
+	}
+}
+
+// recordHistory samples worker state into r.history at its interval.
+// Rates are computed from counter deltas between samples.
+func (r *Router) recordHistory() {
+	ticker := time.NewTicker(r.history.interval)
+	defer ticker.Stop()
+
+	lastAt := time.Now()
+	lastTotal := r.totalRequests.Load()
+	lastRouted := make(map[string]int64)
+	for now := range ticker.C {
+		secs := now.Sub(lastAt).Seconds()
+		total := r.totalRequests.Load()
+		sample := HistorySample{
+			At:          now,
+			RequestRate: float64(total-lastTotal) / secs,
+			Workers:     make(map[string]WorkerSample),
+		}
+		for _, w := range r.registry.Views() {
+			addr := w.Entry.Address
+			routed := r.routedCount(addr)
+			ws := WorkerSample{
+				Healthy:        w.Healthy,
+				Score:          r.score(w, now),
+				RoutedRate:     float64(routed-lastRouted[addr]) / secs,
+				QueueDepth:     float64(w.Metrics.GetQueueDepth()),
+				EstimatedQueue: float64(w.EstimatedQueue()),
+				GPUUtilization: w.Metrics.GetGpuUtilization(),
+				VRAMUsedGB:     w.Metrics.GetVramTotalGb() - w.Metrics.GetVramFreeGb(),
+				TemperatureC:   w.Metrics.GetTemperatureC(),
+				AvgLatencyMs:   w.Metrics.GetAvgLatencyMs(),
+			}
+			sample.Workers[addr] = ws
+			lastRouted[addr] = routed
+		}
+		r.history.Add(sample)
+		lastAt, lastTotal = now, total
+	}
+}
+
+// routedCount returns how many requests addr has served.
+func (r *Router) routedCount(addr string) int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if counter, ok := r.routingDistribution[addr]; ok {
+		return counter.Load()
+	}
+	return 0
 }
 
 // Stop shuts down the router.
 func (r *Router) Stop() {
+	close(r.stop)
+	r.broadcaster.Close()
 	r.poller.Stop()
 	r.registry.Close()
+	if r.workerTLS != nil {
+		r.workerTLS.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.tel.tracer.Shutdown(ctx); err != nil {
+		r.log.Warn("trace export shutdown failed", "err", err)
+	}
 }
 
 // Infer routes an inference request to the best available worker.
-func (r *Router) Infer(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+func (r *Router) Infer(ctx context.Context, req *pb.InferRequest) (resp *pb.InferResponse, err error) {
 	r.totalRequests.Add(1)
+	start := time.Now()
+	ctx = logging.With(ctx, "request_id", req.RequestId)
+	ctx, span := r.tel.tracer.Start(ctx, "router.receive", tracing.WithKind(tracing.KindServer))
+	span.SetAttr("request.id", req.RequestId)
+	span.SetAttr("request.priority", req.Priority.String())
+	span.SetAttr("request.model", req.ModelName)
+	if t := TenantFromContext(ctx); t != nil {
+		span.SetAttr("tenant", t.Name)
+	}
+	defer func() {
+		r.tel.observeRequest(req, time.Since(start), err)
+		span.SetError(err)
+		span.End()
+	}()
 
-	// Try up to 3 times (original + 2 retries)
-	maxRetries := 3
+	// Try up to MaxAttempts times (original + retries)
+	cfg := r.cfg.Load()
 	var lastErr error
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		worker := r.pickBestWorker()
+	for attempt := 0; attempt < cfg.Retries.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			r.tel.retries.With().Inc()
+		}
+		_, sel := r.tel.tracer.Start(ctx, "router.select")
+		sel.SetAttr("attempt", attempt+1)
+		pickStart := time.Now()
+		worker := r.pickBestWorker(req.ModelName)
+		r.tel.decision.With().Observe(time.Since(pickStart).Seconds())
 		if worker == nil {
-			return nil, status.Error(codes.Unavailable, "no healthy workers available")
+			sel.SetError(errNoHealthyWorkers)
+			sel.End()
+			return nil, status.Error(codes.Unavailable, errNoHealthyWorkers.Error())
 		}
-
-		// Forward request to chosen worker with generous timeout for remote workers
-		fwdCtx, fwdCancel := context.WithTimeout(context.Background(), 10*time.Second)
-		resp, err := worker.InferClient.Infer(fwdCtx, req)
+		entry := worker.Entry
+		sel.SetAttr("worker.address", entry.Address)
+		sel.End()
+
+		// Forward request to chosen worker with generous timeout for remote workers.
+		// The forward outlives client cancellation but keeps the trace context.
+		fctx, fwd := r.tel.tracer.Start(ctx, "router.forward", tracing.WithKind(tracing.KindClient))
+		fwd.SetAttr("attempt", attempt+1)
+		fwd.SetAttr("worker.address", entry.Address)
+		fwdCtx, fwdCancel := context.WithTimeout(context.WithoutCancel(fctx), cfg.Retries.ForwardTimeout)
+		done := entry.beginRequest()
+		resp, err := entry.InferClient.Infer(fwdCtx, req)
+		done()
 		fwdCancel()
+		fwd.SetError(err)
+		fwd.End()
 		if err == nil {
 			// Success — track routing distribution
-			if counter, ok := r.routingDistribution[worker.Address]; ok {
+			r.mu.RLock()
+			if counter, ok := r.routingDistribution[entry.Address]; ok {
 				counter.Add(1)
 			}
+			r.mu.RUnlock()
+			r.tel.routed.With(entry.Address).Inc()
 			return resp, nil
 		}
+		r.tel.forwardFailures.With(entry.Address, status.Code(err).String()).Inc()
 
 		// Failure — mark worker and retry
-		log.Printf("⚠️  Worker %s failed (attempt %d): %v", worker.Address, attempt+1, err)
-		r.registry.MarkFailed(worker.Address)
+		r.log.WarnContext(ctx, "worker failed",
+			"worker", entry.Address,
+			"worker_id", worker.Metrics.GetWorkerId(),
+			"attempt", attempt+1,
+			"err", err)
+		r.registry.MarkFailed(entry.Address)
 		lastErr = err
 	}
 
 	return nil, status.Errorf(codes.Unavailable, "all workers failed: %v", lastErr)
 }
 
-// pickBestWorker selects the best worker using weighted random among top-3.
-func (r *Router) pickBestWorker() *WorkerEntry {
-	healthy := r.registry.GetHealthy()
-	if len(healthy) == 0 {
+// notifyDashboards queues an immediate state push describing act. If the
+// queue is full the next tick carries the new state anyway.
+func (r *Router) notifyDashboards(act *AdminAction) {
+	select {
+	case r.actions <- act:
+	default:
+	}
+}
+
+// pickBestWorker selects the best worker for model using weighted random
+// among the top Scoring.Candidates (3 by default), scaled by operator
+// weights. Cordoned and draining workers, and registered workers that
+// don't serve model, are skipped. Workers are scored on a copy of their
+// state taken under the registry lock.
+func (r *Router) pickBestWorker(model string) *WorkerView {
+	routable := slices.DeleteFunc(r.registry.Routable(), func(w WorkerView) bool { return !w.Serves(model) })
+	if len(routable) == 0 {
 		return nil
 	}
+	now := time.Now()
 
 	// Score all workers
 	type scored struct {
-		worker *WorkerEntry
+		worker WorkerView
 		score  float64
 	}
-	candidates := make([]scored, len(healthy))
-	for i, w := range healthy {
-		candidates[i] = scored{worker: w, score: Score(w.Metrics)}
+	candidates := make([]scored, len(routable))
+	for i, w := range routable {
+		candidates[i] = scored{worker: w, score: r.score(w, now)}
 	}
 
 	// Sort by score descending
@@ -171,11 +377,8 @@ func (r *Router) pickBestWorker() *WorkerEntry {
 		return candidates[i].score > candidates[j].score
 	})
 
-	// Take top-3 (or fewer if less available)
-	topN := 3
-	if topN > len(candidates) {
-		topN = len(candidates)
-	}
+	// Take the top N (or fewer if less available)
+	topN := min(r.cfg.Load().Scoring.Candidates, len(candidates))
 	top := candidates[:topN]
 
 	// Weighted random selection among top-N
@@ -184,7 +387,7 @@ func (r *Router) pickBestWorker() *WorkerEntry {
 	totalWeight := 0.0
 	weights := make([]float64, topN)
 	for i, c := range top {
-		weights[i] = c.score - minScore + 1 // +1 to avoid zero weight
+		weights[i] = (c.score - minScore + 1) * c.worker.Weight // +1 to avoid zero weight
 		totalWeight += weights[i]
 	}
 
@@ -194,45 +397,106 @@ func (r *Router) pickBestWorker() *WorkerEntry {
 	for i, w := range weights {
 		cumulative += w
 		if r_ <= cumulative {
-			return top[i].worker
+			return &top[i].worker
 		}
 	}
 
 	// Fallback: return the best
-	return top[0].worker
+	return &top[0].worker
 }
 
-// broadcastState pushes cluster state to dashboard clients.
-func (r *Router) broadcastState() {
-	workers := r.registry.GetAll()
+// score is a worker's routing score, counting requests routed since its
+// last report as queued and discounting stale metrics.
+func (r *Router) score(w WorkerView, now time.Time) float64 {
+	cfg := r.cfg.Load()
+	s := ScoreWith(cfg.Scoring, w.Metrics, w.EstimatedQueue())
+	return DiscountStale(s, w.MetricsAge(now), cfg.MetricsStaleAfter, cfg.MetricsMaxAge, cfg.Scoring.StalePenalty)
+}
+
+// broadcastState publishes cluster state and pending events to dashboard
+// clients, along with the operator action that caused the push, if any.
+func (r *Router) broadcastState(act *AdminAction) {
+	workers := r.registry.Views()
+	staleAfter := r.cfg.Load().MetricsStaleAfter
+	now := time.Now()
 	state := &ClusterState{
 		Workers:             make([]WorkerState, 0, len(workers)),
 		RoutingDistribution: make(map[string]int64),
 		TotalRequests:       r.totalRequests.Load(),
+		DashboardClients:    r.broadcaster.ClientCount(),
+		Action:              act,
 	}
 
 	for _, w := range workers {
 		ws := WorkerState{
-			Address: w.Address,
-			Healthy: w.Healthy,
+			Address:  w.Entry.Address,
+			Healthy:  w.Healthy,
+			Restarts: w.Restarts,
+			Cordoned: w.Cordoned,
+			Draining: w.Draining,
+			Drained:  w.Drained(),
+			Weight:   w.Weight,
+			Source:   w.Entry.Source,
+			Models:   w.Models,
+		}
+		if !w.UpdatedAt.IsZero() {
+			age := w.MetricsAge(now)
+			ws.MetricsAgeMs = age.Milliseconds()
+			ws.MetricsUpdated = w.UpdatedAt.UnixMilli()
+			ws.Stale = age > staleAfter
+		} else {
+			ws.MetricsAgeMs = -1
+			ws.Stale = true
 		}
 		if w.Metrics != nil {
 			ws.ID = w.Metrics.WorkerId
-			ws.Score = Score(w.Metrics)
+			ws.Score = r.score(w, now)
 			ws.VRAMFreeGB = w.Metrics.VramFreeGb
 			ws.VRAMTotalGB = w.Metrics.VramTotalGb
 			ws.GPUUtilization = w.Metrics.GpuUtilization
 			ws.TemperatureC = w.Metrics.TemperatureC
 			ws.QueueDepth = w.Metrics.QueueDepth
+			ws.EstimatedQueue = w.EstimatedQueue()
+			ws.InFlight = w.Entry.InFlight()
 			ws.AvgLatencyMs = w.Metrics.AvgLatencyMs
 			ws.CurrentBatch = w.Metrics.CurrentBatch
+			ws.MetricsSource = w.Metrics.MetricsSource
+			ws.PowerDrawW = w.Metrics.PowerDrawW
+			ws.PowerLimitW = w.Metrics.PowerLimitW
+			ws.SMClockMHz = w.Metrics.SmClockMhz
+			ws.Throttling = gpu.ThrottleReasonNames(w.Metrics.ThrottleReasons &^ gpu.ThrottleGpuIdle)
+			ws.ECCUncorrected = w.Metrics.EccUncorrected
+			ws.ProcessVRAMGB = w.Metrics.ProcessVramUsedGb
+			ws.DeviceFreeGB = w.Metrics.DeviceVramFreeGb
+			ws.GPUShare = w.Metrics.GpuShare
+			for _, d := range w.Metrics.Devices {
+				ws.Devices = append(ws.Devices, DeviceState{
+					Index:          d.Index,
+					Executor:       d.Executor,
+					VRAMFreeGB:     d.VramFreeGb,
+					VRAMTotalGB:    d.VramTotalGb,
+					GPUUtilization: d.GpuUtilization,
+					TemperatureC:   d.TemperatureC,
+					CurrentBatch:   d.CurrentBatch,
+				})
+			}
 		}
 		state.Workers = append(state.Workers, ws)
 	}
 
+	r.mu.RLock()
 	for addr, counter := range r.routingDistribution {
 		state.RoutingDistribution[addr] = counter.Load()
 	}
+	r.mu.RUnlock()
 
-	r.broadcaster.Broadcast(state)
+	events := r.registry.TakeEvents()
+	if act != nil {
+		msg := fmt.Sprintf("%s %s by %s", act.Action, act.Worker, act.User)
+		if act.Action == "weight" {
+			msg = fmt.Sprintf("weight %s → %g by %s", act.Worker, act.Weight, act.User)
+		}
+		events = append(events, Event{Type: "admin", Worker: act.Worker, Message: msg, At: act.At, Action: act})
+	}
+	r.broadcaster.Publish(state, events)
 }
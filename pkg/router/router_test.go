@@ -0,0 +1,94 @@
+package router
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/config"
+)
+
+// newTestRouter builds a router from env settings on top of the defaults.
+// Workers are dialled lazily, so nothing needs to listen at their
+// addresses. Callers stop the router themselves.
+func newTestRouter(t *testing.T, env map[string]string) *Router {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	cfg, err := config.Load(config.Router, "")
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return r
+}
+
+// eventually fails the test if cond isn't true within two seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// sourceOf returns the source of the worker at addr, read under the
+// registry lock, or "" if there is none.
+func sourceOf(r *Router, addr string) string {
+	var source string
+	r.registry.Control(addr, func(w *WorkerEntry) { source = w.Source })
+	return source
+}
+
+// TestStateReadsDuringUpdates reads worker state the ways routing, the
+// dashboard, history, /metrics and the admin API do while metrics and
+// operator changes arrive. It finds unlocked reads under -race.
+func TestStateReadsDuringUpdates(t *testing.T) {
+	r := newTestRouter(t, map[string]string{
+		"WORKER_ENDPOINTS":    "127.0.0.1:1,127.0.0.1:2",
+		"HISTORY_INTERVAL_MS": "1",
+	})
+	defer r.Stop()
+	go r.recordHistory()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for seq := uint64(1); ; seq++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for _, addr := range []string{"127.0.0.1:1", "127.0.0.1:2"} {
+				r.registry.UpdateMetrics(addr, &pb.WorkerMetrics{Healthy: true, Seq: seq, QueueDepth: int32(seq % 7)})
+				r.registry.Control(addr, func(w *WorkerEntry) {
+					w.Weight = float64(seq%3) + 0.5
+					w.Cordoned = seq%5 == 0
+					w.Models = []string{"m"}
+				})
+			}
+		}
+	}()
+
+	for range 200 {
+		r.pickBestWorker("m")
+		r.broadcastState(nil)
+		r.ServePrometheus(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+		r.handleListWorkers(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/admin/workers", nil))
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -1,19 +1,37 @@
 package router
 
 import (
+	"time"
+
 	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/config"
+	"github.com/kunal/gpu-batch-router/pkg/gpu"
 )
 
 // Score calculates a routing score for a worker based on its current metrics.
 // Higher score = better candidate.
 //
-// Formula:
+// Formula, with the default weights (router.scoring in the config file):
 //   - (vram_free / vram_total) * 100     → more free memory = better
 //   - (queue_depth / 10)                  → longer queue = worse
 //   - (avg_latency_ms / 10)               → higher latency = worse
 //   - (gpu_utilization / 100) * 50        → busier GPU = worse
 //   - 50 if temperature > 80°C           → thermal throttling penalty
+//   - 40 if clocks are thermally throttled (NVML throttle reasons)
+//   - 20 if clocks are held back by the power cap
+//   - 200 if the GPU reports uncorrected ECC errors → only use as last resort
 func Score(m *pb.WorkerMetrics) float64 {
+	return ScoreWithQueue(m, m.GetQueueDepth())
+}
+
+// ScoreWithQueue is Score with the queue depth replaced by an estimate,
+// such as one that counts requests routed since m was collected.
+func ScoreWithQueue(m *pb.WorkerMetrics, queueDepth int32) float64 {
+	return ScoreWith(config.DefaultScoring(), m, queueDepth)
+}
+
+// ScoreWith is ScoreWithQueue with the given weights.
+func ScoreWith(s config.Scoring, m *pb.WorkerMetrics, queueDepth int32) float64 {
 	if m == nil || !m.Healthy {
 		return -1000
 	}
@@ -22,22 +40,51 @@ func Score(m *pb.WorkerMetrics) float64 {
 
 	// Memory headroom (0-100 points)
 	if m.VramTotalGb > 0 {
-		score += (m.VramFreeGb / m.VramTotalGb) * 100
+		score += (m.VramFreeGb / m.VramTotalGb) * s.MemoryWeight
 	}
 
 	// Queue depth penalty
-	score -= float64(m.QueueDepth) / 10
+	score -= float64(queueDepth) * s.QueuePenalty
 
 	// Latency penalty
-	score -= m.AvgLatencyMs / 10
+	score -= m.AvgLatencyMs * s.LatencyPenalty
 
 	// GPU utilization penalty (0-50 points)
-	score -= (m.GpuUtilization / 100) * 50
+	score -= (m.GpuUtilization / 100) * s.UtilizationWeight
 
 	// Thermal throttling penalty
-	if m.TemperatureC > 80 {
-		score -= 50
+	if m.TemperatureC > s.ThermalLimitC {
+		score -= s.ThermalPenalty
+	}
+
+	// Clock throttling reported by the driver — the GPU is already slower
+	// than its numbers suggest
+	if m.ThrottleReasons&gpu.ThrottleThermal != 0 {
+		score -= s.ThrottleThermalPenalty
+	}
+	if m.ThrottleReasons&gpu.ThrottlePower != 0 {
+		score -= s.ThrottlePowerPenalty
+	}
+
+	// Uncorrected ECC errors mean results may be corrupt
+	if m.EccUncorrected > 0 {
+		score -= s.ECCPenalty
 	}
 
 	return score
 }
+
+// DiscountStale lowers score for metrics older than staleAfter, ramping
+// linearly up to penalty at maxAge. Fresh metrics are unaffected. The
+// default penalty (100) is as much as the whole memory-headroom term, so
+// old good numbers can't beat fresh ones.
+func DiscountStale(score float64, age, staleAfter, maxAge time.Duration, penalty float64) float64 {
+	if age <= staleAfter {
+		return score
+	}
+	frac := 1.0
+	if span := maxAge - staleAfter; span > 0 && age < maxAge {
+		frac = float64(age-staleAfter) / float64(span)
+	}
+	return score - frac*penalty
+}
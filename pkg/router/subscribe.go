@@ -0,0 +1,239 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Dashboard WebSocket protocol.
+//
+// A client that never sends anything gets the full ClusterState at the
+// default interval (the original protocol). Sending
+//
+//	{"type": "subscribe", "topics": ["workers", "routing", "events"], "interval_ms": 1000}
+//
+// switches it to snapshots and deltas on the chosen topics: first
+//
+//	{"type": "snapshot", "seq": 1, "server_time": ..., "workers": [...], "routing": {...}, "events": [...]}
+//
+// then, at most once per interval and only when something changed,
+//
+//	{"type": "delta", "seq": 2, "server_time": ..., "workers": [changed...], "removed": [addr...], "routing": {...}, "events": [...]}
+//
+// Deltas carry whole WorkerState objects for workers that changed, routing
+// only if it changed, and new events. Events are pushed straight away
+// rather than waiting for the interval. Subscribing again starts over with
+// a fresh snapshot.
+const (
+	TopicWorkers = "workers"
+	TopicRouting = "routing"
+	TopicEvents  = "events"
+)
+
+// Client push interval bounds.
+const (
+	minPushInterval = 100 * time.Millisecond
+	maxPushInterval = 10 * time.Second
+	recentEvents    = 50 // events replayed in a snapshot
+)
+
+// Event is something that happened, as opposed to a state change.
+type Event struct {
+	Type    string       `json:"type"` // admin, health, restart, config
+	Worker  string       `json:"worker"`
+	Message string       `json:"message"`
+	At      time.Time    `json:"at"`
+	Action  *AdminAction `json:"action,omitempty"`
+}
+
+// RoutingState is the routing topic payload.
+type RoutingState struct {
+	RoutingDistribution map[string]int64 `json:"routing_distribution"`
+	TotalRequests       int64            `json:"total_requests"`
+	DashboardClients    int              `json:"dashboard_clients"`
+}
+
+// workerView is WorkerState as sent to subscribed clients. The metrics age
+// grows on every tick, which would make every worker differ every time, so
+// it's dropped; clients derive it from metrics_updated_at and server_time.
+type workerView struct {
+	WorkerState
+	MetricsAgeMs int64 `json:"metrics_age_ms,omitempty"` // shadows WorkerState's; always zero
+}
+
+type clientRequest struct {
+	Type       string   `json:"type"`
+	Topics     []string `json:"topics"`
+	IntervalMs int      `json:"interval_ms"`
+}
+
+type updateMessage struct {
+	Type       string            `json:"type"` // snapshot or delta
+	Seq        uint64            `json:"seq"`
+	ServerTime int64             `json:"server_time"` // unix ms
+	Workers    []json.RawMessage `json:"workers,omitempty"`
+	Removed    []string          `json:"removed,omitempty"`
+	Routing    json.RawMessage   `json:"routing,omitempty"`
+	Events     []Event           `json:"events,omitempty"`
+}
+
+// subscription is a client's protocol state. Guarded by mu: the read loop
+// changes it, the publisher reads it.
+type subscription struct {
+	mu       sync.Mutex
+	legacy   bool // never subscribed: gets full ClusterState
+	topics   map[string]bool
+	interval time.Duration
+	next     time.Time // earliest time for the next periodic push
+
+	snapshotSent bool
+	seq          uint64
+	lastWorkers  map[string][]byte // worker JSON as last sent
+	lastRouting  []byte
+}
+
+func newSubscription(interval time.Duration) *subscription {
+	return &subscription{legacy: true, interval: interval}
+}
+
+// apply handles a subscribe request, resetting the client to a fresh snapshot.
+func (s *subscription) apply(req clientRequest, defaultInterval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.legacy = false
+	s.topics = make(map[string]bool)
+	for _, t := range req.Topics {
+		if t == TopicWorkers || t == TopicRouting || t == TopicEvents {
+			s.topics[t] = true
+		}
+	}
+	s.interval = defaultInterval
+	if req.IntervalMs > 0 {
+		s.interval = min(max(time.Duration(req.IntervalMs)*time.Millisecond, minPushInterval), maxPushInterval)
+	}
+	s.snapshotSent = false
+	s.next = time.Time{}
+}
+
+// frame is one published state, with JSON encoded once and shared by all
+// clients.
+type frame struct {
+	now     time.Time
+	state   *ClusterState
+	events  []Event
+	recent  []Event // for snapshots
+	legacy  []byte
+	order   []string // worker addresses, sorted
+	workers map[string][]byte
+	routing []byte
+}
+
+func newFrame(state *ClusterState, events, recent []Event) (*frame, error) {
+	f := &frame{
+		now:     time.Now(),
+		state:   state,
+		events:  events,
+		recent:  recent,
+		workers: make(map[string][]byte, len(state.Workers)),
+	}
+	for _, w := range state.Workers {
+		data, err := json.Marshal(workerView{WorkerState: w})
+		if err != nil {
+			return nil, err
+		}
+		f.workers[w.Address] = data
+		f.order = append(f.order, w.Address)
+	}
+	slices.Sort(f.order)
+	routing, err := json.Marshal(RoutingState{
+		RoutingDistribution: state.RoutingDistribution,
+		TotalRequests:       state.TotalRequests,
+		DashboardClients:    state.DashboardClients,
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.routing = routing
+	return f, nil
+}
+
+// legacyJSON marshals the full state on first use.
+func (f *frame) legacyJSON() ([]byte, error) {
+	if f.legacy == nil {
+		data, err := json.Marshal(f.state)
+		if err != nil {
+			return nil, err
+		}
+		f.legacy = data
+	}
+	return f.legacy, nil
+}
+
+// message returns what the client should be sent for f, or nil if nothing
+// is due. The second result names the message type for accounting.
+func (s *subscription) message(f *frame) ([]byte, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	urgent := len(f.events) > 0 && (s.legacy || s.topics[TopicEvents])
+	if f.now.Before(s.next) && !urgent {
+		return nil, "", nil
+	}
+
+	if s.legacy {
+		s.next = f.now.Add(s.interval)
+		data, err := f.legacyJSON()
+		return data, "legacy", err
+	}
+
+	msg := updateMessage{Type: "delta", ServerTime: f.now.UnixMilli()}
+	if !s.snapshotSent {
+		msg.Type = "snapshot"
+		s.lastWorkers = make(map[string][]byte)
+		s.lastRouting = nil
+	}
+	changed := false
+
+	if s.topics[TopicWorkers] {
+		for _, addr := range f.order {
+			data := f.workers[addr]
+			if prev, ok := s.lastWorkers[addr]; !ok || !bytes.Equal(prev, data) {
+				msg.Workers = append(msg.Workers, data)
+				s.lastWorkers[addr] = data
+			}
+		}
+		for addr := range s.lastWorkers {
+			if _, ok := f.workers[addr]; !ok {
+				msg.Removed = append(msg.Removed, addr)
+				delete(s.lastWorkers, addr)
+			}
+		}
+		changed = changed || len(msg.Workers) > 0 || len(msg.Removed) > 0
+	}
+	if s.topics[TopicRouting] && !bytes.Equal(s.lastRouting, f.routing) {
+		msg.Routing = f.routing
+		s.lastRouting = f.routing
+		changed = true
+	}
+	if s.topics[TopicEvents] {
+		if msg.Type == "snapshot" {
+			msg.Events = f.recent
+		} else {
+			msg.Events = f.events
+		}
+		changed = changed || len(msg.Events) > 0
+	}
+
+	if msg.Type == "delta" && !changed {
+		return nil, "", nil
+	}
+	s.snapshotSent = true
+	s.seq++
+	msg.Seq = s.seq
+	s.next = f.now.Add(s.interval)
+	data, err := json.Marshal(msg)
+	return data, msg.Type, err
+}
@@ -0,0 +1,160 @@
+package router
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+// clusterFrame builds a frame at now from the given workers and routing.
+func clusterFrame(t *testing.T, now time.Time, total int64, workers []WorkerState, events ...Event) *frame {
+	t.Helper()
+	f, err := newFrame(&ClusterState{
+		Workers:             workers,
+		RoutingDistribution: map[string]int64{"a:1": total},
+		TotalRequests:       total,
+	}, events, nil)
+	if err != nil {
+		t.Fatalf("newFrame: %v", err)
+	}
+	f.now = now
+	return f
+}
+
+// decode unmarshals a message, with its workers keyed by address.
+func decode(t *testing.T, data []byte) (updateMessage, map[string]WorkerState) {
+	t.Helper()
+	var msg updateMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("bad message %s: %v", data, err)
+	}
+	workers := make(map[string]WorkerState)
+	for _, raw := range msg.Workers {
+		var w WorkerState
+		if err := json.Unmarshal(raw, &w); err != nil {
+			t.Fatal(err)
+		}
+		workers[w.Address] = w
+	}
+	return msg, workers
+}
+
+func TestSubscriptionDelta(t *testing.T) {
+	s := newSubscription(time.Second)
+	s.apply(clientRequest{Type: "subscribe", Topics: []string{TopicWorkers, TopicRouting}, IntervalMs: 500}, time.Second)
+
+	now := time.Now()
+	a := WorkerState{ID: "a", Address: "a:1", QueueDepth: 1, Healthy: true}
+	b := WorkerState{ID: "b", Address: "b:1", QueueDepth: 2, Healthy: true}
+	data, typ, err := s.message(clusterFrame(t, now, 10, []WorkerState{a, b}))
+	if err != nil || typ != "snapshot" {
+		t.Fatalf("first message: type %q, err %v; want a snapshot", typ, err)
+	}
+	msg, workers := decode(t, data)
+	if msg.Seq != 1 || len(workers) != 2 || msg.Routing == nil {
+		t.Fatalf("snapshot: seq %d, %d workers, routing %s; want seq 1, both workers and routing", msg.Seq, len(workers), msg.Routing)
+	}
+
+	// a changed, b removed, c added; routing unchanged
+	a.QueueDepth = 5
+	c := WorkerState{ID: "c", Address: "c:1", Healthy: true}
+	next := []WorkerState{a, c}
+
+	// Not yet due
+	if data, _, _ := s.message(clusterFrame(t, now.Add(499*time.Millisecond), 10, next)); data != nil {
+		t.Fatalf("sent before the interval: %s", data)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	data, typ, err = s.message(clusterFrame(t, now, 10, next))
+	if err != nil || typ != "delta" {
+		t.Fatalf("second message: type %q, err %v; want a delta", typ, err)
+	}
+	msg, workers = decode(t, data)
+	if msg.Seq != 2 {
+		t.Errorf("seq %d, want 2", msg.Seq)
+	}
+	if len(workers) != 2 || workers["a:1"].QueueDepth != 5 || workers["c:1"].ID != "c" {
+		t.Errorf("delta workers %+v, want only the changed a and the added c", workers)
+	}
+	if !slices.Equal(msg.Removed, []string{"b:1"}) {
+		t.Errorf("removed %v, want [b:1]", msg.Removed)
+	}
+	if msg.Routing != nil {
+		t.Errorf("unchanged routing sent: %s", msg.Routing)
+	}
+
+	// Nothing changed: nothing sent, and the sequence doesn't move
+	now = now.Add(time.Second)
+	if data, _, _ := s.message(clusterFrame(t, now, 10, next)); data != nil {
+		t.Fatalf("sent with nothing changed: %s", data)
+	}
+
+	// Only routing changed
+	now = now.Add(time.Second)
+	data, _, _ = s.message(clusterFrame(t, now, 11, next))
+	msg, workers = decode(t, data)
+	if msg.Seq != 3 || len(workers) != 0 || msg.Removed != nil || msg.Routing == nil {
+		t.Errorf("routing delta: seq %d, workers %v, removed %v, routing %s; want seq 3 and routing only", msg.Seq, workers, msg.Removed, msg.Routing)
+	}
+
+	// A removed worker that comes back is sent whole again
+	now = now.Add(time.Second)
+	data, _, _ = s.message(clusterFrame(t, now, 11, []WorkerState{a, b, c}))
+	msg, workers = decode(t, data)
+	if len(workers) != 1 || workers["b:1"].QueueDepth != 2 {
+		t.Errorf("workers %+v, want b back", workers)
+	}
+
+	// Subscribing again starts over from a snapshot
+	s.apply(clientRequest{Type: "subscribe", Topics: []string{TopicWorkers}}, time.Second)
+	data, typ, _ = s.message(clusterFrame(t, now, 11, []WorkerState{a, b, c}))
+	msg, workers = decode(t, data)
+	if typ != "snapshot" || len(workers) != 3 || msg.Routing != nil {
+		t.Errorf("resubscribe: type %q, %d workers, routing %s; want a snapshot of all workers without routing", typ, len(workers), msg.Routing)
+	}
+}
+
+func TestSubscriptionEventsBypassInterval(t *testing.T) {
+	s := newSubscription(time.Second)
+	s.apply(clientRequest{Type: "subscribe", Topics: []string{TopicWorkers, TopicEvents}, IntervalMs: 5000}, time.Second)
+	now := time.Now()
+	workers := []WorkerState{{ID: "a", Address: "a:1"}}
+	if data, _, _ := s.message(clusterFrame(t, now, 0, workers)); data == nil {
+		t.Fatal("no snapshot")
+	}
+
+	ev := Event{Type: "health", Worker: "a:1", Message: "worker unhealthy", At: now}
+	workers[0].Healthy = false
+	workers[0].QueueDepth = 3
+	data, typ, _ := s.message(clusterFrame(t, now.Add(time.Millisecond), 0, workers, ev))
+	if typ != "delta" {
+		t.Fatalf("event within the interval: type %q, want a delta", typ)
+	}
+	msg, got := decode(t, data)
+	if len(msg.Events) != 1 || msg.Events[0].Message != ev.Message {
+		t.Errorf("events %+v, want the health event", msg.Events)
+	}
+	if got["a:1"].QueueDepth != 3 {
+		t.Errorf("the worker change wasn't sent with the event: %+v", got)
+	}
+}
+
+func TestLegacySubscription(t *testing.T) {
+	s := newSubscription(time.Second)
+	now := time.Now()
+	f := clusterFrame(t, now, 7, []WorkerState{{ID: "a", Address: "a:1"}})
+	data, typ, err := s.message(f)
+	if err != nil || typ != "legacy" {
+		t.Fatalf("type %q, err %v; want legacy", typ, err)
+	}
+	var state ClusterState
+	if err := json.Unmarshal(data, &state); err != nil || state.TotalRequests != 7 || len(state.Workers) != 1 {
+		t.Errorf("legacy message %s, want the full cluster state", data)
+	}
+	// Sent every interval even when nothing changed
+	if data, _, _ := s.message(clusterFrame(t, now.Add(time.Second), 7, state.Workers)); data == nil {
+		t.Error("legacy client not sent the unchanged state")
+	}
+}
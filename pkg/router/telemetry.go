@@ -0,0 +1,163 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/metrics"
+	"github.com/kunal/gpu-batch-router/pkg/tracing"
+	"google.golang.org/grpc/status"
+)
+
+// telemetry holds the router's tracer and Prometheus instruments. Workers
+// are labelled by their configured address, the same key the registry uses.
+type telemetry struct {
+	tracer   *tracing.Tracer
+	registry *metrics.Registry
+
+	requests           *metrics.CounterVec   // priority, outcome
+	requestDuration    *metrics.HistogramVec // priority, outcome
+	requestErrors      *metrics.CounterVec   // code
+	retries            *metrics.CounterVec   // (none)
+	forwardFailures    *metrics.CounterVec   // worker, code
+	routed             *metrics.CounterVec   // worker
+	decision           *metrics.HistogramVec // (none)
+	transitions        *metrics.CounterVec   // worker, to
+	pollFailures       *metrics.CounterVec   // worker
+	streamErrors       *metrics.CounterVec   // worker
+	restarts           *metrics.CounterVec   // worker
+	outOfOrder         *metrics.CounterVec   // worker
+	adminActions       *metrics.CounterVec   // action
+	dashboardEvictions *metrics.CounterVec   // (none)
+	dashboardBytes     *metrics.CounterVec   // type
+	authRejections     *metrics.CounterVec   // reason
+	registrations      *metrics.CounterVec   // event
+	discoveryLookups   *metrics.CounterVec   // outcome
+
+	healthy   *metrics.GaugeVec // worker
+	score     *metrics.GaugeVec // worker
+	streaming *metrics.GaugeVec // worker
+	age       *metrics.GaugeVec // worker
+	inFlight  *metrics.GaugeVec // worker
+	estQueue  *metrics.GaugeVec // worker
+}
+
+// Decisions are in-memory sorts, so use a finer scale than request latency.
+var decisionBuckets = []float64{1e-6, 5e-6, 1e-5, 2.5e-5, 5e-5, 1e-4, 2.5e-4, 5e-4, 1e-3, 5e-3}
+
+func newTelemetry(tracer *tracing.Tracer) *telemetry {
+	r := metrics.NewRegistry(nil)
+	return &telemetry{
+		tracer:   tracer,
+		registry: r,
+
+		requests: r.NewCounterVec("router_requests_total",
+			"Inference requests received by the router", "priority", "outcome"),
+		requestDuration: r.NewHistogramVec("router_request_duration_seconds",
+			"End-to-end request latency through the router, including retries", metrics.LatencyBuckets,
+			"priority", "outcome"),
+		requestErrors: r.NewCounterVec("router_request_errors_total",
+			"Requests that failed, by gRPC status code returned to the client", "code"),
+		retries: r.NewCounterVec("router_retries_total",
+			"Forward attempts beyond the first for a request"),
+		forwardFailures: r.NewCounterVec("router_forward_failures_total",
+			"Failed forward attempts to a worker, by gRPC status code", "worker", "code"),
+		routed: r.NewCounterVec("router_routed_requests_total",
+			"Requests successfully served by each worker", "worker"),
+		decision: r.NewHistogramVec("router_routing_decision_seconds",
+			"Time spent scoring and picking a worker", decisionBuckets),
+		transitions: r.NewCounterVec("router_worker_health_transitions_total",
+			"Worker health state changes", "worker", "to"),
+		pollFailures: r.NewCounterVec("router_poll_failures_total",
+			"Failed metrics polls per worker", "worker"),
+		streamErrors: r.NewCounterVec("router_metrics_stream_errors_total",
+			"WatchMetrics streams that broke or stalled, per worker", "worker"),
+		restarts: r.NewCounterVec("router_worker_restarts_total",
+			"Worker process restarts detected from a changed start time", "worker"),
+		outOfOrder: r.NewCounterVec("router_metrics_out_of_order_total",
+			"Metrics snapshots dropped because a newer one was already cached", "worker"),
+		registrations: r.NewCounterVec("router_worker_registrations_total",
+			"Worker self-registration events: register, deregister, expire", "event"),
+		discoveryLookups: r.NewCounterVec("router_discovery_lookups_total",
+			"DNS discovery lookups of the worker name, by outcome (ok, error)", "outcome"),
+		adminActions: r.NewCounterVec("router_admin_actions_total",
+			"Operator actions applied through the admin API", "action"),
+		dashboardEvictions: r.NewCounterVec("router_dashboard_evictions_total",
+			"Dashboard clients disconnected for falling behind on updates"),
+		dashboardBytes: r.NewCounterVec("router_dashboard_sent_bytes_total",
+			"Bytes queued to dashboard clients, by message type (legacy, snapshot, delta)", "type"),
+		authRejections: r.NewCounterVec("router_auth_rejections_total",
+			"gRPC calls rejected by authentication or tenant limits", "reason"),
+
+		healthy: r.NewGaugeVec("router_worker_healthy",
+			"Whether the router considers a worker healthy (1) or not (0)", "worker"),
+		score: r.NewGaugeVec("router_worker_score",
+			"Current routing score per worker (higher is preferred)", "worker"),
+		streaming: r.NewGaugeVec("router_worker_metrics_streaming",
+			"Whether a worker's metrics arrive over a live stream (1) or polling/reconnecting (0)", "worker"),
+		age: r.NewGaugeVec("router_worker_metrics_age_seconds",
+			"Time since the router last received metrics from a worker", "worker"),
+		inFlight: r.NewGaugeVec("router_worker_in_flight",
+			"Requests the router has forwarded to a worker and not yet seen finish", "worker"),
+		estQueue: r.NewGaugeVec("router_worker_estimated_queue",
+			"Reported queue depth plus requests routed since the report, as used for scoring", "worker"),
+	}
+}
+
+// observeRequest records the final outcome of one routed request.
+func (t *telemetry) observeRequest(req *pb.InferRequest, elapsed time.Duration, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+		t.requestErrors.With(status.Code(err).String()).Inc()
+	}
+	pri := req.Priority.String()
+	t.requests.With(pri, outcome).Inc()
+	t.requestDuration.With(pri, outcome).Observe(elapsed.Seconds())
+}
+
+// healthChanged records a worker moving between healthy and unhealthy.
+func (t *telemetry) healthChanged(addr string, healthy bool) {
+	to := "unhealthy"
+	if healthy {
+		to = "healthy"
+	}
+	t.transitions.With(addr, to).Inc()
+}
+
+// forgetWorker drops every series labelled with addr, once the worker has
+// been removed, so its last values aren't exported forever.
+func (t *telemetry) forgetWorker(addr string) {
+	for _, v := range []*metrics.ValueVec{
+		t.forwardFailures, t.routed, t.transitions, t.pollFailures, t.streamErrors,
+		t.restarts, t.outOfOrder,
+		t.healthy, t.score, t.streaming, t.age, t.inFlight, t.estQueue,
+	} {
+		v.DeleteMatching("worker", addr)
+	}
+}
+
+// ServePrometheus writes the router's metrics, refreshing per-worker
+// health, score, metrics-age and in-flight gauges first.
+func (r *Router) ServePrometheus(w http.ResponseWriter, req *http.Request) {
+	now := time.Now()
+	for _, wk := range r.registry.Views() {
+		if wk.Removing {
+			continue // its series go once it is removed
+		}
+		addr := wk.Entry.Address
+		healthy := 0.0
+		if wk.Healthy {
+			healthy = 1
+		}
+		r.tel.healthy.With(addr).Set(healthy)
+		r.tel.score.With(addr).Set(r.score(wk, now))
+		r.tel.inFlight.With(addr).Set(float64(wk.Entry.InFlight()))
+		r.tel.estQueue.With(addr).Set(float64(wk.EstimatedQueue()))
+		if !wk.UpdatedAt.IsZero() {
+			r.tel.age.With(addr).Set(wk.MetricsAge(now).Seconds())
+		}
+	}
+	r.tel.registry.ServeHTTP(w, req)
+}
@@ -0,0 +1,253 @@
+// Package tlsutil builds TLS configs for the gRPC links from PEM files and
+// keeps them current when the files are rotated.
+//
+// A Reloader re-reads its certificate, key and CA bundle whenever one of
+// the files changes on disk (cert-manager and Kubernetes secret mounts
+// replace them in place), so new handshakes pick up rotated certificates
+// without a restart. Established connections keep the certificate they
+// were made with. If a reload fails the previous material stays in use.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+)
+
+// Files names the PEM files a Reloader loads. Cert and Key go together
+// and may both be empty (a client with no certificate of its own). CA, if
+// set, is the bundle peers are verified against: client certificates on a
+// server, server certificates on a client.
+type Files struct {
+	Cert string
+	Key  string
+	CA   string
+}
+
+func (f Files) paths() []string {
+	var out []string
+	for _, p := range []string{f.Cert, f.Key, f.CA} {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Reloader holds TLS material loaded from Files.
+type Reloader struct {
+	files Files
+	log   *slog.Logger
+
+	mu    sync.RWMutex
+	cert  *tls.Certificate // nil without Cert/Key
+	pool  *x509.CertPool   // nil without CA
+	stamp map[string]fileStamp
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// NewReloader loads files and, if interval is positive, checks them for
+// changes every interval until Close.
+func NewReloader(files Files, interval time.Duration) (*Reloader, error) {
+	if (files.Cert == "") != (files.Key == "") {
+		return nil, errors.New("certificate and key files must be set together")
+	}
+	r := &Reloader{
+		files: files,
+		log:   logging.For("tls"),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		go r.watch(interval)
+	} else {
+		close(r.done)
+	}
+	return r, nil
+}
+
+// Close stops watching the files.
+func (r *Reloader) Close() {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	<-r.done
+}
+
+func (r *Reloader) watch(interval time.Duration) {
+	defer close(r.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-t.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.load(); err != nil {
+				r.log.Warn("certificate reload failed; keeping previous", "err", err)
+			}
+		}
+	}
+}
+
+// changed reports whether any file's size or modification time differs
+// from when it was last loaded.
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, p := range r.files.paths() {
+		st, err := os.Stat(p)
+		if err != nil {
+			// Mid-rotation, or gone; try again next tick
+			continue
+		}
+		if prev := r.stamp[p]; !st.ModTime().Equal(prev.mod) || st.Size() != prev.size {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) load() error {
+	stamp := make(map[string]fileStamp)
+	for _, p := range r.files.paths() {
+		st, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		stamp[p] = fileStamp{mod: st.ModTime(), size: st.Size()}
+	}
+
+	var cert *tls.Certificate
+	if r.files.Cert != "" {
+		c, err := tls.LoadX509KeyPair(r.files.Cert, r.files.Key)
+		if err != nil {
+			return fmt.Errorf("load key pair %s: %w", r.files.Cert, err)
+		}
+		cert = &c
+	}
+	var pool *x509.CertPool
+	if r.files.CA != "" {
+		pem, err := os.ReadFile(r.files.CA)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", r.files.CA)
+		}
+	}
+
+	r.mu.Lock()
+	reload := r.stamp != nil
+	r.cert, r.pool, r.stamp = cert, pool, stamp
+	r.mu.Unlock()
+
+	attrs := []any{"cert", r.files.Cert, "ca", r.files.CA}
+	if cert != nil {
+		attrs = append(attrs, "subject", cert.Leaf.Subject.String(), "not_after", cert.Leaf.NotAfter)
+		if time.Until(cert.Leaf.NotAfter) < 7*24*time.Hour {
+			r.log.Warn("certificate expires soon", "cert", r.files.Cert, "not_after", cert.Leaf.NotAfter)
+		}
+	}
+	if reload {
+		r.log.Info("certificates reloaded", attrs...)
+	} else {
+		r.log.Debug("certificates loaded", attrs...)
+	}
+	return nil
+}
+
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.pool
+}
+
+// ServerConfig returns a config serving the current certificate. With a CA
+// bundle, clients must present a certificate signed by it (mTLS).
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			if cert == nil {
+				return nil, errors.New("no server certificate configured")
+			}
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+			}
+			if pool != nil {
+				cfg.ClientCAs = pool
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// ClientConfig returns a config for connecting to a server that must
+// prove it is serverName, presenting the current certificate if the
+// server asks for one. Without a CA bundle the system roots are used.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert, _ := r.current(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil // none: the server decides whether that's fine
+		},
+	}
+	if r.files.CA == "" {
+		return cfg
+	}
+	// The CA bundle can change after the config is built, so verification
+	// is done here against the current pool instead of through RootCAs.
+	// InsecureSkipVerify only turns off the built-in check; VerifyConnection
+	// repeats it in full, including the name.
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		_, pool := r.current()
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("server presented no certificate")
+		}
+		opts := x509.VerifyOptions{
+			DNSName:       serverName,
+			Roots:         pool,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, c := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(c)
+		}
+		if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+			return fmt.Errorf("server certificate is not valid for %q: %w", serverName, err)
+		}
+		return nil
+	}
+	return cfg
+}
@@ -0,0 +1,316 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA is a throwaway certificate authority.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var serial int64
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key := newKey(t)
+	serial++
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for name, valid as a server
+// certificate for the DNS names and as a client certificate.
+func (ca *testCA) issue(t *testing.T, name string, dnsNames ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key := newKey(t)
+	serial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// write writes content to name in dir, with a modification time after
+// any earlier write so a watching Reloader sees the change.
+func write(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	var mod time.Time
+	if st, err := os.Stat(path); err == nil {
+		mod = st.ModTime().Add(time.Second)
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if !mod.IsZero() {
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+// tlsFiles writes a certificate, its key and optionally a CA bundle to a
+// new directory and returns their Files.
+func tlsFiles(t *testing.T, certPEM, keyPEM, caPEM []byte) Files {
+	t.Helper()
+	dir := t.TempDir()
+	var f Files
+	if certPEM != nil {
+		f.Cert = write(t, dir, "tls.crt", certPEM)
+		f.Key = write(t, dir, "tls.key", keyPEM)
+	}
+	if caPEM != nil {
+		f.CA = write(t, dir, "ca.crt", caPEM)
+	}
+	return f
+}
+
+func newTestReloader(t *testing.T, f Files, interval time.Duration) *Reloader {
+	t.Helper()
+	r, err := NewReloader(f, interval)
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	t.Cleanup(r.Close)
+	return r
+}
+
+// handshake runs a TLS handshake between server and client over loopback
+// and returns the client's error, or the server's if only the server
+// failed.
+func handshake(t *testing.T, server, client *tls.Config) error {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	cc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	sc.SetDeadline(deadline)
+	cc.SetDeadline(deadline)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		s := tls.Server(sc, server)
+		err := s.Handshake()
+		if err == nil {
+			// TLS 1.3 servers verify the client certificate after the
+			// client considers the handshake done; read to see the result
+			_, err = s.Read(make([]byte, 1))
+		}
+		sc.Close()
+		serverErr <- err
+	}()
+	c := tls.Client(cc, client)
+	err = c.Handshake()
+	if err == nil {
+		_, err = c.Write([]byte{0})
+	}
+	cc.Close()
+	if sErr := <-serverErr; err == nil {
+		return sErr
+	}
+	return err
+}
+
+func TestClientVerifiesServer(t *testing.T) {
+	ca, other := newCA(t, "test CA"), newCA(t, "other CA")
+	certPEM, keyPEM := ca.issue(t, "worker", "worker.example")
+	otherCert, otherKey := other.issue(t, "impostor", "worker.example")
+	client := newTestReloader(t, tlsFiles(t, nil, nil, ca.pem), 0)
+
+	tests := []struct {
+		name       string
+		server     Files
+		serverName string
+		wantErr    bool
+	}{
+		{"trusted CA and name", tlsFiles(t, certPEM, keyPEM, nil), "worker.example", false},
+		{"untrusted CA", tlsFiles(t, otherCert, otherKey, nil), "worker.example", true},
+		{"wrong SAN", tlsFiles(t, certPEM, keyPEM, nil), "other.example", true},
+		{"name only in the CN", tlsFiles(t, certPEM, keyPEM, nil), "worker", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestReloader(t, tt.server, 0)
+			err := handshake(t, server.ServerConfig(), client.ClientConfig(tt.serverName))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("handshake error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Without a CA bundle the system roots apply, which don't include ours
+	system := newTestReloader(t, Files{}, 0)
+	server := newTestReloader(t, tlsFiles(t, certPEM, keyPEM, nil), 0)
+	if err := handshake(t, server.ServerConfig(), system.ClientConfig("worker.example")); err == nil {
+		t.Error("a certificate from a private CA was trusted without the CA bundle")
+	}
+}
+
+func TestServerRequiresClientCert(t *testing.T) {
+	ca, other := newCA(t, "test CA"), newCA(t, "other CA")
+	serverCert, serverKey := ca.issue(t, "router", "router.example")
+	clientCert, clientKey := ca.issue(t, "worker")
+	otherCert, otherKey := other.issue(t, "impostor")
+
+	mtls := newTestReloader(t, tlsFiles(t, serverCert, serverKey, ca.pem), 0)
+	plain := newTestReloader(t, tlsFiles(t, serverCert, serverKey, nil), 0)
+	tests := []struct {
+		name    string
+		server  *Reloader
+		client  Files
+		wantErr bool
+	}{
+		{"client cert from the CA", mtls, tlsFiles(t, clientCert, clientKey, ca.pem), false},
+		{"no client cert", mtls, tlsFiles(t, nil, nil, ca.pem), true},
+		{"client cert from another CA", mtls, tlsFiles(t, otherCert, otherKey, ca.pem), true},
+		{"no CA on the server", plain, tlsFiles(t, nil, nil, ca.pem), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestReloader(t, tt.client, 0)
+			err := handshake(t, tt.server.ServerConfig(), client.ClientConfig("router.example"))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("handshake error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReloaderPicksUpRotation(t *testing.T) {
+	caOld, caNew := newCA(t, "old CA"), newCA(t, "new CA")
+	oldCert, oldKey := caOld.issue(t, "worker", "worker.example")
+	newCert, newKey := caNew.issue(t, "worker", "worker.example")
+
+	serverFiles := tlsFiles(t, oldCert, oldKey, nil)
+	clientFiles := tlsFiles(t, nil, nil, caOld.pem)
+	server := newTestReloader(t, serverFiles, 10*time.Millisecond)
+	client := newTestReloader(t, clientFiles, 10*time.Millisecond)
+	// Configs are built once, as the gRPC servers and dialers do
+	serverCfg, clientCfg := server.ServerConfig(), client.ClientConfig("worker.example")
+	if err := handshake(t, serverCfg, clientCfg); err != nil {
+		t.Fatalf("before rotation: %v", err)
+	}
+
+	eventually := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The server moves to a certificate from the new CA first: the client
+	// stops trusting it until its CA bundle is rotated too
+	write(t, filepath.Dir(serverFiles.Cert), "tls.crt", newCert)
+	write(t, filepath.Dir(serverFiles.Key), "tls.key", newKey)
+	eventually("the server certificate to rotate", func() bool {
+		cert, _ := server.current()
+		return cert.Leaf.Issuer.CommonName == "new CA"
+	})
+	if err := handshake(t, serverCfg, clientCfg); err == nil {
+		t.Fatal("the client trusted the new CA before its bundle was rotated")
+	}
+
+	// A bundle that doesn't parse keeps the previous one
+	write(t, filepath.Dir(clientFiles.CA), "ca.crt", []byte("not a certificate"))
+	time.Sleep(50 * time.Millisecond)
+	if _, pool := client.current(); pool == nil {
+		t.Fatal("a bad bundle dropped the CA pool")
+	}
+
+	write(t, filepath.Dir(clientFiles.CA), "ca.crt", caNew.pem)
+	eventually("the client to trust the new CA", func() bool {
+		return handshake(t, serverCfg, clientCfg) == nil
+	})
+}
+
+func TestNewReloaderErrors(t *testing.T) {
+	ca := newCA(t, "test CA")
+	certPEM, _ := ca.issue(t, "worker", "worker.example")
+	_, otherKey := ca.issue(t, "other")
+	dir := t.TempDir()
+
+	tests := []struct {
+		name  string
+		files Files
+	}{
+		{"cert without key", Files{Cert: write(t, dir, "a.crt", certPEM)}},
+		{"key that doesn't match", Files{Cert: write(t, dir, "b.crt", certPEM), Key: write(t, dir, "b.key", otherKey)}},
+		{"missing file", Files{CA: filepath.Join(dir, "missing.crt")}},
+		{"empty CA bundle", Files{CA: write(t, dir, "empty.crt", []byte("no PEM here"))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if r, err := NewReloader(tt.files, 0); err == nil {
+				r.Close()
+				t.Error("NewReloader succeeded")
+			}
+		})
+	}
+}
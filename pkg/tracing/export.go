@@ -0,0 +1,274 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+)
+
+// Export formats understood by NewFileExporter.
+const (
+	FormatJSON = "json" // one flat span object per line
+	FormatOTLP = "otlp" // one OTLP/JSON ExportTraceServiceRequest per line
+)
+
+// FileExporter appends finished spans to a file from a background
+// goroutine, so request paths never wait on disk. Spans are dropped (and
+// counted) if the buffer fills up.
+type FileExporter struct {
+	format string
+	file   *os.File
+	ch     chan SpanData
+	done   chan struct{}
+
+	mu      sync.RWMutex // guards closed vs. sends on ch
+	closed  bool
+	Dropped atomic.Int64
+}
+
+// NewFileExporter opens path for appending and starts the writer.
+func NewFileExporter(path, format string) (*FileExporter, error) {
+	if format != FormatJSON && format != FormatOTLP {
+		return nil, fmt.Errorf("unknown trace format %q (want %q or %q)", format, FormatJSON, FormatOTLP)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	e := &FileExporter{
+		format: format,
+		file:   f,
+		ch:     make(chan SpanData, 4096),
+		done:   make(chan struct{}),
+	}
+	go e.loop()
+	return e, nil
+}
+
+// Export queues a span for writing.
+func (e *FileExporter) Export(s SpanData) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.ch <- s:
+	default:
+		e.Dropped.Add(1)
+	}
+}
+
+// Shutdown writes out queued spans and closes the file.
+func (e *FileExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.ch)
+	e.mu.Unlock()
+
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if n := e.Dropped.Load(); n > 0 {
+		logging.For("tracing").Warn("dropped spans, export buffer full", "dropped", n)
+	}
+	return e.file.Close()
+}
+
+func (e *FileExporter) loop() {
+	defer close(e.done)
+	w := bufio.NewWriter(e.file)
+	enc := json.NewEncoder(w)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case s, ok := <-e.ch:
+			if !ok {
+				w.Flush()
+				return
+			}
+			var err error
+			if e.format == FormatOTLP {
+				err = enc.Encode(toOTLP(s))
+			} else {
+				err = enc.Encode(toJSON(s))
+			}
+			if err != nil {
+				logging.For("tracing").Warn("span write failed", "err", err)
+			}
+		case <-ticker.C:
+			w.Flush()
+		}
+	}
+}
+
+// --- flat JSON -------------------------------------------------------------------
+
+type jsonLink struct {
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id"`
+}
+
+type jsonSpan struct {
+	Service    string         `json:"service"`
+	Name       string         `json:"name"`
+	Kind       string         `json:"kind"`
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_span_id,omitempty"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	DurationMs float64        `json:"duration_ms"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Links      []jsonLink     `json:"links,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+func toJSON(s SpanData) jsonSpan {
+	js := jsonSpan{
+		Service:    s.Service,
+		Name:       s.Name,
+		Kind:       s.Kind.String(),
+		TraceID:    s.TraceID.String(),
+		SpanID:     s.SpanID.String(),
+		Start:      s.Start,
+		End:        s.End,
+		DurationMs: float64(s.End.Sub(s.Start).Microseconds()) / 1000,
+		Attributes: s.Attributes,
+	}
+	if s.ParentID != (SpanID{}) {
+		js.ParentID = s.ParentID.String()
+	}
+	for _, l := range s.Links {
+		js.Links = append(js.Links, jsonLink{l.TraceID.String(), l.SpanID.String()})
+	}
+	if s.Error {
+		js.Error = s.ErrorMsg
+		if js.Error == "" {
+			js.Error = "error"
+		}
+	}
+	return js
+}
+
+// --- OTLP/JSON ---------------------------------------------------------------------
+//
+// Mirrors the protobuf JSON mapping of opentelemetry.proto.collector.trace.v1,
+// as written by the OpenTelemetry Collector's file exporter.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Links             []otlpLink     `json:"links,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpLink struct {
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"` // 0 unset, 2 error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func otlpValue(v any) map[string]any {
+	switch x := v.(type) {
+	case string:
+		return map[string]any{"stringValue": x}
+	case bool:
+		return map[string]any{"boolValue": x}
+	case int:
+		return map[string]any{"intValue": strconv.Itoa(x)}
+	case int32:
+		return map[string]any{"intValue": strconv.FormatInt(int64(x), 10)}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(x, 10)}
+	case float64:
+		return map[string]any{"doubleValue": x}
+	}
+	return map[string]any{"stringValue": fmt.Sprint(v)}
+}
+
+func toOTLP(s SpanData) otlpRequest {
+	// OTLP numbers kinds as internal=1, server=2, client=3 — same as SpanKind
+	span := otlpSpan{
+		TraceID:           s.TraceID.String(),
+		SpanID:            s.SpanID.String(),
+		Name:              s.Name,
+		Kind:              int(s.Kind),
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+	}
+	if s.ParentID != (SpanID{}) {
+		span.ParentSpanID = s.ParentID.String()
+	}
+	for k, v := range s.Attributes {
+		span.Attributes = append(span.Attributes, otlpKeyValue{k, otlpValue(v)})
+	}
+	for _, l := range s.Links {
+		span.Links = append(span.Links, otlpLink{l.TraceID.String(), l.SpanID.String()})
+	}
+	if s.Error {
+		span.Status = otlpStatus{Code: 2, Message: s.ErrorMsg}
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			{"service.name", otlpValue(s.Service)},
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/kunal/gpu-batch-router/pkg/tracing"},
+			Spans: []otlpSpan{span},
+		}},
+	}}}
+}
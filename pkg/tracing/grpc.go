@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// traceparentHeader is the W3C header, carried as gRPC metadata.
+const traceparentHeader = "traceparent"
+
+// UnaryServerInterceptor extracts an incoming traceparent so spans started
+// by the handler join the caller's trace. It does not start a span itself:
+// handlers name their own spans, and untraced RPCs (metrics polls) stay quiet.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get(traceparentHeader); len(v) > 0 {
+				if sc, ok := ParseTraceparent(v[0]); ok {
+					ctx = ContextWithRemote(ctx, sc)
+				}
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// UnaryClientInterceptor injects the current span as a traceparent header.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if sc := SpanContextFromContext(ctx); sc.IsValid() {
+			ctx = metadata.AppendToOutgoingContext(ctx, traceparentHeader, sc.Traceparent())
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// Setup builds a tracer from configuration. An empty format disables
+// export; the tracer still forwards trace context it receives.
+func Setup(service, format, path string, sampleRate float64) (*Tracer, error) {
+	if format == "" {
+		return New(service, nil, 0), nil
+	}
+	if path == "" {
+		path = service + "-traces.jsonl"
+	}
+	exp, err := NewFileExporter(path, format)
+	if err != nil {
+		return nil, fmt.Errorf("trace exporter: %w", err)
+	}
+	logging.For("tracing").Info("exporting spans", "format", format, "file", path, "sample_rate", sampleRate)
+	return New(service, exp, sampleRate), nil
+}
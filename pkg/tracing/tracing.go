@@ -0,0 +1,295 @@
+// Package tracing is a small OpenTelemetry-style tracer: spans with
+// parent/child relationships and links, W3C traceparent propagation over
+// gRPC metadata, and exporters that write finished spans to a local file.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+// TraceID and SpanID follow the W3C Trace Context sizes.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether sc carries non-zero IDs.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent renders sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(s string) (SpanContext, bool) {
+	var sc SpanContext
+	// version(2)-traceid(32)-spanid(16)-flags(2)
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(s[3:35])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(s[36:52])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(s[53:55])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&0x01 != 0
+	return sc, sc.IsValid()
+}
+
+// SpanKind mirrors the OpenTelemetry span kinds.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = iota + 1
+	KindServer
+	KindClient
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	}
+	return "internal"
+}
+
+// Span is a single timed operation. All methods are safe on a nil or
+// unsampled span, so callers never need to check.
+type Span struct {
+	tracer *Tracer
+	name   string
+	kind   SpanKind
+	sc     SpanContext
+	parent SpanID
+	start  time.Time
+	links  []SpanContext
+
+	mu     sync.Mutex
+	attrs  map[string]any
+	errMsg string
+	failed bool
+	ended  bool
+}
+
+// Context returns the span's identity for propagation and links.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttr records a key/value attribute (string, bool, int, int64 or float64).
+func (s *Span) SetAttr(key string, value any) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	if !s.ended {
+		s.attrs[key] = value
+	}
+	s.mu.Unlock()
+}
+
+// SetError marks the span failed. A nil err is ignored.
+func (s *Span) SetError(err error) {
+	if err == nil || !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	if !s.ended {
+		s.failed = true
+		s.errMsg = err.Error()
+	}
+	s.mu.Unlock()
+}
+
+// End finishes the span now and hands it to the exporter.
+func (s *Span) End() { s.EndAt(time.Now()) }
+
+// EndAt finishes the span at t. Only the first call has any effect.
+func (s *Span) EndAt(t time.Time) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		Service:    s.tracer.service,
+		Name:       s.name,
+		Kind:       s.kind,
+		TraceID:    s.sc.TraceID,
+		SpanID:     s.sc.SpanID,
+		ParentID:   s.parent,
+		Start:      s.start,
+		End:        t,
+		Attributes: s.attrs,
+		Links:      s.links,
+		Error:      s.failed,
+		ErrorMsg:   s.errMsg,
+	}
+	s.mu.Unlock()
+	s.tracer.exporter.Export(data)
+}
+
+func (s *Span) recording() bool {
+	return s != nil && s.sc.Sampled && s.tracer.exporter != nil
+}
+
+// SpanData is a finished span as handed to an Exporter.
+type SpanData struct {
+	Service    string
+	Name       string
+	Kind       SpanKind
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID // zero for root spans
+	Start, End time.Time
+	Attributes map[string]any
+	Links      []SpanContext
+	Error      bool
+	ErrorMsg   string
+}
+
+// Exporter receives finished spans.
+type Exporter interface {
+	Export(SpanData)
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer starts spans for one service.
+type Tracer struct {
+	service    string
+	exporter   Exporter // nil disables recording; IDs still propagate
+	sampleRate float64
+}
+
+// New creates a tracer. A nil exporter gives a tracer that propagates
+// incoming trace context but records nothing.
+func New(service string, exporter Exporter, sampleRate float64) *Tracer {
+	return &Tracer{service: service, exporter: exporter, sampleRate: sampleRate}
+}
+
+// Shutdown flushes and closes the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil || t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+// StartOption customises a new span.
+type StartOption func(*Span)
+
+// WithKind sets the span kind (default internal).
+func WithKind(k SpanKind) StartOption { return func(s *Span) { s.kind = k } }
+
+// WithStartTime back-dates the span, e.g. for time already spent queued.
+func WithStartTime(t time.Time) StartOption { return func(s *Span) { s.start = t } }
+
+// WithLinks links the span to other spans, e.g. a batch to its requests.
+func WithLinks(links ...SpanContext) StartOption {
+	return func(s *Span) { s.links = append(s.links, links...) }
+}
+
+// Start begins a span as a child of the span (or remote parent) in ctx.
+// Without a parent a new trace is started: it is sampled if any link is
+// sampled, otherwise with the tracer's sample rate.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	s := &Span{tracer: t, name: name, kind: KindInternal, start: time.Now()}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Sampled = parent.Sampled
+		s.parent = parent.SpanID
+	} else {
+		s.sc.TraceID = newTraceID()
+		s.sc.Sampled = mrand.Float64() < t.sampleRate
+		for _, l := range s.links {
+			s.sc.Sampled = s.sc.Sampled || l.Sampled
+		}
+	}
+	s.sc.SpanID = newSpanID()
+	if s.recording() {
+		s.attrs = make(map[string]any)
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+// --- context plumbing ----------------------------------------------------------
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// ContextWithSpan returns ctx carrying s as the current span.
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// ContextWithRemote returns ctx carrying a parent received from another process.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanFromContext returns the current span, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SpanContextFromContext returns the local span's context, falling back to
+// a remote parent.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+func newTraceID() (id TraceID) {
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	rand.Read(id[:])
+	return id
+}
@@ -1,12 +1,17 @@
 package worker
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+	"github.com/kunal/gpu-batch-router/pkg/tracing"
 	"github.com/kunal/gpu-batch-router/pkg/worker/executor"
 )
 
@@ -15,6 +20,8 @@ type BatcherConfig struct {
 	MaxBatchSize int
 	MaxWaitTime  time.Duration
 	MinBatchSize int
+	ExecTimeout  time.Duration // per-batch deadline passed to the executor
+	Device       int           // GPU index, used to label metrics
 }
 
 // Batcher implements the adaptive micro-batching engine.
@@ -24,11 +31,17 @@ type Batcher struct {
 	cfg    BatcherConfig
 	queue  *PriorityQueue
 	exec   executor.GPUExecutor
+	tel    *telemetry
+	log    *slog.Logger
 	notify chan struct{} // signals new request arrival
 	stopCh chan struct{}
 	wg     sync.WaitGroup
 
-	// Adaptive state
+	// ctx is the parent of every batch context; cancelled when Stop gives up waiting
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Adaptive state, and the limits in cfg that SetLimits changes
 	mu          sync.RWMutex
 	currentWait time.Duration
 
@@ -37,15 +50,25 @@ type Batcher struct {
 	TotalRequests atomic.Int64
 	LastBatchSize atomic.Int32
 	AvgLatencyMs  atomic.Int64 // exponential moving average in microseconds
+	FailedItems   atomic.Int64 // requests that completed with an error
+	Bisections    atomic.Int64 // whole-batch failures split to isolate bad inputs
 }
 
-func NewBatcher(cfg BatcherConfig, queue *PriorityQueue, exec executor.GPUExecutor) *Batcher {
+func NewBatcher(cfg BatcherConfig, queue *PriorityQueue, exec executor.GPUExecutor, tel *telemetry) *Batcher {
+	if cfg.ExecTimeout <= 0 {
+		cfg.ExecTimeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Batcher{
 		cfg:         cfg,
 		queue:       queue,
 		exec:        exec,
+		tel:         tel,
+		log:         logging.For("batcher").With("device", cfg.Device),
 		notify:      make(chan struct{}, 256),
 		stopCh:      make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
 		currentWait: cfg.MaxWaitTime,
 	}
 }
@@ -54,14 +77,52 @@ func NewBatcher(cfg BatcherConfig, queue *PriorityQueue, exec executor.GPUExecut
 func (b *Batcher) Start() {
 	b.wg.Add(1)
 	go b.loop()
-	log.Printf("🔄 Batcher started: max_batch=%d, max_wait=%v, executor=%s",
-		b.cfg.MaxBatchSize, b.cfg.MaxWaitTime, b.exec.Name())
+	b.log.Info("batcher started",
+		"max_batch", b.cfg.MaxBatchSize,
+		"max_wait", b.cfg.MaxWaitTime,
+		"exec_timeout", b.cfg.ExecTimeout,
+		"executor", b.exec.Name())
 }
 
-// Stop gracefully shuts down the batcher.
-func (b *Batcher) Stop() {
+// Stop gracefully shuts down the batcher, draining queued requests.
+// If ctx expires before the drain finishes, the running batch is cancelled
+// and everything still queued fails fast with a context error.
+func (b *Batcher) Stop(ctx context.Context) {
 	close(b.stopCh)
-	b.wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		b.log.Warn("drain timed out, cancelling in-flight work")
+		b.cancel()
+		<-done
+	}
+	b.cancel()
+}
+
+// SetLimits changes the batch size and wait limits and the executor
+// deadline. A batch being collected finishes under the old limits.
+func (b *Batcher) SetLimits(maxBatch int, maxWait, execTimeout time.Duration) {
+	b.mu.Lock()
+	b.cfg.MaxBatchSize = maxBatch
+	b.cfg.MaxWaitTime = maxWait
+	b.cfg.ExecTimeout = execTimeout
+	b.currentWait = maxWait
+	b.mu.Unlock()
+	b.log.Info("batch limits changed", "max_batch", maxBatch, "max_wait", maxWait, "exec_timeout", execTimeout)
+}
+
+// limits returns the current batch size limit and executor deadline.
+func (b *Batcher) limits() (maxBatch int, execTimeout time.Duration) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.cfg.MaxBatchSize, b.cfg.ExecTimeout
 }
 
 // Signal notifies the batcher that a new request has arrived.
@@ -99,6 +160,7 @@ func (b *Batcher) loop() {
 func (b *Batcher) collectBatch() []*PendingRequest {
 	b.mu.RLock()
 	wait := b.currentWait
+	maxBatch := b.cfg.MaxBatchSize
 	b.mu.RUnlock()
 
 	timer := time.NewTimer(wait)
@@ -108,23 +170,23 @@ func (b *Batcher) collectBatch() []*PendingRequest {
 		depth := b.queue.Depth()
 
 		// Flush if queue has enough for a full batch
-		if depth >= b.cfg.MaxBatchSize {
-			return b.queue.DequeueN(b.cfg.MaxBatchSize)
+		if depth >= maxBatch {
+			return b.queue.DequeueN(maxBatch)
 		}
 
 		select {
 		case <-b.stopCh:
 			// Drain what we have on shutdown
-			return b.queue.DequeueN(b.cfg.MaxBatchSize)
+			return b.queue.DequeueN(maxBatch)
 
 		case <-timer.C:
 			// Timeout — flush whatever we have
-			return b.queue.DequeueN(b.cfg.MaxBatchSize)
+			return b.queue.DequeueN(maxBatch)
 
 		case <-b.notify:
 			// New request arrived, check if batch is full now
-			if b.queue.Depth() >= b.cfg.MaxBatchSize {
-				return b.queue.DequeueN(b.cfg.MaxBatchSize)
+			if b.queue.Depth() >= maxBatch {
+				return b.queue.DequeueN(maxBatch)
 			}
 			// Otherwise keep waiting for more
 			continue
@@ -136,14 +198,37 @@ func (b *Batcher) executeBatch(batch []*PendingRequest) {
 	batchSize := len(batch)
 	start := time.Now()
 
-	// Extract payloads
+	// Extract payloads, closing each request's queue-wait span
 	payloads := make([][]byte, batchSize)
+	links := make([]tracing.SpanContext, 0, batchSize)
 	for i, r := range batch {
 		payloads[i] = r.Req.Payload
+		b.tel.observeQueueWait(r.Req, start.Sub(r.EnqueueAt))
+
+		_, wait := b.tel.tracer.Start(tracing.ContextWithSpan(context.Background(), r.Span),
+			"worker.queue_wait", tracing.WithStartTime(r.EnqueueAt))
+		wait.EndAt(start)
+		if sc := r.Span.Context(); sc.IsValid() {
+			links = append(links, sc)
+		}
+	}
+
+	// The batch is its own trace, linked to every member request
+	_, span := b.tel.tracer.Start(context.Background(), "worker.batch_execute",
+		tracing.WithStartTime(start), tracing.WithLinks(links...))
+	span.SetAttr("batch.size", batchSize)
+	span.SetAttr("gpu.device", b.cfg.Device)
+	span.SetAttr("executor", b.exec.Name())
+	for _, r := range batch {
+		r.Span.SetAttr("batch.trace_id", span.Context().TraceID.String())
 	}
 
-	// Execute on GPU
-	results, err := b.exec.ExecuteBatch(payloads)
+	// Execute on GPU (bisecting on whole-batch failure) under the batch deadline
+	bisections := b.Bisections.Load()
+	_, execTimeout := b.limits()
+	ctx, cancel := context.WithTimeout(b.ctx, execTimeout)
+	results := b.runIsolating(tracing.ContextWithSpan(ctx, span), payloads)
+	cancel()
 	elapsed := time.Since(start)
 
 	// Update metrics
@@ -162,21 +247,22 @@ func (b *Batcher) executeBatch(batch []*PendingRequest) {
 		b.AvgLatencyMs.Store(newAvg)
 	}
 
-	log.Printf("📦 Batch executed: size=%d, latency=%v", batchSize, elapsed)
-
 	// Distribute results
-	if err != nil {
-		for _, r := range batch {
+	failed := 0
+	done := time.Now()
+	for i, r := range batch {
+		r.DoneAt = done
+		if err := results[i].Err; err != nil {
+			b.log.Debug("request failed in batch", "request_id", r.Req.RequestId, "err", err)
+			failed++
+			b.FailedItems.Add(1)
 			r.ErrCh <- err
+			continue
 		}
-		return
-	}
-
-	for i, r := range batch {
 		queueWait := start.Sub(r.EnqueueAt)
 		resp := &pb.InferResponse{
 			RequestId:    r.Req.RequestId,
-			Result:       results[i],
+			Result:       results[i].Output,
 			LatencyNs:    elapsed.Nanoseconds(),
 			BatchSize:    int32(batchSize),
 			QueueWaitMs:  int32(queueWait.Milliseconds()),
@@ -184,11 +270,49 @@ func (b *Batcher) executeBatch(batch []*PendingRequest) {
 		}
 		r.DoneCh <- resp
 	}
+	b.tel.observeBatch(b.cfg.Device, batchSize, failed, elapsed)
+	b.log.Debug("batch executed", "size", batchSize, "failed", failed, "latency", elapsed)
+	span.SetAttr("batch.failed_items", failed)
+	span.SetAttr("batch.bisections", b.Bisections.Load()-bisections)
+	if failed == batchSize {
+		span.SetError(results[0].Err)
+	}
+	span.EndAt(start.Add(elapsed))
 
 	// Adaptive wait tuning
 	b.adaptWait()
 }
 
+// runIsolating executes payloads as one batch. If the executor fails the
+// whole batch, it is split in half and each half retried, recursing down to
+// single items, so one malformed payload only fails its own request.
+// Deadline/cancellation failures are not bisected — every item gets ctx's error.
+// Always returns exactly one result per payload.
+func (b *Batcher) runIsolating(ctx context.Context, payloads [][]byte) []executor.Result {
+	results, err := b.exec.ExecuteBatch(ctx, payloads)
+	if err == nil && len(results) != len(payloads) {
+		err = fmt.Errorf("executor %s returned %d results for %d payloads",
+			b.exec.Name(), len(results), len(payloads))
+	}
+	if err == nil {
+		return results
+	}
+
+	if len(payloads) == 1 || ctx.Err() != nil ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		failed := make([]executor.Result, len(payloads))
+		for i := range failed {
+			failed[i].Err = err
+		}
+		return failed
+	}
+
+	b.Bisections.Add(1)
+	b.log.Warn("batch failed, bisecting to isolate bad input", "size", len(payloads), "err", err)
+	mid := len(payloads) / 2
+	return append(b.runIsolating(ctx, payloads[:mid]), b.runIsolating(ctx, payloads[mid:])...)
+}
+
 func (b *Batcher) adaptWait() {
 	depth := b.queue.Depth()
 	b.mu.Lock()
@@ -209,7 +333,8 @@ func (b *Batcher) adaptWait() {
 
 func (b *Batcher) drainRemaining() {
 	for {
-		batch := b.queue.DequeueN(b.cfg.MaxBatchSize)
+		maxBatch, _ := b.limits()
+		batch := b.queue.DequeueN(maxBatch)
 		if len(batch) == 0 {
 			return
 		}
@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/tracing"
+	"github.com/kunal/gpu-batch-router/pkg/worker/executor"
+)
+
+// poisonExecutor echoes each payload, but fails any batch holding the
+// "poison" payload as a whole, the way a real runtime rejects a batch
+// with one malformed tensor. With block set it instead waits for ctx.
+type poisonExecutor struct {
+	block bool
+	calls atomic.Int32
+}
+
+func (e *poisonExecutor) ExecuteBatch(ctx context.Context, payloads [][]byte) ([]executor.Result, error) {
+	e.calls.Add(1)
+	if e.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if slices.ContainsFunc(payloads, func(p []byte) bool { return string(p) == "poison" }) {
+		return nil, errors.New("input tensor has the wrong shape")
+	}
+	results := make([]executor.Result, len(payloads))
+	for i, p := range payloads {
+		results[i].Output = p
+	}
+	return results, nil
+}
+
+func (e *poisonExecutor) Name() string { return "poison" }
+
+func newTestBatcher(exec executor.GPUExecutor) *Batcher {
+	tel := newTelemetry("w1", nil, tracing.New("w1", nil, 0))
+	return NewBatcher(BatcherConfig{MaxBatchSize: 32, MaxWaitTime: 10 * time.Millisecond}, NewPriorityQueue(), exec, tel)
+}
+
+// pendingBatch returns a request per payload, with room for its answer.
+func pendingBatch(payloads ...string) []*PendingRequest {
+	batch := make([]*PendingRequest, len(payloads))
+	for i, p := range payloads {
+		batch[i] = &PendingRequest{
+			Req:       &pb.InferRequest{RequestId: fmt.Sprint("r", i), Payload: []byte(p)},
+			DoneCh:    make(chan *pb.InferResponse, 1),
+			ErrCh:     make(chan error, 1),
+			EnqueueAt: time.Now(),
+		}
+	}
+	return batch
+}
+
+func TestBatchIsolatesPoisonedPayload(t *testing.T) {
+	exec := &poisonExecutor{}
+	b := newTestBatcher(exec)
+	payloads := []string{"a", "b", "c", "d", "e", "poison", "g", "h"}
+	batch := pendingBatch(payloads...)
+
+	b.executeBatch(batch)
+
+	for i, r := range batch {
+		select {
+		case err := <-r.ErrCh:
+			if payloads[i] != "poison" {
+				t.Errorf("request %d (%q) failed: %v", i, payloads[i], err)
+			}
+		case resp := <-r.DoneCh:
+			if payloads[i] == "poison" {
+				t.Errorf("the poisoned request succeeded")
+			} else if string(resp.Result) != payloads[i] {
+				t.Errorf("request %d got %q, want its own payload %q", i, resp.Result, payloads[i])
+			}
+		default:
+			t.Errorf("request %d got no answer", i)
+		}
+	}
+	if got := b.FailedItems.Load(); got != 1 {
+		t.Errorf("failed items = %d, want 1", got)
+	}
+	// 8 → 4+4 → 2+2 on the poisoned side → 1+1
+	if got := b.Bisections.Load(); got != 3 {
+		t.Errorf("bisections = %d, want 3", got)
+	}
+}
+
+func TestBatchCanceledIsNotBisected(t *testing.T) {
+	tests := []struct {
+		name   string
+		exec   *poisonExecutor
+		setup  func(b *Batcher)
+		wantEr error // nil: any error
+	}{
+		{
+			// The executor reports the bad input, but the batch was canceled
+			// and every request is failing anyway
+			name:  "stop gave up on the drain",
+			exec:  &poisonExecutor{},
+			setup: func(b *Batcher) { b.cancel() },
+		},
+		{
+			name:   "batch deadline",
+			exec:   &poisonExecutor{block: true},
+			setup:  func(b *Batcher) { b.SetLimits(32, 10*time.Millisecond, 20*time.Millisecond) },
+			wantEr: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBatcher(tt.exec)
+			tt.setup(b)
+			batch := pendingBatch("a", "poison", "c", "d")
+
+			b.executeBatch(batch)
+
+			for i, r := range batch {
+				select {
+				case err := <-r.ErrCh:
+					if err == nil || tt.wantEr != nil && !errors.Is(err, tt.wantEr) {
+						t.Errorf("request %d: got %v, want %v", i, err, tt.wantEr)
+					}
+				default:
+					t.Errorf("request %d didn't fail", i)
+				}
+			}
+			if got := tt.exec.calls.Load(); got != 1 {
+				t.Errorf("executor called %d times, want 1", got)
+			}
+			if got := b.Bisections.Load(); got != 0 {
+				t.Errorf("bisections = %d, want 0", got)
+			}
+		})
+	}
+}
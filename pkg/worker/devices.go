@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kunal/gpu-batch-router/pkg/worker/nvml"
+)
+
+// resolveDevices turns the GPU_DEVICES setting into CUDA device ordinals.
+//
+//	""     → [0] (classic single-GPU worker)
+//	"all"  → every GPU visible to NVML
+//	"0,2"  → an explicit device list
+func resolveDevices(spec string) ([]int, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "":
+		return []int{0}, nil
+	case "all":
+		n, err := nvml.New()
+		if err != nil {
+			return nil, fmt.Errorf("GPU_DEVICES=all: %w", err)
+		}
+		count := n.GPUCount()
+		n.Shutdown()
+		devices := make([]int, count)
+		for i := range devices {
+			devices[i] = i
+		}
+		return devices, nil
+	}
+
+	seen := make(map[int]bool)
+	var devices []int
+	for _, part := range strings.Split(spec, ",") {
+		idx, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || idx < 0 {
+			return nil, fmt.Errorf("GPU_DEVICES: invalid device %q", part)
+		}
+		if seen[idx] {
+			return nil, fmt.Errorf("GPU_DEVICES: device %d listed twice", idx)
+		}
+		seen[idx] = true
+		devices = append(devices, idx)
+	}
+	return devices, nil
+}
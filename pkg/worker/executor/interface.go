@@ -1,11 +1,33 @@
 package executor
 
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidPayload is wrapped by every per-item error caused by the
+// request's own input, so callers can report it as a client error.
+var ErrInvalidPayload = errors.New("invalid payload")
+
+// Result is the outcome of a single item within a batch.
+// Exactly one of Output or Err is meaningful: a non-nil Err means this
+// item failed on its own (e.g. malformed payload) while the rest of the
+// batch may still have succeeded.
+type Result struct {
+	Output []byte
+	Err    error
+}
+
 // GPUExecutor is the interface for running batched inference workloads.
 // Implementations can target real GPU (ONNX) or simulation.
 type GPUExecutor interface {
-	// ExecuteBatch processes a batch of payloads and returns results.
-	// Each payload corresponds to one inference request.
-	ExecuteBatch(payloads [][]byte) ([][]byte, error)
+	// ExecuteBatch processes a batch of payloads and returns one Result per
+	// payload, in the same order. A non-nil error means the whole batch
+	// failed and the returned results must be ignored.
+	//
+	// ctx carries the batch deadline. Implementations should abandon the
+	// work as soon as they can once ctx is done and return ctx.Err().
+	ExecuteBatch(ctx context.Context, payloads [][]byte) ([]Result, error)
 
 	// Name returns the executor type for logging.
 	Name() string
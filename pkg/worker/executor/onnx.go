@@ -7,18 +7,35 @@ package executor
 #include <onnxruntime_c_api.h>
 #include <stdlib.h>
 
-// Helper to create ORT environment, session, and run inference
+// Helper to create ORT environment, sessions, and run inference
 // We use the C API directly for maximum control and portability
+//
+// Process-wide state is limited to what ORT itself treats as shared:
+// the API table, a single OrtEnv (refcounted across sessions), the CPU
+// memory info and the default allocator. Everything model-specific lives
+// in an ort_session_t owned by one ONNXExecutor.
+// The env functions are not thread-safe; callers serialize them (envMu).
 
 static const OrtApi* g_ort = NULL;
 static OrtEnv* g_env = NULL;
-static OrtSession* g_session = NULL;
-static OrtSessionOptions* g_session_opts = NULL;
+static int g_env_refs = 0;
 static OrtMemoryInfo* g_memory_info = NULL;
 static OrtAllocator* g_allocator = NULL;
 
-static int ort_init(const char* model_path, int use_gpu) {
-    g_ort = OrtGetApiBase()->GetApi(ORT_API_VERSION);
+typedef struct {
+    OrtSession* session;
+    OrtSessionOptions* opts;
+    char* input_name;
+    char* output_name;
+} ort_session_t;
+
+static int ort_env_acquire() {
+    if (g_env_refs > 0) {
+        g_env_refs++;
+        return 0;
+    }
+
+    if (!g_ort) g_ort = OrtGetApiBase()->GetApi(ORT_API_VERSION);
     if (!g_ort) return -1;
 
     OrtStatus* status = NULL;
@@ -27,13 +44,58 @@ static int ort_init(const char* model_path, int use_gpu) {
     status = g_ort->CreateEnv(ORT_LOGGING_LEVEL_WARNING, "gpu-batch-router", &g_env);
     if (status) { g_ort->ReleaseStatus(status); return -2; }
 
+    // Create memory info
+    status = g_ort->CreateCpuMemoryInfo(OrtArenaAllocator, OrtMemTypeDefault, &g_memory_info);
+    if (status) {
+        g_ort->ReleaseStatus(status);
+        g_ort->ReleaseEnv(g_env); g_env = NULL;
+        return -5;
+    }
+
+    // Get allocator (owned by ORT, never released)
+    status = g_ort->GetAllocatorWithDefaultOptions(&g_allocator);
+    if (status) {
+        g_ort->ReleaseStatus(status);
+        g_ort->ReleaseMemoryInfo(g_memory_info); g_memory_info = NULL;
+        g_ort->ReleaseEnv(g_env); g_env = NULL;
+        return -6;
+    }
+
+    g_env_refs = 1;
+    return 0;
+}
+
+static void ort_env_release() {
+    if (g_env_refs == 0) return;
+    if (--g_env_refs > 0) return;
+    if (g_memory_info) { g_ort->ReleaseMemoryInfo(g_memory_info); g_memory_info = NULL; }
+    if (g_env) { g_ort->ReleaseEnv(g_env); g_env = NULL; }
+    g_allocator = NULL;
+}
+
+static void ort_session_release(ort_session_t* s) {
+    if (!s) return;
+    if (s->input_name) g_ort->AllocatorFree(g_allocator, s->input_name);
+    if (s->output_name) g_ort->AllocatorFree(g_allocator, s->output_name);
+    if (s->session) g_ort->ReleaseSession(s->session);
+    if (s->opts) g_ort->ReleaseSessionOptions(s->opts);
+    free(s);
+}
+
+// Create a session for one model on one CUDA device. The env must already be acquired.
+static int ort_session_create(const char* model_path, int use_gpu, int device_id, ort_session_t** out) {
+    ort_session_t* s = (ort_session_t*)calloc(1, sizeof(ort_session_t));
+    if (!s) return -1;
+
+    OrtStatus* status = NULL;
+
     // Create session options
-    status = g_ort->CreateSessionOptions(&g_session_opts);
-    if (status) { g_ort->ReleaseStatus(status); return -3; }
+    status = g_ort->CreateSessionOptions(&s->opts);
+    if (status) { g_ort->ReleaseStatus(status); ort_session_release(s); return -3; }
 
     // Enable GPU if requested
     if (use_gpu) {
-        status = OrtSessionOptionsAppendExecutionProvider_CUDA(g_session_opts, 0);
+        status = OrtSessionOptionsAppendExecutionProvider_CUDA(s->opts, device_id);
         if (status) {
             // CUDA not available, fall back to CPU
             g_ort->ReleaseStatus(status);
@@ -41,29 +103,47 @@ static int ort_init(const char* model_path, int use_gpu) {
     }
 
     // Optimize for throughput
-    g_ort->SetIntraOpNumThreads(g_session_opts, 4);
-    g_ort->SetSessionGraphOptimizationLevel(g_session_opts, ORT_ENABLE_ALL);
+    g_ort->SetIntraOpNumThreads(s->opts, 4);
+    g_ort->SetSessionGraphOptimizationLevel(s->opts, ORT_ENABLE_ALL);
 
     // Create session
-    status = g_ort->CreateSession(g_env, model_path, g_session_opts, &g_session);
-    if (status) { g_ort->ReleaseStatus(status); return -4; }
+    status = g_ort->CreateSession(g_env, model_path, s->opts, &s->session);
+    if (status) { g_ort->ReleaseStatus(status); ort_session_release(s); return -4; }
 
-    // Create memory info
-    status = g_ort->CreateCpuMemoryInfo(OrtArenaAllocator, OrtMemTypeDefault, &g_memory_info);
-    if (status) { g_ort->ReleaseStatus(status); return -5; }
-
-    // Get allocator
-    status = g_ort->GetAllocatorWithDefaultOptions(&g_allocator);
-    if (status) { g_ort->ReleaseStatus(status); return -6; }
+    // Cache input/output names for the lifetime of the session
+    status = g_ort->SessionGetInputName(s->session, 0, g_allocator, &s->input_name);
+    if (status) { g_ort->ReleaseStatus(status); ort_session_release(s); return -7; }
+    status = g_ort->SessionGetOutputName(s->session, 0, g_allocator, &s->output_name);
+    if (status) { g_ort->ReleaseStatus(status); ort_session_release(s); return -8; }
 
+    *out = s;
     return 0;
 }
 
+// Run options let another thread abort an in-progress Run via
+// RunOptionsSetTerminate — used to honour Go context cancellation.
+static OrtRunOptions* ort_new_run_options() {
+    if (!g_ort) return NULL;
+    OrtRunOptions* opts = NULL;
+    OrtStatus* status = g_ort->CreateRunOptions(&opts);
+    if (status) { g_ort->ReleaseStatus(status); return NULL; }
+    return opts;
+}
+
+static void ort_terminate_run(OrtRunOptions* opts) {
+    OrtStatus* status = g_ort->RunOptionsSetTerminate(opts);
+    if (status) g_ort->ReleaseStatus(status);
+}
+
+static void ort_release_run_options(OrtRunOptions* opts) {
+    if (opts) g_ort->ReleaseRunOptions(opts);
+}
+
 // Run inference on a batch of float data
 // Input shape: [batch_size, 3, 224, 224] (ImageNet)
 // Output: [batch_size, 1000] (class probabilities)
-static int ort_run_batch(float* input_data, int batch_size, float* output_data) {
-    if (!g_session || !g_ort) return -1;
+static int ort_run_batch(ort_session_t* s, OrtRunOptions* run_opts, float* input_data, int batch_size, float* output_data) {
+    if (!s || !s->session || !g_ort) return -1;
 
     OrtStatus* status = NULL;
     const int64_t input_shape[] = {batch_size, 3, 224, 224};
@@ -78,26 +158,18 @@ static int ort_run_batch(float* input_data, int batch_size, float* output_data)
     );
     if (status) { g_ort->ReleaseStatus(status); return -2; }
 
-    // Get input/output names
-    char* input_name = NULL;
-    char* output_name = NULL;
-    g_ort->SessionGetInputName(g_session, 0, g_allocator, &input_name);
-    g_ort->SessionGetOutputName(g_session, 0, g_allocator, &output_name);
-
-    const char* input_names[] = { input_name };
-    const char* output_names[] = { output_name };
+    const char* input_names[] = { s->input_name };
+    const char* output_names[] = { s->output_name };
     OrtValue* output_tensor = NULL;
 
     // Run inference
     status = g_ort->Run(
-        g_session, NULL,
+        s->session, run_opts,
         input_names, (const OrtValue* const*)&input_tensor, 1,
         output_names, 1,
         &output_tensor
     );
 
-    g_ort->AllocatorFree(g_allocator, input_name);
-    g_ort->AllocatorFree(g_allocator, output_name);
     g_ort->ReleaseValue(input_tensor);
 
     if (status) {
@@ -115,17 +187,11 @@ static int ort_run_batch(float* input_data, int batch_size, float* output_data)
     g_ort->ReleaseValue(output_tensor);
     return 0;
 }
-
-static void ort_cleanup() {
-    if (g_session) g_ort->ReleaseSession(g_session);
-    if (g_session_opts) g_ort->ReleaseSessionOptions(g_session_opts);
-    if (g_memory_info) g_ort->ReleaseMemoryInfo(g_memory_info);
-    if (g_env) g_ort->ReleaseEnv(g_env);
-}
 */
 import "C"
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -140,20 +206,29 @@ var imagenetLabels = []string{
 	"electric_ray", "stingray", "cock", "hen", "ostrich",
 }
 
+// envMu serializes OrtEnv acquire/release, which share refcounted C globals.
+var envMu sync.Mutex
+
 // ONNXExecutor runs real inference using ONNX Runtime.
 // Supports both CPU and GPU (CUDA) execution providers.
+// Each executor owns its own ORT session, so several models (or versions
+// of one model) can be loaded side by side in the same process.
 type ONNXExecutor struct {
 	mu        sync.Mutex
 	modelPath string
 	useGPU    bool
+	deviceID  int
+	session   *C.ort_session_t
 	ready     bool
 }
 
-// NewONNX creates an ONNX executor and loads the model.
-func NewONNX(modelPath string, useGPU bool) (*ONNXExecutor, error) {
+// NewONNX creates an ONNX executor and loads the model into a new session.
+// deviceID selects the CUDA device when useGPU is set.
+func NewONNX(modelPath string, useGPU bool, deviceID int) (*ONNXExecutor, error) {
 	e := &ONNXExecutor{
 		modelPath: modelPath,
 		useGPU:    useGPU,
+		deviceID:  deviceID,
 	}
 
 	cModelPath := C.CString(modelPath)
@@ -164,10 +239,16 @@ func NewONNX(modelPath string, useGPU bool) (*ONNXExecutor, error) {
 		gpuFlag = 1
 	}
 
-	rc := C.ort_init(cModelPath, gpuFlag)
-	if rc != 0 {
+	envMu.Lock()
+	defer envMu.Unlock()
+
+	if rc := C.ort_env_acquire(); rc != 0 {
 		return nil, fmt.Errorf("ONNX Runtime init failed (code %d)", rc)
 	}
+	if rc := C.ort_session_create(cModelPath, gpuFlag, C.int(deviceID), &e.session); rc != 0 {
+		C.ort_env_release()
+		return nil, fmt.Errorf("ONNX session creation failed for %s (code %d)", modelPath, rc)
+	}
 
 	e.ready = true
 	return e, nil
@@ -180,16 +261,24 @@ func (e *ONNXExecutor) Name() string {
 	return "onnx-cpu"
 }
 
+// imageInputFloats is the number of float32 values in one [3, 224, 224] input.
+const imageInputFloats = 3 * 224 * 224
+
 // ExecuteBatch runs inference on a batch of payloads.
 // Each payload is treated as raw bytes → float32 image data.
-// If payload is too small, we pad with zeros (random noise for demo).
-func (e *ONNXExecutor) ExecuteBatch(payloads [][]byte) ([][]byte, error) {
+// If payload is too small, we pad with zeros (random noise for demo); bytes
+// beyond one input tensor are ignored.
+// Cancelling ctx terminates the ORT run in progress.
+func (e *ONNXExecutor) ExecuteBatch(ctx context.Context, payloads [][]byte) ([]Result, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	if !e.ready {
 		return nil, fmt.Errorf("ONNX executor not initialized")
 	}
-
-	e.mu.Lock()
-	defer e.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	batchSize := len(payloads)
 	if batchSize == 0 {
@@ -197,13 +286,13 @@ func (e *ONNXExecutor) ExecuteBatch(payloads [][]byte) ([][]byte, error) {
 	}
 
 	// ImageNet input: [batch, 3, 224, 224]
-	inputSize := batchSize * 3 * 224 * 224
+	inputSize := batchSize * imageInputFloats
 	inputData := make([]float32, inputSize)
 
 	// Fill input data from payloads (or pad with normalized random values)
 	for i, payload := range payloads {
-		offset := i * 3 * 224 * 224
-		for j := 0; j < 3*224*224; j++ {
+		offset := i * imageInputFloats
+		for j := 0; j < imageInputFloats; j++ {
 			if j < len(payload)/4 {
 				// Use payload bytes as float32
 				inputData[offset+j] = float32(payload[j%len(payload)]) / 255.0
@@ -218,18 +307,45 @@ func (e *ONNXExecutor) ExecuteBatch(payloads [][]byte) ([][]byte, error) {
 	outputSize := batchSize * 1000
 	outputData := make([]float32, outputSize)
 
+	runOpts := C.ort_new_run_options()
+	if runOpts == nil {
+		return nil, fmt.Errorf("ONNX run options creation failed")
+	}
+
+	// Watch ctx while the (blocking) C call runs; terminate the run on cancel.
+	// The watcher must exit before the run options are released.
+	runDone := make(chan struct{})
+	watcherDone := make(chan struct{})
+	go func() {
+		defer close(watcherDone)
+		select {
+		case <-ctx.Done():
+			C.ort_terminate_run(runOpts)
+		case <-runDone:
+		}
+	}()
+
 	rc := C.ort_run_batch(
+		e.session,
+		runOpts,
 		(*C.float)(unsafe.Pointer(&inputData[0])),
 		C.int(batchSize),
 		(*C.float)(unsafe.Pointer(&outputData[0])),
 	)
+	close(runDone)
+	<-watcherDone
+	C.ort_release_run_options(runOpts)
+
 	if rc != 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("ONNX inference failed (code %d)", rc)
 	}
 
 	// Convert outputs to JSON results
-	results := make([][]byte, batchSize)
-	for i := 0; i < batchSize; i++ {
+	results := make([]Result, batchSize)
+	for i := range results {
 		offset := i * 1000
 		probs := outputData[offset : offset+1000]
 
@@ -273,14 +389,25 @@ func (e *ONNXExecutor) ExecuteBatch(payloads [][]byte) ([][]byte, error) {
 			"executor":  "onnx",
 		}
 		data, _ := json.Marshal(result)
-		results[i] = data
+		results[i].Output = data
 	}
 
 	return results, nil
 }
 
-// Cleanup releases ONNX Runtime resources.
+// Cleanup releases this executor's session. The shared OrtEnv is
+// released once the last session in the process is cleaned up.
 func (e *ONNXExecutor) Cleanup() {
-	C.ort_cleanup()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.ready {
+		return
+	}
+
+	envMu.Lock()
+	defer envMu.Unlock()
+	C.ort_session_release(e.session)
+	C.ort_env_release()
+	e.session = nil
 	e.ready = false
 }
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -23,11 +24,14 @@ func NewSimulated(baseLatencyMs int) *SimulatedGPU {
 
 func (s *SimulatedGPU) Name() string { return "simulation" }
 
-func (s *SimulatedGPU) ExecuteBatch(payloads [][]byte) ([][]byte, error) {
+func (s *SimulatedGPU) ExecuteBatch(ctx context.Context, payloads [][]byte) ([]Result, error) {
 	batchSize := len(payloads)
 	if batchSize == 0 {
 		return nil, fmt.Errorf("empty batch")
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Simulate GPU kernel time: base + sublinear scaling with batch size
 	// Real GPUs show sublinear latency growth — batching is efficient
@@ -37,11 +41,17 @@ func (s *SimulatedGPU) ExecuteBatch(payloads [][]byte) ([][]byte, error) {
 	// Do some real CPU work (matrix multiply) to create actual load
 	matrixWork(64) // 64x64 matrix multiply
 
-	// Sleep for remaining simulated GPU time
-	time.Sleep(latency)
+	// Sleep for remaining simulated GPU time, waking early on cancellation
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+	}
 
 	// Produce results
-	results := make([][]byte, batchSize)
+	results := make([]Result, batchSize)
 	classes := []string{"cat", "dog", "car", "tree", "person", "building", "bird", "fish"}
 	for i := range results {
 		result := map[string]interface{}{
@@ -51,7 +61,7 @@ func (s *SimulatedGPU) ExecuteBatch(payloads [][]byte) ([][]byte, error) {
 			"batch_pos":  i,
 		}
 		data, _ := json.Marshal(result)
-		results[i] = data
+		results[i].Output = data
 	}
 	return results, nil
 }
@@ -9,6 +9,6 @@ import (
 
 // createExecutor returns the simulation executor (default build).
 // For real ONNX inference, build with: go build -tags onnx
-func createExecutor(cfg *config.Config) executor.GPUExecutor {
+func createExecutor(cfg *config.Config, device int) executor.GPUExecutor {
 	return executor.NewSimulated(5)
 }
@@ -3,26 +3,27 @@
 package worker
 
 import (
-	"log"
 	"os"
 
 	"github.com/kunal/gpu-batch-router/pkg/config"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
 	"github.com/kunal/gpu-batch-router/pkg/worker/executor"
 )
 
-// createExecutor returns the ONNX executor (GPU build).
+// createExecutor returns the ONNX executor (GPU build) bound to one device.
 // Build with: go build -tags onnx
-func createExecutor(cfg *config.Config) executor.GPUExecutor {
+func createExecutor(cfg *config.Config, device int) executor.GPUExecutor {
 	modelPath := os.Getenv("ONNX_MODEL_PATH")
 	if modelPath == "" {
 		modelPath = "/models/resnet50.onnx"
 	}
 	useGPU := cfg.UseNVML != "false"
-	onnxExec, err := executor.NewONNX(modelPath, useGPU)
+	log := logging.For("executor")
+	onnxExec, err := executor.NewONNX(modelPath, useGPU, device)
 	if err != nil {
-		log.Printf("⚠️  ONNX init failed: %v — falling back to simulation", err)
+		log.Warn("ONNX init failed, falling back to simulation", "device", device, "err", err)
 		return executor.NewSimulated(5)
 	}
-	log.Printf("🧠 ONNX executor loaded: model=%s, gpu=%v", modelPath, useGPU)
+	log.Info("ONNX executor loaded", "model", modelPath, "gpu", useGPU, "device", device)
 	return onnxExec
 }
@@ -1,100 +1,402 @@
 package worker
 
 import (
-	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"math/rand"
-	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/gpu"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+	"github.com/kunal/gpu-batch-router/pkg/worker/nvml"
 )
 
+// Values for WorkerMetrics.MetricsSource.
+const (
+	MetricsSourceNVML      = "nvml"
+	MetricsSourceSimulated = "simulated"
+)
+
+// GPUMetricsSource supplies real hardware readings per GPU index.
+// *nvml.NVML implements it; tests can inject a fake backend.
+type GPUMetricsSource interface {
+	GPUCount() int
+	GetGPUInfo(index int) (*nvml.GPUInfo, error)
+	Shutdown()
+}
+
 // MetricsCollector gathers GPU metrics (real NVML or simulated).
 type MetricsCollector struct {
 	workerID string
-	batcher  *Batcher
+	devices  []*Device
 	queue    *PriorityQueue
 
-	// Simulated GPU state
-	mu             sync.RWMutex
-	simVRAMUsedGB  float64
-	simVRAMTotalGB float64
-	simTempC       float64
-	simGPUUtil     float64
+	// gpu is the hardware source; nil means simulated metrics
+	gpu GPUMetricsSource
+
+	// share is the fraction of each device's memory this worker may use
+	// (1.0 unless the GPU is time-sliced between several workers)
+	share float64
+
+	// Simulated GPU state, one entry per device
+	mu  sync.RWMutex
+	sim []deviceSim
 
 	// Track request count for utilization simulation
 	inFlight atomic.Int32
 
-	useNVML bool
+	// Freshness stamps on every snapshot
+	seq       atomic.Uint64
+	startedAt time.Time
+
+	// Sampling loops shared by metrics streams, by interval; see Watch
+	samplersMu sync.Mutex
+	samplers   map[time.Duration]*sampler
+
+	log       *slog.Logger
+	stopCh    chan struct{}
+	closeOnce sync.Once
+}
+
+// deviceSim is the simulated state of a single GPU.
+type deviceSim struct {
+	vramUsedGB  float64
+	vramTotalGB float64
+	tempC       float64
+	gpuUtil     float64
+
+	// Extended telemetry, modelled loosely on a Tesla T4
+	powerW    float64
+	smClock   float64
+	throttle  uint64
+	pcieRxKBs float64
+	pcieTxKBs float64
+	fanPct    float64
+}
+
+// Simulated T4 envelope.
+const (
+	simPowerLimitW = 70.0
+	simIdlePowerW  = 10.0
+	simMaxSMClock  = 1590.0
+	simIdleSMClock = 300.0
+	simMemClock    = 5001.0
+	simVRAMTotalGB = 15.0 // the whole device; GPU_MEMORY_SHARE carves out this worker's part
+)
+
+// NewMetricsCollector creates a collector, loading NVML when useNVML is
+// "auto" or "true". If NVML can't be loaded (no GPU, or a build without
+// -tags nvml) it falls back to simulated metrics.
+func NewMetricsCollector(workerID string, devices []*Device, queue *PriorityQueue, useNVML string, gpuShare float64) *MetricsCollector {
+	var src GPUMetricsSource
+	if useNVML == "true" || useNVML == "auto" {
+		n, err := nvml.New()
+		switch {
+		case err == nil:
+			src = n
+		case useNVML == "true":
+			logging.For("metrics").Warn("USE_NVML=true but NVML failed to load", "err", err)
+		}
+	}
+	return NewMetricsCollectorWithSource(workerID, devices, queue, gpuShare, src)
 }
 
-func NewMetricsCollector(workerID string, batcher *Batcher, queue *PriorityQueue, useNVML string) *MetricsCollector {
+// NewMetricsCollectorWithSource creates a collector reading hardware stats
+// from src. A nil src selects simulated metrics.
+func NewMetricsCollectorWithSource(workerID string, devices []*Device, queue *PriorityQueue, gpuShare float64, src GPUMetricsSource) *MetricsCollector {
+	log := logging.For("metrics")
+	if gpuShare <= 0 || gpuShare > 1 {
+		log.Warn("GPU share out of range (0, 1], using the whole device", "share", gpuShare)
+		gpuShare = 1
+	}
 	mc := &MetricsCollector{
-		workerID:       workerID,
-		batcher:        batcher,
-		queue:          queue,
-		simVRAMTotalGB: 5.0, // 5GB vGPU slice (T4 / 3)
-		simVRAMUsedGB:  0.8, // base ONNX model footprint
-		simTempC:       42.0,
-		simGPUUtil:     0.0,
-	}
-
-	// Check if NVML is available
-	if useNVML == "true" || (useNVML == "auto" && mc.tryNVML()) {
-		mc.useNVML = true
-		log.Printf("📊 Metrics: using REAL NVML")
-	} else {
-		mc.useNVML = false
-		log.Printf("📊 Metrics: using SIMULATED GPU stats")
+		log:       log,
+		workerID:  workerID,
+		devices:   devices,
+		queue:     queue,
+		share:     gpuShare,
+		sim:       make([]deviceSim, len(devices)),
+		startedAt: time.Now(),
+		samplers:  make(map[time.Duration]*sampler),
+		stopCh:    make(chan struct{}),
+	}
+	for i := range mc.sim {
+		mc.sim[i] = deviceSim{
+			vramTotalGB: simVRAMTotalGB,
+			vramUsedGB:  0.8, // base ONNX model footprint
+			tempC:       42.0,
+			gpuUtil:     0.0,
+			powerW:      simIdlePowerW,
+			smClock:     simIdleSMClock,
+			throttle:    gpu.ThrottleGpuIdle,
+		}
+	}
+
+	// Every served device must exist on the hardware side
+	if src != nil {
+		for _, d := range devices {
+			if d.Index >= src.GPUCount() {
+				log.Warn("device not visible to NVML, using simulated stats", "device", d.Index, "gpus", src.GPUCount())
+				src.Shutdown()
+				src = nil
+				break
+			}
+		}
 	}
+	mc.gpu = src
 
-	// Start background simulation ticker
-	if !mc.useNVML {
+	if mc.gpu != nil {
+		log.Info("using real NVML metrics")
+	} else {
+		log.Info("using simulated GPU metrics")
+		// Start background simulation ticker
 		go mc.simulationLoop()
 	}
 
 	return mc
 }
 
-// GetMetrics returns current worker metrics as a protobuf message.
+// Close stops the simulation loop and releases the hardware source.
+func (mc *MetricsCollector) Close() {
+	mc.closeOnce.Do(func() {
+		close(mc.stopCh)
+		if mc.gpu != nil {
+			mc.gpu.Shutdown()
+		}
+	})
+}
+
+// GetMetrics returns current worker metrics as a protobuf message for the
+// router, stamped with a sequence number and the collection and process
+// start times.
 func (mc *MetricsCollector) GetMetrics() *pb.WorkerMetrics {
-	if mc.useNVML {
+	m := mc.collect()
+	m.Seq = mc.seq.Add(1)
+	m.CollectedAtUnixMs = time.Now().UnixMilli()
+	m.StartedAtUnixMs = mc.startedAt.UnixMilli()
+	return m
+}
+
+// collect returns current worker metrics without a sequence number, for
+// local readers such as /metrics that must not advance the one the router
+// orders updates by.
+func (mc *MetricsCollector) collect() *pb.WorkerMetrics {
+	if mc.gpu != nil {
 		return mc.getRealMetrics()
 	}
 	return mc.getSimulatedMetrics()
 }
 
+// sampler collects a snapshot every interval and hands it to each watcher.
+type sampler struct {
+	watchers map[chan *pb.WorkerMetrics]struct{}
+	stop     chan struct{}
+}
+
+// Watch returns a channel that receives a snapshot every interval, until
+// cancel is called. Watchers with the same interval share one sampling
+// loop, so each snapshot is collected once however many streams are open.
+// A watcher that hasn't taken the last snapshot gets the newer one in its
+// place. Snapshots are shared and must not be modified.
+func (mc *MetricsCollector) Watch(interval time.Duration) (<-chan *pb.WorkerMetrics, func()) {
+	ch := make(chan *pb.WorkerMetrics, 1)
+	mc.samplersMu.Lock()
+	s, ok := mc.samplers[interval]
+	if !ok {
+		s = &sampler{watchers: make(map[chan *pb.WorkerMetrics]struct{}), stop: make(chan struct{})}
+		mc.samplers[interval] = s
+		go mc.sample(s, interval)
+	}
+	s.watchers[ch] = struct{}{}
+	mc.samplersMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			mc.samplersMu.Lock()
+			defer mc.samplersMu.Unlock()
+			delete(s.watchers, ch)
+			if len(s.watchers) == 0 {
+				delete(mc.samplers, interval)
+				close(s.stop)
+			}
+		})
+	}
+}
+
+// sample is s's loop: it runs until the last watcher leaves or the
+// collector closes.
+func (mc *MetricsCollector) sample(s *sampler, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-mc.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		m := mc.GetMetrics()
+		mc.samplersMu.Lock()
+		for ch := range s.watchers {
+			// Only this loop sends, so after dropping a stale snapshot there is room
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- m
+		}
+		mc.samplersMu.Unlock()
+	}
+}
+
 func (mc *MetricsCollector) getSimulatedMetrics() *pb.WorkerMetrics {
 	mc.mu.RLock()
 	defer mc.mu.RUnlock()
 
-	return &pb.WorkerMetrics{
-		WorkerId:       mc.workerID,
-		VramFreeGb:     mc.simVRAMTotalGB - mc.simVRAMUsedGB,
-		VramTotalGb:    mc.simVRAMTotalGB,
-		QueueDepth:     int32(mc.queue.Depth()),
-		AvgLatencyMs:   float64(mc.batcher.AvgLatencyMs.Load()),
-		GpuUtilization: mc.simGPUUtil,
-		TemperatureC:   mc.simTempC,
-		CurrentBatch:   mc.batcher.LastBatchSize.Load(),
-		Healthy:        true,
+	m := &pb.WorkerMetrics{
+		WorkerId:      mc.workerID,
+		QueueDepth:    int32(mc.queue.Depth()),
+		Healthy:       true,
+		Devices:       make([]*pb.DeviceMetrics, len(mc.devices)),
+		MetricsSource: MetricsSourceSimulated,
+		GpuShare:      mc.share,
 	}
+	for i, d := range mc.devices {
+		sim := mc.sim[i]
+		m.Devices[i] = &pb.DeviceMetrics{
+			Index:             int32(d.Index),
+			Executor:          d.Exec.Name(),
+			DeviceVramFreeGb:  sim.vramTotalGB - sim.vramUsedGB,
+			DeviceVramTotalGb: sim.vramTotalGB,
+			GpuUtilization:    sim.gpuUtil,
+			TemperatureC:      sim.tempC,
+			CurrentBatch:      d.Batcher.LastBatchSize.Load(),
+			AvgLatencyMs:      float64(d.Batcher.AvgLatencyMs.Load()),
+			TotalBatches:      d.Batcher.TotalBatches.Load(),
+
+			PowerDrawW:      sim.powerW,
+			PowerLimitW:     simPowerLimitW,
+			SmClockMhz:      sim.smClock,
+			MemClockMhz:     simMemClock,
+			ThrottleReasons: sim.throttle,
+			PcieTxKbps:      sim.pcieTxKBs,
+			PcieRxKbps:      sim.pcieRxKBs,
+			FanSpeedPct:     sim.fanPct,
+		}
+		// The simulated device has no other tenants: all used memory is ours
+		mc.applyShare(m.Devices[i], sim.vramUsedGB, true)
+	}
+	aggregateDevices(m)
+	return m
 }
 
-func (mc *MetricsCollector) getRealMetrics() *pb.WorkerMetrics {
-	// TODO: Implement real NVML via CGo
-	// For now, fall back to simulated
-	return mc.getSimulatedMetrics()
+// applyShare derives this worker's view of device memory. The budget is
+// share × device total; headroom is that budget minus what this process
+// holds, capped by what is actually free on the device. Without per-process
+// data, the device's free memory is split proportionally.
+func (mc *MetricsCollector) applyShare(dm *pb.DeviceMetrics, processUsedGB float64, tracked bool) {
+	budget := dm.DeviceVramTotalGb * mc.share
+	dm.VramTotalGb = budget
+	if tracked {
+		dm.ProcessVramUsedGb = processUsedGB
+		dm.VramFreeGb = math.Min(budget-processUsedGB, dm.DeviceVramFreeGb)
+	} else {
+		dm.VramFreeGb = dm.DeviceVramFreeGb * mc.share
+	}
+	dm.VramFreeGb = math.Max(dm.VramFreeGb, 0)
+}
+
+// aggregateDevices fills the worker-level GPU fields from m.Devices so
+// routers that only look at the top-level numbers still see the whole
+// worker: VRAM, power, ECC and PCIe are summed, utilization and latency
+// averaged, temperature / batch size / fan take the worst device, clocks
+// the slowest, and throttle reasons are OR-ed together.
+func aggregateDevices(m *pb.WorkerMetrics) {
+	if len(m.Devices) == 0 {
+		return
+	}
+	var util, latency float64
+	for i, d := range m.Devices {
+		m.VramFreeGb += d.VramFreeGb
+		m.VramTotalGb += d.VramTotalGb
+		util += d.GpuUtilization
+		latency += d.AvgLatencyMs
+		m.TemperatureC = math.Max(m.TemperatureC, d.TemperatureC)
+		if d.CurrentBatch > m.CurrentBatch {
+			m.CurrentBatch = d.CurrentBatch
+		}
+
+		m.PowerDrawW += d.PowerDrawW
+		m.PowerLimitW += d.PowerLimitW
+		if i == 0 || d.SmClockMhz < m.SmClockMhz {
+			m.SmClockMhz = d.SmClockMhz
+		}
+		if i == 0 || d.MemClockMhz < m.MemClockMhz {
+			m.MemClockMhz = d.MemClockMhz
+		}
+		m.ThrottleReasons |= d.ThrottleReasons
+		m.EccCorrected += d.EccCorrected
+		m.EccUncorrected += d.EccUncorrected
+		m.PcieTxKbps += d.PcieTxKbps
+		m.PcieRxKbps += d.PcieRxKbps
+		m.FanSpeedPct = math.Max(m.FanSpeedPct, d.FanSpeedPct)
+
+		m.ProcessVramUsedGb += d.ProcessVramUsedGb
+		m.DeviceVramFreeGb += d.DeviceVramFreeGb
+		m.DeviceVramTotalGb += d.DeviceVramTotalGb
+	}
+	n := float64(len(m.Devices))
+	m.GpuUtilization = util / n
+	m.AvgLatencyMs = latency / n
 }
 
-func (mc *MetricsCollector) tryNVML() bool {
-	// TODO: Try to dlopen libnvidia-ml.so
-	// Return true if successful
-	return false
+func (mc *MetricsCollector) getRealMetrics() *pb.WorkerMetrics {
+	m := &pb.WorkerMetrics{
+		WorkerId:      mc.workerID,
+		QueueDepth:    int32(mc.queue.Depth()),
+		Healthy:       true,
+		Devices:       make([]*pb.DeviceMetrics, len(mc.devices)),
+		MetricsSource: MetricsSourceNVML,
+		GpuShare:      mc.share,
+	}
+	for i, d := range mc.devices {
+		dm := &pb.DeviceMetrics{
+			Index:        int32(d.Index),
+			Executor:     d.Exec.Name(),
+			CurrentBatch: d.Batcher.LastBatchSize.Load(),
+			AvgLatencyMs: float64(d.Batcher.AvgLatencyMs.Load()),
+			TotalBatches: d.Batcher.TotalBatches.Load(),
+		}
+		// A failed read leaves the hardware fields zeroed for this poll
+		if info, err := mc.gpu.GetGPUInfo(d.Index); err == nil {
+			dm.DeviceVramFreeGb = info.MemoryFreeGB
+			dm.DeviceVramTotalGb = info.MemoryTotalGB
+			mc.applyShare(dm, info.ProcessMemoryUsedGB, info.ProcessTracked)
+			dm.GpuUtilization = info.GPUUtilization
+			dm.TemperatureC = info.TemperatureC
+			dm.PowerDrawW = info.PowerDrawW
+			dm.PowerLimitW = info.PowerLimitW
+			dm.SmClockMhz = info.SMClockMHz
+			dm.MemClockMhz = info.MemClockMHz
+			dm.ThrottleReasons = info.ThrottleReasons
+			dm.EccCorrected = info.ECCCorrected
+			dm.EccUncorrected = info.ECCUncorrected
+			dm.PcieTxKbps = info.PCIeTxKBps
+			dm.PcieRxKbps = info.PCIeRxKBps
+			dm.FanSpeedPct = info.FanSpeedPct
+		} else {
+			mc.log.Warn("NVML read failed", "device", d.Index, "err", err)
+		}
+		m.Devices[i] = dm
+	}
+	aggregateDevices(m)
+	return m
 }
 
 // simulationLoop updates simulated GPU metrics based on actual worker load.
@@ -102,27 +404,60 @@ func (mc *MetricsCollector) simulationLoop() {
 	ticker := time.NewTicker(200 * time.Millisecond)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-mc.stopCh:
+			return
+		case <-ticker.C:
+		}
+
 		mc.mu.Lock()
 
-		queueDepth := float64(mc.queue.Depth())
-		batchSize := float64(mc.batcher.LastBatchSize.Load())
-		inFlight := float64(mc.inFlight.Load())
+		// The queue and in-flight requests are shared by all devices
+		n := float64(len(mc.devices))
+		queueDepth := float64(mc.queue.Depth()) / n
+		inFlight := float64(mc.inFlight.Load()) / n
 
-		// GPU utilization: based on queue depth and in-flight requests
-		targetUtil := math.Min(100, (queueDepth*3)+(inFlight*15)+(batchSize*2))
-		// Smooth transition (exponential decay)
-		mc.simGPUUtil = mc.simGPUUtil*0.7 + targetUtil*0.3
+		for i, d := range mc.devices {
+			sim := &mc.sim[i]
+			batchSize := float64(d.Batcher.LastBatchSize.Load())
 
-		// VRAM: base footprint + proportional to batch activity
-		mc.simVRAMUsedGB = 0.8 + (batchSize/32.0)*2.5
-		mc.simVRAMUsedGB = math.Min(mc.simVRAMUsedGB, mc.simVRAMTotalGB-0.2)
+			// GPU utilization: based on queue depth and in-flight requests
+			targetUtil := math.Min(100, (queueDepth*3)+(inFlight*15)+(batchSize*2))
+			// Smooth transition (exponential decay)
+			sim.gpuUtil = sim.gpuUtil*0.7 + targetUtil*0.3
 
-		// Temperature: rises with utilization, cools at idle
-		targetTemp := 42.0 + (mc.simGPUUtil/100.0)*38.0 // 42°C idle → 80°C full load
-		mc.simTempC = mc.simTempC*0.9 + targetTemp*0.1
-		// Add slight noise
-		mc.simTempC += (rand.Float64() - 0.5) * 0.5
+			// VRAM: base footprint + proportional to batch activity
+			sim.vramUsedGB = 0.8 + (batchSize/32.0)*2.5
+			sim.vramUsedGB = math.Min(sim.vramUsedGB, sim.vramTotalGB-0.2)
+
+			// Temperature: rises with utilization, cools at idle
+			targetTemp := 42.0 + (sim.gpuUtil/100.0)*38.0 // 42°C idle → 80°C full load
+			sim.tempC = sim.tempC*0.9 + targetTemp*0.1
+			// Add slight noise
+			sim.tempC += (rand.Float64() - 0.5) * 0.5
+
+			// Power and clocks follow utilization; near 80°C the driver
+			// would pull clocks down, so model a thermal slowdown there
+			sim.powerW = simIdlePowerW + (sim.gpuUtil/100.0)*(simPowerLimitW-simIdlePowerW)
+			sim.smClock = simIdleSMClock + (sim.gpuUtil/100.0)*(simMaxSMClock-simIdleSMClock)
+			sim.throttle = 0
+			switch {
+			case sim.gpuUtil < 1:
+				sim.throttle |= gpu.ThrottleGpuIdle
+			case sim.tempC > 78:
+				sim.throttle |= gpu.ThrottleSwThermalSlowdown
+				sim.smClock *= 0.75
+			}
+			if sim.powerW > simPowerLimitW*0.97 {
+				sim.throttle |= gpu.ThrottleSwPowerCap
+			}
+
+			// PCIe traffic: inputs in, results out (~600KB per image at 224x224x3 fp32)
+			sim.pcieRxKBs = batchSize * 600 * (sim.gpuUtil / 100.0) * 10
+			sim.pcieTxKBs = batchSize * 4 * (sim.gpuUtil / 100.0) * 10
+			sim.fanPct = 30 + sim.gpuUtil*0.5
+		}
 
 		mc.mu.Unlock()
 	}
@@ -132,35 +467,18 @@ func (mc *MetricsCollector) simulationLoop() {
 func (mc *MetricsCollector) IncrInFlight() { mc.inFlight.Add(1) }
 func (mc *MetricsCollector) DecrInFlight() { mc.inFlight.Add(-1) }
 
-// ServePrometheus writes Prometheus-format metrics to HTTP response.
-func (mc *MetricsCollector) ServePrometheus(w http.ResponseWriter, r *http.Request) {
-	m := mc.GetMetrics()
-	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
-	fmt.Fprintf(w, "# HELP gpu_vram_free_gb Free VRAM in GB\n")
-	fmt.Fprintf(w, "# TYPE gpu_vram_free_gb gauge\n")
-	fmt.Fprintf(w, "gpu_vram_free_gb{worker=\"%s\"} %.2f\n", m.WorkerId, m.VramFreeGb)
-	fmt.Fprintf(w, "# HELP gpu_vram_total_gb Total VRAM in GB\n")
-	fmt.Fprintf(w, "# TYPE gpu_vram_total_gb gauge\n")
-	fmt.Fprintf(w, "gpu_vram_total_gb{worker=\"%s\"} %.2f\n", m.WorkerId, m.VramTotalGb)
-	fmt.Fprintf(w, "# HELP gpu_utilization GPU utilization percentage\n")
-	fmt.Fprintf(w, "# TYPE gpu_utilization gauge\n")
-	fmt.Fprintf(w, "gpu_utilization{worker=\"%s\"} %.2f\n", m.WorkerId, m.GpuUtilization)
-	fmt.Fprintf(w, "# HELP gpu_temperature_celsius GPU temperature\n")
-	fmt.Fprintf(w, "# TYPE gpu_temperature_celsius gauge\n")
-	fmt.Fprintf(w, "gpu_temperature_celsius{worker=\"%s\"} %.1f\n", m.WorkerId, m.TemperatureC)
-	fmt.Fprintf(w, "# HELP worker_queue_depth Current queue depth\n")
-	fmt.Fprintf(w, "# TYPE worker_queue_depth gauge\n")
-	fmt.Fprintf(w, "worker_queue_depth{worker=\"%s\"} %d\n", m.WorkerId, m.QueueDepth)
-	fmt.Fprintf(w, "# HELP worker_avg_latency_ms Average batch latency\n")
-	fmt.Fprintf(w, "# TYPE worker_avg_latency_ms gauge\n")
-	fmt.Fprintf(w, "worker_avg_latency_ms{worker=\"%s\"} %.2f\n", m.WorkerId, m.AvgLatencyMs)
-	fmt.Fprintf(w, "# HELP worker_batch_size Last batch size\n")
-	fmt.Fprintf(w, "# TYPE worker_batch_size gauge\n")
-	fmt.Fprintf(w, "worker_batch_size{worker=\"%s\"} %d\n", m.WorkerId, m.CurrentBatch)
-	fmt.Fprintf(w, "# HELP worker_total_batches Total batches processed\n")
-	fmt.Fprintf(w, "# TYPE worker_total_batches counter\n")
-	fmt.Fprintf(w, "worker_total_batches{worker=\"%s\"} %d\n", m.WorkerId, mc.batcher.TotalBatches.Load())
-	fmt.Fprintf(w, "# HELP worker_total_requests Total requests processed\n")
-	fmt.Fprintf(w, "# TYPE worker_total_requests counter\n")
-	fmt.Fprintf(w, "worker_total_requests{worker=\"%s\"} %d\n", m.WorkerId, mc.batcher.TotalRequests.Load())
+func (mc *MetricsCollector) totalBatches() int64 {
+	var n int64
+	for _, d := range mc.devices {
+		n += d.Batcher.TotalBatches.Load()
+	}
+	return n
+}
+
+func (mc *MetricsCollector) totalRequests() int64 {
+	var n int64
+	for _, d := range mc.devices {
+		n += d.Batcher.TotalRequests.Load()
+	}
+	return n
 }
@@ -0,0 +1,231 @@
+package worker
+
+import (
+	"errors"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/tracing"
+	"github.com/kunal/gpu-batch-router/pkg/worker/executor"
+	"github.com/kunal/gpu-batch-router/pkg/worker/nvml"
+)
+
+// fakeGPU is a GPUMetricsSource with fixed readings per index. Indices in
+// fail return an error instead.
+type fakeGPU struct {
+	count int
+	info  map[int]*nvml.GPUInfo
+	fail  map[int]bool
+
+	mu       sync.Mutex
+	shutdown bool
+}
+
+func (f *fakeGPU) GPUCount() int { return f.count }
+
+func (f *fakeGPU) GetGPUInfo(index int) (*nvml.GPUInfo, error) {
+	if f.fail[index] {
+		return nil, errors.New("GPU is lost")
+	}
+	return f.info[index], nil
+}
+
+func (f *fakeGPU) Shutdown() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.shutdown = true
+}
+
+func (f *fakeGPU) isShutdown() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.shutdown
+}
+
+// testDevices returns devices with the given indices, each with a
+// simulated executor and an idle batcher.
+func testDevices(indices ...int) []*Device {
+	devices := make([]*Device, len(indices))
+	for i, idx := range indices {
+		devices[i] = &Device{Index: idx, Exec: executor.NewSimulated(1), Batcher: &Batcher{}}
+	}
+	return devices
+}
+
+func twoGPUs() *fakeGPU {
+	return &fakeGPU{
+		count: 2,
+		info: map[int]*nvml.GPUInfo{
+			0: {MemoryTotalGB: 16, MemoryFreeGB: 10, GPUUtilization: 40, TemperatureC: 60, PowerDrawW: 50},
+			1: {MemoryTotalGB: 16, MemoryFreeGB: 4, GPUUtilization: 80, TemperatureC: 70, PowerDrawW: 60},
+		},
+	}
+}
+
+func TestMetricsSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     GPUMetricsSource
+		devices []int
+		want    string
+	}{
+		{"no source", nil, []int{0}, MetricsSourceSimulated},
+		{"every device visible", twoGPUs(), []int{0, 1}, MetricsSourceNVML},
+		{"device out of range", twoGPUs(), []int{0, 2}, MetricsSourceSimulated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := NewMetricsCollectorWithSource("w1", testDevices(tt.devices...), NewPriorityQueue(), 1, tt.src)
+			defer mc.Close()
+			if got := mc.GetMetrics().MetricsSource; got != tt.want {
+				t.Errorf("metrics_source = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsOutOfRangeFallback(t *testing.T) {
+	src := twoGPUs()
+	mc := NewMetricsCollectorWithSource("w1", testDevices(0, 2), NewPriorityQueue(), 1, src)
+	defer mc.Close()
+
+	if !src.isShutdown() {
+		t.Error("the source was kept after a device was found missing")
+	}
+	m := mc.GetMetrics()
+	if len(m.Devices) != 2 || m.Devices[1].Index != 2 {
+		t.Fatalf("devices = %v, want indices 0 and 2", m.Devices)
+	}
+	// Simulated devices are whole T4s
+	for _, d := range m.Devices {
+		if d.DeviceVramTotalGb != simVRAMTotalGB {
+			t.Errorf("device %d total VRAM = %v, want the simulated %v", d.Index, d.DeviceVramTotalGb, simVRAMTotalGB)
+		}
+	}
+}
+
+func TestSimulatedShare(t *testing.T) {
+	tests := []struct {
+		share, wantTotal float64
+	}{
+		{1, simVRAMTotalGB},
+		{1.0 / 3, simVRAMTotalGB / 3}, // a T4 time-sliced three ways
+	}
+	for _, tt := range tests {
+		mc := NewMetricsCollectorWithSource("w1", testDevices(0), NewPriorityQueue(), tt.share, nil)
+		d := mc.GetMetrics().Devices[0]
+		mc.Close()
+		if d.DeviceVramTotalGb != simVRAMTotalGB {
+			t.Errorf("share %.2f: device total %v, want the whole simulated device", tt.share, d.DeviceVramTotalGb)
+		}
+		if math.Abs(d.VramTotalGb-tt.wantTotal) > 1e-9 {
+			t.Errorf("share %.2f: worker budget %v GB, want %v", tt.share, d.VramTotalGb, tt.wantTotal)
+		}
+		if want := d.VramTotalGb - d.ProcessVramUsedGb; math.Abs(d.VramFreeGb-want) > 1e-9 {
+			t.Errorf("share %.2f: free %v GB, want the budget less the process's %v", tt.share, d.VramFreeGb, d.ProcessVramUsedGb)
+		}
+	}
+}
+
+func TestMetricsReadFailure(t *testing.T) {
+	src := twoGPUs()
+	src.fail = map[int]bool{1: true}
+	mc := NewMetricsCollectorWithSource("w1", testDevices(0, 1), NewPriorityQueue(), 1, src)
+	defer mc.Close()
+
+	m := mc.GetMetrics()
+	if m.MetricsSource != MetricsSourceNVML {
+		t.Errorf("metrics_source = %q, want %q after one failed read", m.MetricsSource, MetricsSourceNVML)
+	}
+	if !m.Healthy {
+		t.Error("a failed read marked the worker unhealthy")
+	}
+
+	ok, failed := m.Devices[0], m.Devices[1]
+	if ok.DeviceVramTotalGb != 16 || ok.GpuUtilization != 40 || ok.TemperatureC != 60 {
+		t.Errorf("readable device = %+v", ok)
+	}
+	if failed.Index != 1 || failed.Executor == "" {
+		t.Errorf("failed device lost its identity: %+v", failed)
+	}
+	if failed.DeviceVramTotalGb != 0 || failed.VramFreeGb != 0 || failed.GpuUtilization != 0 ||
+		failed.TemperatureC != 0 || failed.PowerDrawW != 0 {
+		t.Errorf("failed device reports hardware values: %+v", failed)
+	}
+
+	// The worker totals only count what could be read
+	if m.VramTotalGb != 16 || m.VramFreeGb != 10 || m.PowerDrawW != 50 || m.TemperatureC != 60 {
+		t.Errorf("totals = vram %v/%v, power %v, temp %v", m.VramFreeGb, m.VramTotalGb, m.PowerDrawW, m.TemperatureC)
+	}
+	if m.GpuUtilization != 20 {
+		t.Errorf("utilization = %v, want 20 (averaged with the unread device at 0)", m.GpuUtilization)
+	}
+}
+
+func TestWatchSharesSamples(t *testing.T) {
+	mc := NewMetricsCollectorWithSource("w1", testDevices(0), NewPriorityQueue(), 1, nil)
+	defer mc.Close()
+
+	const interval = 50 * time.Millisecond
+	a, stopA := mc.Watch(interval)
+	b, stopB := mc.Watch(interval)
+	c, stopC := mc.Watch(2 * interval)
+	defer stopC()
+
+	for range 3 {
+		fromA, fromB := <-a, <-b
+		if fromA != fromB {
+			t.Fatalf("watchers got different snapshots, seq %d and %d", fromA.Seq, fromB.Seq)
+		}
+	}
+	<-c
+
+	// Nobody reads b; a keeps getting every sample, and b only the newest
+	before := mc.seq.Load()
+	var last *pb.WorkerMetrics
+	for range 3 {
+		last = <-a
+	}
+	if got := <-b; got != last {
+		t.Errorf("an idle watcher got seq %d, want the newest, %d", got.Seq, last.Seq)
+	}
+	// One loop per interval: 3 samples for a and b, one or two for c. A
+	// loop per watcher would take at least 7
+	if n := mc.seq.Load() - before; n > 6 {
+		t.Errorf("%d snapshots collected for 3 intervals", n)
+	}
+
+	stopA()
+	stopB()
+	stopB()
+	mc.samplersMu.Lock()
+	_, shared := mc.samplers[interval]
+	n := len(mc.samplers)
+	mc.samplersMu.Unlock()
+	if shared || n != 1 {
+		t.Errorf("after its watchers left, %d samplers remain (shared one: %v)", n, shared)
+	}
+}
+
+func TestScrapeLeavesSeqAlone(t *testing.T) {
+	mc := NewMetricsCollectorWithSource("w1", testDevices(0), NewPriorityQueue(), 1, twoGPUs())
+	defer mc.Close()
+	w := &Worker{metrics: mc, tel: newTelemetry("w1", nil, tracing.New("w1", nil, 0))}
+
+	first := mc.GetMetrics().Seq
+	for range 3 {
+		rec := httptest.NewRecorder()
+		w.ServePrometheus(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if !strings.Contains(rec.Body.String(), "gpu_device_vram_free_gb") {
+			t.Fatalf("scrape is missing the device gauges:\n%s", rec.Body)
+		}
+	}
+	if next := mc.GetMetrics().Seq; next != first+1 {
+		t.Errorf("seq went from %d to %d across 3 scrapes, want %d", first, next, first+1)
+	}
+}
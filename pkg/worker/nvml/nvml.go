@@ -23,6 +23,23 @@ typedef struct {
     unsigned int memory;
 } nvmlUtilization_t;
 
+// Running-process records. The _v2/_v3 entry points use the wider struct
+// (MIG instance IDs); the legacy entry point uses the v1 layout.
+typedef struct {
+    unsigned int pid;
+    unsigned long long usedGpuMemory;
+} nvmlProcessInfo_v1_t;
+
+typedef struct {
+    unsigned int pid;
+    unsigned long long usedGpuMemory;
+    unsigned int gpuInstanceId;
+    unsigned int computeInstanceId;
+} nvmlProcessInfo_v2_t;
+
+#define NVML_ERROR_INSUFFICIENT_SIZE 7
+#define NVML_VALUE_NOT_AVAILABLE_ULL 0xFFFFFFFFFFFFFFFFULL
+
 // Function pointers
 static void* nvml_lib = NULL;
 
@@ -34,6 +51,14 @@ typedef nvmlReturn_t (*nvmlDeviceGetMemoryInfo_t)(nvmlDevice_t, nvmlMemory_t*);
 typedef nvmlReturn_t (*nvmlDeviceGetUtilizationRates_t)(nvmlDevice_t, nvmlUtilization_t*);
 typedef nvmlReturn_t (*nvmlDeviceGetTemperature_t)(nvmlDevice_t, int, unsigned int*);
 typedef nvmlReturn_t (*nvmlDeviceGetName_t)(nvmlDevice_t, char*, unsigned int);
+typedef nvmlReturn_t (*nvmlDeviceGetPowerUsage_t)(nvmlDevice_t, unsigned int*);
+typedef nvmlReturn_t (*nvmlDeviceGetEnforcedPowerLimit_t)(nvmlDevice_t, unsigned int*);
+typedef nvmlReturn_t (*nvmlDeviceGetClockInfo_t)(nvmlDevice_t, int, unsigned int*);
+typedef nvmlReturn_t (*nvmlDeviceGetCurrentClocksThrottleReasons_t)(nvmlDevice_t, unsigned long long*);
+typedef nvmlReturn_t (*nvmlDeviceGetTotalEccErrors_t)(nvmlDevice_t, int, int, unsigned long long*);
+typedef nvmlReturn_t (*nvmlDeviceGetPcieThroughput_t)(nvmlDevice_t, int, unsigned int*);
+typedef nvmlReturn_t (*nvmlDeviceGetFanSpeed_t)(nvmlDevice_t, unsigned int*);
+typedef nvmlReturn_t (*nvmlDeviceGetComputeRunningProcesses_t)(nvmlDevice_t, unsigned int*, void*);
 
 static nvmlInit_t f_nvmlInit = NULL;
 static nvmlShutdown_t f_nvmlShutdown = NULL;
@@ -43,6 +68,15 @@ static nvmlDeviceGetMemoryInfo_t f_nvmlDeviceGetMemoryInfo = NULL;
 static nvmlDeviceGetUtilizationRates_t f_nvmlDeviceGetUtilizationRates = NULL;
 static nvmlDeviceGetTemperature_t f_nvmlDeviceGetTemperature = NULL;
 static nvmlDeviceGetName_t f_nvmlDeviceGetName = NULL;
+static nvmlDeviceGetPowerUsage_t f_nvmlDeviceGetPowerUsage = NULL;
+static nvmlDeviceGetEnforcedPowerLimit_t f_nvmlDeviceGetEnforcedPowerLimit = NULL;
+static nvmlDeviceGetClockInfo_t f_nvmlDeviceGetClockInfo = NULL;
+static nvmlDeviceGetCurrentClocksThrottleReasons_t f_nvmlDeviceGetCurrentClocksThrottleReasons = NULL;
+static nvmlDeviceGetTotalEccErrors_t f_nvmlDeviceGetTotalEccErrors = NULL;
+static nvmlDeviceGetPcieThroughput_t f_nvmlDeviceGetPcieThroughput = NULL;
+static nvmlDeviceGetFanSpeed_t f_nvmlDeviceGetFanSpeed = NULL;
+static nvmlDeviceGetComputeRunningProcesses_t f_nvmlDeviceGetComputeRunningProcesses = NULL;
+static int procs_v2 = 0; // 1 if f_nvmlDeviceGetComputeRunningProcesses takes nvmlProcessInfo_v2_t
 
 static int nvml_load() {
     nvml_lib = dlopen("libnvidia-ml.so.1", RTLD_LAZY);
@@ -63,6 +97,21 @@ static int nvml_load() {
     f_nvmlDeviceGetTemperature = (nvmlDeviceGetTemperature_t)dlsym(nvml_lib, "nvmlDeviceGetTemperature");
     f_nvmlDeviceGetName = (nvmlDeviceGetName_t)dlsym(nvml_lib, "nvmlDeviceGetName");
 
+    // Extended telemetry — all optional, older drivers may lack some
+    f_nvmlDeviceGetPowerUsage = (nvmlDeviceGetPowerUsage_t)dlsym(nvml_lib, "nvmlDeviceGetPowerUsage");
+    f_nvmlDeviceGetEnforcedPowerLimit = (nvmlDeviceGetEnforcedPowerLimit_t)dlsym(nvml_lib, "nvmlDeviceGetEnforcedPowerLimit");
+    f_nvmlDeviceGetClockInfo = (nvmlDeviceGetClockInfo_t)dlsym(nvml_lib, "nvmlDeviceGetClockInfo");
+    f_nvmlDeviceGetCurrentClocksThrottleReasons = (nvmlDeviceGetCurrentClocksThrottleReasons_t)dlsym(nvml_lib, "nvmlDeviceGetCurrentClocksEventReasons");
+    if (!f_nvmlDeviceGetCurrentClocksThrottleReasons) f_nvmlDeviceGetCurrentClocksThrottleReasons = (nvmlDeviceGetCurrentClocksThrottleReasons_t)dlsym(nvml_lib, "nvmlDeviceGetCurrentClocksThrottleReasons");
+    f_nvmlDeviceGetTotalEccErrors = (nvmlDeviceGetTotalEccErrors_t)dlsym(nvml_lib, "nvmlDeviceGetTotalEccErrors");
+    f_nvmlDeviceGetPcieThroughput = (nvmlDeviceGetPcieThroughput_t)dlsym(nvml_lib, "nvmlDeviceGetPcieThroughput");
+    f_nvmlDeviceGetFanSpeed = (nvmlDeviceGetFanSpeed_t)dlsym(nvml_lib, "nvmlDeviceGetFanSpeed");
+
+    f_nvmlDeviceGetComputeRunningProcesses = (nvmlDeviceGetComputeRunningProcesses_t)dlsym(nvml_lib, "nvmlDeviceGetComputeRunningProcesses_v3");
+    if (!f_nvmlDeviceGetComputeRunningProcesses) f_nvmlDeviceGetComputeRunningProcesses = (nvmlDeviceGetComputeRunningProcesses_t)dlsym(nvml_lib, "nvmlDeviceGetComputeRunningProcesses_v2");
+    procs_v2 = f_nvmlDeviceGetComputeRunningProcesses != NULL;
+    if (!f_nvmlDeviceGetComputeRunningProcesses) f_nvmlDeviceGetComputeRunningProcesses = (nvmlDeviceGetComputeRunningProcesses_t)dlsym(nvml_lib, "nvmlDeviceGetComputeRunningProcesses");
+
     if (!f_nvmlInit || !f_nvmlDeviceGetCount || !f_nvmlDeviceGetHandleByIndex) return -2;
 
     return f_nvmlInit();
@@ -113,6 +162,109 @@ static int nvml_get_name(int idx, char* name, int len) {
     return 0;
 }
 
+// Power draw and enforced limit, in milliwatts
+static int nvml_get_power(int idx, unsigned int* usage, unsigned int* limit) {
+    nvmlDevice_t dev;
+    if (f_nvmlDeviceGetHandleByIndex(idx, &dev) != 0) return -1;
+    if (!f_nvmlDeviceGetPowerUsage) return -2;
+    if (f_nvmlDeviceGetPowerUsage(dev, usage) != 0) return -3;
+    if (!f_nvmlDeviceGetEnforcedPowerLimit || f_nvmlDeviceGetEnforcedPowerLimit(dev, limit) != 0) *limit = 0;
+    return 0;
+}
+
+// SM and memory clocks, in MHz
+static int nvml_get_clocks(int idx, unsigned int* sm, unsigned int* mem) {
+    nvmlDevice_t dev;
+    if (f_nvmlDeviceGetHandleByIndex(idx, &dev) != 0) return -1;
+    if (!f_nvmlDeviceGetClockInfo) return -2;
+    // NVML_CLOCK_SM = 1, NVML_CLOCK_MEM = 2
+    if (f_nvmlDeviceGetClockInfo(dev, 1, sm) != 0) return -3;
+    if (f_nvmlDeviceGetClockInfo(dev, 2, mem) != 0) return -4;
+    return 0;
+}
+
+static int nvml_get_throttle_reasons(int idx, unsigned long long* reasons) {
+    nvmlDevice_t dev;
+    if (f_nvmlDeviceGetHandleByIndex(idx, &dev) != 0) return -1;
+    if (!f_nvmlDeviceGetCurrentClocksThrottleReasons) return -2;
+    if (f_nvmlDeviceGetCurrentClocksThrottleReasons(dev, reasons) != 0) return -3;
+    return 0;
+}
+
+// Volatile (since driver load) ECC error counts
+static int nvml_get_ecc(int idx, unsigned long long* corrected, unsigned long long* uncorrected) {
+    nvmlDevice_t dev;
+    if (f_nvmlDeviceGetHandleByIndex(idx, &dev) != 0) return -1;
+    if (!f_nvmlDeviceGetTotalEccErrors) return -2;
+    // NVML_MEMORY_ERROR_TYPE_CORRECTED = 0, _UNCORRECTED = 1; NVML_VOLATILE_ECC = 0
+    if (f_nvmlDeviceGetTotalEccErrors(dev, 0, 0, corrected) != 0) return -3;
+    if (f_nvmlDeviceGetTotalEccErrors(dev, 1, 0, uncorrected) != 0) return -4;
+    return 0;
+}
+
+// PCIe throughput over the last sample window, in KB/s
+static int nvml_get_pcie(int idx, unsigned int* tx, unsigned int* rx) {
+    nvmlDevice_t dev;
+    if (f_nvmlDeviceGetHandleByIndex(idx, &dev) != 0) return -1;
+    if (!f_nvmlDeviceGetPcieThroughput) return -2;
+    // NVML_PCIE_UTIL_TX_BYTES = 0, NVML_PCIE_UTIL_RX_BYTES = 1
+    if (f_nvmlDeviceGetPcieThroughput(dev, 0, tx) != 0) return -3;
+    if (f_nvmlDeviceGetPcieThroughput(dev, 1, rx) != 0) return -4;
+    return 0;
+}
+
+static int nvml_get_fan(int idx, unsigned int* speed) {
+    nvmlDevice_t dev;
+    if (f_nvmlDeviceGetHandleByIndex(idx, &dev) != 0) return -1;
+    if (!f_nvmlDeviceGetFanSpeed) return -2;
+    if (f_nvmlDeviceGetFanSpeed(dev, speed) != 0) return -3;
+    return 0;
+}
+
+// GPU memory used by one process on a device, in bytes.
+// *found is 0 if the pid has no compute context there (or NVML can't
+// attribute memory to it, e.g. under MIG without privileges).
+static int nvml_get_process_memory(int idx, unsigned int pid, unsigned long long* used, int* found) {
+    nvmlDevice_t dev;
+    if (f_nvmlDeviceGetHandleByIndex(idx, &dev) != 0) return -1;
+    if (!f_nvmlDeviceGetComputeRunningProcesses) return -2;
+
+    size_t rec = procs_v2 ? sizeof(nvmlProcessInfo_v2_t) : sizeof(nvmlProcessInfo_v1_t);
+    unsigned int count = 32;
+    void* buf = NULL;
+    nvmlReturn_t rc;
+    for (int attempt = 0; attempt < 3; attempt++) {
+        free(buf);
+        buf = malloc(rec * count);
+        if (!buf) return -3;
+        unsigned int n = count;
+        rc = f_nvmlDeviceGetComputeRunningProcesses(dev, &n, buf);
+        if (rc == NVML_ERROR_INSUFFICIENT_SIZE) { count = n + 8; continue; }
+        count = n;
+        break;
+    }
+    if (rc != 0) { free(buf); return -4; }
+
+    *used = 0;
+    *found = 0;
+    for (unsigned int i = 0; i < count; i++) {
+        unsigned int p;
+        unsigned long long mem;
+        if (procs_v2) {
+            nvmlProcessInfo_v2_t* info = (nvmlProcessInfo_v2_t*)buf + i;
+            p = info->pid; mem = info->usedGpuMemory;
+        } else {
+            nvmlProcessInfo_v1_t* info = (nvmlProcessInfo_v1_t*)buf + i;
+            p = info->pid; mem = info->usedGpuMemory;
+        }
+        if (p != pid || mem == NVML_VALUE_NOT_AVAILABLE_ULL) continue;
+        *used += mem;
+        *found = 1;
+    }
+    free(buf);
+    return 0;
+}
+
 static void nvml_shutdown() {
     if (f_nvmlShutdown) f_nvmlShutdown();
     if (nvml_lib) dlclose(nvml_lib);
@@ -122,20 +274,10 @@ import "C"
 
 import (
 	"fmt"
-	"log"
-)
+	"os"
 
-// GPUInfo holds real GPU metrics from NVML.
-type GPUInfo struct {
-	Name           string
-	Index          int
-	MemoryTotalGB  float64
-	MemoryFreeGB   float64
-	MemoryUsedGB   float64
-	GPUUtilization float64
-	MemUtilization float64
-	TemperatureC   float64
-}
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+)
 
 // NVML wraps NVIDIA Management Library via dlopen (no compile-time dependency).
 type NVML struct {
@@ -157,13 +299,14 @@ func New() (*NVML, error) {
 		return nil, fmt.Errorf("NVML loaded but no GPUs found")
 	}
 
-	log.Printf("🎮 NVML initialized: %d GPU(s) detected", count)
+	log := logging.For("nvml")
+	log.Info("NVML initialized", "gpus", count)
 
-	// Print GPU names
+	// Log GPU names
 	for i := 0; i < count; i++ {
 		var name [256]C.char
 		if C.nvml_get_name(C.int(i), &name[0], 256) == 0 {
-			log.Printf("   GPU %d: %s", i, C.GoString(&name[0]))
+			log.Info("GPU detected", "index", i, "name", C.GoString(&name[0]))
 		}
 	}
 
@@ -221,6 +364,55 @@ func (n *NVML) GetGPUInfo(index int) (*GPUInfo, error) {
 		info.TemperatureC = float64(temp)
 	}
 
+	// Power (NVML reports milliwatts)
+	var powerUsage, powerLimit C.uint
+	if C.nvml_get_power(C.int(index), &powerUsage, &powerLimit) == 0 {
+		info.PowerDrawW = float64(powerUsage) / 1000
+		info.PowerLimitW = float64(powerLimit) / 1000
+	}
+
+	// Clocks
+	var smClock, memClock C.uint
+	if C.nvml_get_clocks(C.int(index), &smClock, &memClock) == 0 {
+		info.SMClockMHz = float64(smClock)
+		info.MemClockMHz = float64(memClock)
+	}
+
+	// Clock throttle reasons
+	var reasons C.ulonglong
+	if C.nvml_get_throttle_reasons(C.int(index), &reasons) == 0 {
+		info.ThrottleReasons = uint64(reasons)
+	}
+
+	// ECC (unsupported on GPUs with ECC disabled — leaves zeros)
+	var eccCorrected, eccUncorrected C.ulonglong
+	if C.nvml_get_ecc(C.int(index), &eccCorrected, &eccUncorrected) == 0 {
+		info.ECCCorrected = uint64(eccCorrected)
+		info.ECCUncorrected = uint64(eccUncorrected)
+	}
+
+	// PCIe throughput
+	var pcieTx, pcieRx C.uint
+	if C.nvml_get_pcie(C.int(index), &pcieTx, &pcieRx) == 0 {
+		info.PCIeTxKBps = float64(pcieTx)
+		info.PCIeRxKBps = float64(pcieRx)
+	}
+
+	// This process's own footprint. NVML reports host PIDs, so inside a
+	// container without the host PID namespace the lookup finds nothing.
+	var procUsed C.ulonglong
+	var found C.int
+	if C.nvml_get_process_memory(C.int(index), C.uint(os.Getpid()), &procUsed, &found) == 0 && found != 0 {
+		info.ProcessMemoryUsedGB = float64(procUsed) / (1024 * 1024 * 1024)
+		info.ProcessTracked = true
+	}
+
+	// Fan (passively cooled GPUs like the T4 report NOT_SUPPORTED)
+	var fan C.uint
+	if C.nvml_get_fan(C.int(index), &fan) == 0 {
+		info.FanSpeedPct = float64(fan)
+	}
+
 	return info, nil
 }
 
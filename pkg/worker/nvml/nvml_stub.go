@@ -0,0 +1,29 @@
+//go:build !nvml
+
+package nvml
+
+import "fmt"
+
+// NVML is a placeholder in builds without the nvml tag.
+// New always fails, so callers fall back to simulated metrics.
+// Build with: go build -tags nvml
+type NVML struct{}
+
+// New reports that NVML support was not compiled in.
+func New() (*NVML, error) {
+	return nil, fmt.Errorf("NVML support not compiled in (build with -tags nvml)")
+}
+
+// Available always returns false.
+func (n *NVML) Available() bool { return false }
+
+// GPUCount always returns 0.
+func (n *NVML) GPUCount() int { return 0 }
+
+// GetGPUInfo always fails.
+func (n *NVML) GetGPUInfo(index int) (*GPUInfo, error) {
+	return nil, fmt.Errorf("NVML not available")
+}
+
+// Shutdown is a no-op.
+func (n *NVML) Shutdown() {}
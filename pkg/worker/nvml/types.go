@@ -0,0 +1,31 @@
+package nvml
+
+// GPUInfo holds real GPU metrics from NVML.
+// Defined without a build tag so callers can use it in every build.
+type GPUInfo struct {
+	Name           string
+	Index          int
+	MemoryTotalGB  float64
+	MemoryFreeGB   float64
+	MemoryUsedGB   float64
+	GPUUtilization float64
+	MemUtilization float64
+	TemperatureC   float64
+
+	// Extended telemetry. Fields the device/driver doesn't support stay zero.
+	PowerDrawW      float64
+	PowerLimitW     float64
+	SMClockMHz      float64
+	MemClockMHz     float64
+	ThrottleReasons uint64 // bitmask of gpu.Throttle* constants
+	ECCCorrected    uint64 // volatile counts since driver load
+	ECCUncorrected  uint64
+	PCIeTxKBps      float64
+	PCIeRxKBps      float64
+	FanSpeedPct     float64
+
+	// GPU memory held by the calling process (summed over its contexts).
+	// ProcessTracked is false when NVML had no record of our PID.
+	ProcessMemoryUsedGB float64
+	ProcessTracked      bool
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/tracing"
 )
 
 // PendingRequest wraps a gRPC request with channels for the response.
@@ -14,7 +15,9 @@ type PendingRequest struct {
 	DoneCh    chan *pb.InferResponse
 	ErrCh     chan error
 	EnqueueAt time.Time
-	index     int // used by heap
+	DoneAt    time.Time     // set by the batcher before the result is sent
+	Span      *tracing.Span // the request's worker.receive span
+	index     int           // used by heap
 }
 
 // PriorityQueue implements heap.Interface for PendingRequests.
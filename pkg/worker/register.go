@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/config"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+	"github.com/kunal/gpu-batch-router/pkg/tlsutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// rpcTimeout bounds each registration call to the router.
+const rpcTimeout = 5 * time.Second
+
+// Registrar keeps the worker registered with a router. It registers on
+// Start, retrying until the router answers, heartbeats as often as the
+// router asks, registers again if the router forgets it (a router
+// restart), and deregisters on Stop so the router drains it.
+type Registrar struct {
+	router string
+	token  string
+	req    *pb.RegisterRequest
+	certs  *tlsutil.Reloader // nil: plaintext to the router
+	conn   *grpc.ClientConn
+	client pb.RegistrationServiceClient
+	log    *slog.Logger
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRegistrar prepares to register the worker with cfg.RouterAddress as
+// cfg.AdvertiseAddress. With a router CA the connection uses TLS, and the
+// worker's own certificate, if it has one, as its client certificate.
+func (w *Worker) NewRegistrar(cfg *config.Config) (*Registrar, error) {
+	creds := insecure.NewCredentials()
+	var certs *tlsutil.Reloader
+	if cfg.RouterTLSCAFile != "" {
+		var err error
+		certs, err = tlsutil.NewReloader(tlsutil.Files{
+			Cert: cfg.TLSCertFile,
+			Key:  cfg.TLSKeyFile,
+			CA:   cfg.RouterTLSCAFile,
+		}, cfg.TLSReload)
+		if err != nil {
+			return nil, fmt.Errorf("router TLS: %w", err)
+		}
+		host, _, _ := net.SplitHostPort(cfg.RouterAddress)
+		creds = credentials.NewTLS(certs.ClientConfig(host))
+	}
+	conn, err := grpc.NewClient(cfg.RouterAddress, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		if certs != nil {
+			certs.Close()
+		}
+		return nil, err
+	}
+	return &Registrar{
+		router: cfg.RouterAddress,
+		token:  cfg.RegistrationToken,
+		req: &pb.RegisterRequest{
+			WorkerId: cfg.WorkerID,
+			Address:  cfg.AdvertiseAddress,
+			Models:   cfg.Models,
+			Capacity: int32(len(w.devices) * cfg.MaxBatchSize),
+		},
+		certs:  certs,
+		conn:   conn,
+		client: pb.NewRegistrationServiceClient(conn),
+		log:    logging.For("registrar").With("router", cfg.RouterAddress),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start registers and heartbeats in the background until Stop.
+func (g *Registrar) Start() {
+	go g.run()
+}
+
+// Stop ends heartbeats and deregisters, so the router stops sending new
+// requests; call it before draining the worker.
+func (g *Registrar) Stop() {
+	close(g.stop)
+	<-g.done
+
+	ctx, cancel := g.callContext()
+	defer cancel()
+	_, err := g.client.Deregister(ctx, &pb.DeregisterRequest{WorkerId: g.req.WorkerId, Address: g.req.Address})
+	if err != nil {
+		g.log.Warn("deregistration failed; the router will expire this worker", "err", err)
+	} else {
+		g.log.Info("deregistered from router")
+	}
+	g.conn.Close()
+	if g.certs != nil {
+		g.certs.Close()
+	}
+}
+
+func (g *Registrar) run() {
+	defer close(g.done)
+	var interval time.Duration // 0 until registered
+	delay := 500 * time.Millisecond
+	for {
+		if interval == 0 {
+			ctx, cancel := g.callContext()
+			resp, err := g.client.Register(ctx, g.req)
+			cancel()
+			if err != nil {
+				g.log.Warn("registration failed, retrying", "err", err, "retry_in", delay)
+				if !g.sleep(delay) {
+					return
+				}
+				delay = min(delay*2, 30*time.Second)
+				continue
+			}
+			interval = time.Duration(resp.HeartbeatMs) * time.Millisecond
+			delay = 500 * time.Millisecond
+			g.log.Info("registered with router", "address", g.req.Address, "heartbeat", interval)
+		}
+
+		if !g.sleep(interval) {
+			return
+		}
+		ctx, cancel := g.callContext()
+		_, err := g.client.Heartbeat(ctx, &pb.HeartbeatRequest{WorkerId: g.req.WorkerId, Address: g.req.Address})
+		cancel()
+		switch status.Code(err) {
+		case codes.OK:
+		case codes.NotFound:
+			g.log.Info("router no longer knows this worker, registering again")
+			interval = 0
+		default:
+			g.log.Warn("heartbeat failed", "err", err)
+		}
+	}
+}
+
+// callContext returns a context for one call, carrying the token.
+func (g *Registrar) callContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+g.token), cancel
+}
+
+// sleep waits for d; it reports false if Stop was called meanwhile.
+func (g *Registrar) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-g.stop:
+		return false
+	case <-t.C:
+		return true
+	}
+}
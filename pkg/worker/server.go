@@ -2,14 +2,19 @@ package worker
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
 	"github.com/kunal/gpu-batch-router/pkg/config"
+	"github.com/kunal/gpu-batch-router/pkg/logging"
+	"github.com/kunal/gpu-batch-router/pkg/tracing"
 	"github.com/kunal/gpu-batch-router/pkg/worker/executor"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Worker is the main worker service.
@@ -19,34 +24,74 @@ type Worker struct {
 
 	cfg     *config.Config
 	queue   *PriorityQueue
-	batcher *Batcher
+	devices []*Device
 	metrics *MetricsCollector
-	exec    executor.GPUExecutor
+	tel     *telemetry
+	log     *slog.Logger
+
+	// streamsDone is closed by EndStreams to finish WatchMetrics calls
+	streamsDone chan struct{}
+	endStreams  sync.Once
+}
+
+// Device bundles the executor and batcher bound to one GPU.
+// All devices pull from the worker's shared priority queue, so whichever
+// device is free next takes the next batch.
+type Device struct {
+	Index   int
+	Exec    executor.GPUExecutor
+	Batcher *Batcher
 }
 
 // New creates a new Worker with the given configuration.
+// One executor + batcher is created per device in cfg.GPUDevices.
 func New(cfg *config.Config) (*Worker, error) {
-	queue := NewPriorityQueue()
+	indices, err := resolveDevices(cfg.GPUDevices)
+	if err != nil {
+		return nil, err
+	}
 
-	// Create executor — defaults to simulation.
-	// Build with `go build -tags onnx` for real ONNX inference.
-	exec := createExecutor(cfg)
-	log.Printf("🔧 Executor: %s", exec.Name())
+	tracer, err := tracing.Setup(cfg.WorkerID, cfg.TraceExport, cfg.TraceFile, cfg.TraceSampleRate)
+	if err != nil {
+		return nil, err
+	}
 
-	batcher := NewBatcher(BatcherConfig{
-		MaxBatchSize: cfg.MaxBatchSize,
-		MaxWaitTime:  cfg.MaxWaitTime,
-		MinBatchSize: 1,
-	}, queue, exec)
+	log := logging.For("worker")
+	queue := NewPriorityQueue()
+	tel := newTelemetry(cfg.WorkerID, cfg.Models, tracer)
+	devices := make([]*Device, 0, len(indices))
+	for _, idx := range indices {
+		// Create executor — defaults to simulation.
+		// Build with `go build -tags onnx` for real ONNX inference.
+		exec := createExecutor(cfg, idx)
+		log.Info("executor ready", "executor", exec.Name(), "device", idx)
+
+		batcher := NewBatcher(BatcherConfig{
+			MaxBatchSize: cfg.MaxBatchSize,
+			MaxWaitTime:  cfg.MaxWaitTime,
+			MinBatchSize: 1,
+			ExecTimeout:  cfg.BatchTimeout,
+			Device:       idx,
+		}, queue, exec, tel)
+
+		devices = append(devices, &Device{Index: idx, Exec: exec, Batcher: batcher})
+	}
 
-	metrics := NewMetricsCollector(cfg.WorkerID, batcher, queue, cfg.UseNVML)
+	metrics := NewMetricsCollector(cfg.WorkerID, devices, queue, cfg.UseNVML, cfg.GPUShare)
+	tel.registry.NewCounterFunc("worker_total_batches", "Total batches processed",
+		func() float64 { return float64(metrics.totalBatches()) })
+	tel.registry.NewCounterFunc("worker_total_requests", "Total requests processed",
+		func() float64 { return float64(metrics.totalRequests()) })
 
 	return &Worker{
 		cfg:     cfg,
 		queue:   queue,
-		batcher: batcher,
+		devices: devices,
 		metrics: metrics,
-		exec:    exec,
+		tel:     tel,
+		log:     log,
+
+		streamsDone: make(chan struct{}),
 	}, nil
 }
 
@@ -58,54 +103,120 @@ func (w *Worker) RegisterGRPC(s *grpc.Server) {
 
 // RegisterMetricsHTTP registers the /metrics HTTP endpoint.
 func (w *Worker) RegisterMetricsHTTP(mux *http.ServeMux) {
-	mux.HandleFunc("/metrics", w.metrics.ServePrometheus)
+	mux.HandleFunc("/metrics", w.ServePrometheus)
 	mux.HandleFunc("/health", func(rw http.ResponseWriter, r *http.Request) {
 		rw.WriteHeader(http.StatusOK)
 		rw.Write([]byte("OK"))
 	})
 }
 
-// StartBatcher starts the micro-batching engine.
+// StartBatcher starts the micro-batching engine on every device.
 func (w *Worker) StartBatcher() {
-	w.batcher.Start()
+	for _, d := range w.devices {
+		d.Batcher.Start()
+	}
 }
 
-// Stop shuts down the worker gracefully.
-func (w *Worker) Stop() {
-	w.batcher.Stop()
+// Reload applies cfg's batching limits to every device. The worker's
+// other settings need a restart; config.Reloader refuses changes to them.
+func (w *Worker) Reload(cfg *config.Config) {
+	for _, d := range w.devices {
+		d.Batcher.SetLimits(cfg.MaxBatchSize, cfg.MaxWaitTime, cfg.BatchTimeout)
+	}
+}
+
+// Stop shuts down the worker gracefully, draining queued requests
+// until ctx expires.
+func (w *Worker) Stop(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, d := range w.devices {
+		wg.Add(1)
+		go func(d *Device) {
+			defer wg.Done()
+			d.Batcher.Stop(ctx)
+		}(d)
+	}
+	wg.Wait()
+
+	// Release executor resources (e.g. ONNX sessions) once no batch can run
+	for _, d := range w.devices {
+		if c, ok := d.Exec.(interface{ Cleanup() }); ok {
+			c.Cleanup()
+		}
+	}
+	w.metrics.Close()
+	if err := w.tel.tracer.Shutdown(ctx); err != nil {
+		w.log.Warn("trace export shutdown failed", "err", err)
+	}
 }
 
 // Infer handles a single inference request via gRPC.
 // It enqueues the request into the priority queue and blocks
 // until the batcher processes it and returns a result.
-func (w *Worker) Infer(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+func (w *Worker) Infer(ctx context.Context, req *pb.InferRequest) (resp *pb.InferResponse, err error) {
 	w.metrics.IncrInFlight()
 	defer w.metrics.DecrInFlight()
 
+	start := time.Now()
+	ctx = logging.With(ctx, "request_id", req.RequestId)
+	ctx, span := w.tel.tracer.Start(ctx, "worker.receive", tracing.WithKind(tracing.KindServer))
+	span.SetAttr("request.id", req.RequestId)
+	span.SetAttr("request.priority", req.Priority.String())
+	span.SetAttr("request.model", req.ModelName)
+	span.SetAttr("worker.id", w.cfg.WorkerID)
+	defer func() {
+		if err != nil {
+			err = toStatus(err)
+			w.logFailure(ctx, err)
+		}
+		w.tel.observeRequest(req, time.Since(start), err)
+		span.SetError(err)
+		span.End()
+	}()
+
 	pending := &PendingRequest{
 		Req:       req,
 		DoneCh:    make(chan *pb.InferResponse, 1),
 		ErrCh:     make(chan error, 1),
-		EnqueueAt: time.Now(),
+		EnqueueAt: start,
+		Span:      span,
 	}
 
 	// Enqueue into priority queue
+	_, enq := w.tel.tracer.Start(ctx, "worker.enqueue")
 	w.queue.Enqueue(pending)
-	// Signal batcher that new work is available
-	w.batcher.Signal()
+	// Signal batchers that new work is available; the first free device takes it
+	for _, d := range w.devices {
+		d.Batcher.Signal()
+	}
+	enq.End()
 
 	// Block until result is ready or context cancelled
 	select {
-	case resp := <-pending.DoneCh:
+	case resp = <-pending.DoneCh:
+		// Covers the hand-off from the batcher back to this handler
+		_, rs := w.tel.tracer.Start(ctx, "worker.respond", tracing.WithStartTime(pending.DoneAt))
 		resp.WorkerId = w.cfg.WorkerID
+		rs.End()
 		return resp, nil
-	case err := <-pending.ErrCh:
+	case err = <-pending.ErrCh:
 		return nil, err
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
 }
 
+// logFailure logs a failed request. Client-side problems (bad input,
+// caller gone) are routine and stay at debug.
+func (w *Worker) logFailure(ctx context.Context, err error) {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.Canceled, codes.DeadlineExceeded:
+		w.log.DebugContext(ctx, "request failed", "code", status.Code(err).String(), "err", err)
+	default:
+		w.log.WarnContext(ctx, "request failed", "code", status.Code(err).String(), "err", err)
+	}
+}
+
 // GetMetrics returns current GPU + worker metrics.
 func (w *Worker) GetMetrics(ctx context.Context, req *pb.MetricsRequest) (*pb.WorkerMetrics, error) {
 	return w.metrics.GetMetrics(), nil
@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"math"
+	"time"
+
+	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/gpu"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Defaults for WatchMetrics when the router leaves them unset.
+const (
+	defaultStreamHeartbeat = 2 * time.Second
+	defaultStreamInterval  = 100 * time.Millisecond
+	minStreamInterval      = 20 * time.Millisecond
+)
+
+// WatchMetrics pushes metrics to the router: a snapshot immediately, then
+// whenever something that affects routing changes noticeably, and at
+// least once per heartbeat so the router can tell a quiet worker from a
+// dead stream. Snapshots come from the collector's shared sampling loop
+// for the stream's interval rather than being collected per stream.
+func (w *Worker) WatchMetrics(req *pb.WatchMetricsRequest, stream grpc.ServerStreamingServer[pb.WorkerMetrics]) error {
+	heartbeat := msOr(req.HeartbeatMs, defaultStreamHeartbeat)
+	interval := max(msOr(req.MinIntervalMs, defaultStreamInterval), minStreamInterval)
+
+	samples, stop := w.metrics.Watch(interval)
+	defer stop()
+
+	w.log.Info("metrics stream opened", "heartbeat", heartbeat, "interval", interval)
+	cur := w.metrics.GetMetrics()
+	var last *pb.WorkerMetrics
+	var lastSent time.Time
+	for {
+		if last == nil || time.Since(lastSent) >= heartbeat || significantChange(last, cur) {
+			if err := stream.Send(cur); err != nil {
+				return err
+			}
+			last, lastSent = cur, time.Now()
+		}
+
+		select {
+		case <-stream.Context().Done():
+			w.log.Info("metrics stream closed by router")
+			return nil
+		case <-w.streamsDone:
+			return status.Error(codes.Unavailable, "worker shutting down")
+		case m := <-samples:
+			// A sample collected just before this stream's first snapshot is older
+			if m.Seq > cur.Seq {
+				cur = m
+			}
+		}
+	}
+}
+
+// EndStreams closes every open WatchMetrics stream. Call it before
+// grpc.Server.GracefulStop, which would otherwise wait on them forever.
+func (w *Worker) EndStreams() {
+	w.endStreams.Do(func() { close(w.streamsDone) })
+}
+
+// significantChange reports whether cur differs from the last pushed
+// snapshot enough to change a routing decision.
+func significantChange(prev, cur *pb.WorkerMetrics) bool {
+	const ignoredThrottle = gpu.ThrottleGpuIdle // flips constantly under bursty load
+	latencyDelta := math.Max(5, prev.AvgLatencyMs*0.2)
+	switch {
+	case prev.Healthy != cur.Healthy,
+		(prev.QueueDepth == 0) != (cur.QueueDepth == 0),
+		abs(int(cur.QueueDepth-prev.QueueDepth)) >= 5,
+		math.Abs(cur.GpuUtilization-prev.GpuUtilization) >= 10,
+		math.Abs(cur.VramFreeGb-prev.VramFreeGb) >= 0.25,
+		math.Abs(cur.TemperatureC-prev.TemperatureC) >= 3,
+		math.Abs(cur.AvgLatencyMs-prev.AvgLatencyMs) >= latencyDelta,
+		prev.ThrottleReasons&^ignoredThrottle != cur.ThrottleReasons&^ignoredThrottle,
+		prev.EccUncorrected != cur.EccUncorrected:
+		return true
+	}
+	return false
+}
+
+func msOr(ms int32, fallback time.Duration) time.Duration {
+	if ms <= 0 {
+		return fallback
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,225 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/gpu"
+	"github.com/kunal/gpu-batch-router/pkg/metrics"
+	"github.com/kunal/gpu-batch-router/pkg/tracing"
+	"github.com/kunal/gpu-batch-router/pkg/worker/executor"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Request and batch outcomes used as metric labels.
+const (
+	outcomeOK        = "ok"
+	outcomeError     = "error"
+	outcomeCancelled = "cancelled"
+	outcomePartial   = "partial" // batch where only some items failed
+)
+
+// telemetry holds the worker's tracer and Prometheus instruments.
+// Histograms and counters are fed from the request path; gauges are
+// refreshed from MetricsCollector on every scrape. Every series carries
+// worker="<id>".
+type telemetry struct {
+	tracer   *tracing.Tracer
+	registry *metrics.Registry
+	models   map[string]bool // model label values; anything else is "other"
+
+	requestDuration *metrics.HistogramVec // priority, model, outcome
+	queueWait       *metrics.HistogramVec // priority, model
+	batchDuration   *metrics.HistogramVec // device, outcome
+	batchSize       *metrics.HistogramVec // device
+	requestErrors   *metrics.CounterVec   // code
+
+	queueDepth     *metrics.GaugeVec
+	lastBatchSize  *metrics.GaugeVec
+	avgLatency     *metrics.GaugeVec
+	vramFree       *metrics.GaugeVec
+	vramTotal      *metrics.GaugeVec
+	utilization    *metrics.GaugeVec
+	temperature    *metrics.GaugeVec
+	gpuShare       *metrics.GaugeVec
+	devUtil        *metrics.GaugeVec   // device
+	devVRAMFree    *metrics.GaugeVec   // device
+	devTemp        *metrics.GaugeVec   // device
+	procVRAM       *metrics.GaugeVec   // device
+	devVRAMTotal   *metrics.GaugeVec   // device
+	power          *metrics.GaugeVec   // device
+	powerLimit     *metrics.GaugeVec   // device
+	clock          *metrics.GaugeVec   // device, clock
+	throttle       *metrics.GaugeVec   // device, reason
+	ecc            *metrics.GaugeVec   // device, type
+	pcie           *metrics.GaugeVec   // device, direction
+	fan            *metrics.GaugeVec   // device
+	devBatches     *metrics.CounterVec // device
+	devFailedItems *metrics.CounterVec // device
+}
+
+func newTelemetry(workerID string, models []string, tracer *tracing.Tracer) *telemetry {
+	r := metrics.NewRegistry(metrics.Labels{"worker": workerID})
+	known := make(map[string]bool, len(models))
+	for _, m := range models {
+		known[m] = true
+	}
+	return &telemetry{
+		tracer:   tracer,
+		registry: r,
+		models:   known,
+
+		requestDuration: r.NewHistogramVec("worker_request_duration_seconds",
+			"Time from a request arriving at the worker to its response", metrics.LatencyBuckets,
+			"priority", "model", "outcome"),
+		queueWait: r.NewHistogramVec("worker_queue_wait_seconds",
+			"Time a request spent queued before its batch started", metrics.LatencyBuckets,
+			"priority", "model"),
+		batchDuration: r.NewHistogramVec("worker_batch_duration_seconds",
+			"Executor time per batch, including bisection retries", metrics.LatencyBuckets,
+			"device", "outcome"),
+		batchSize: r.NewHistogramVec("worker_executed_batch_size",
+			"Number of requests per executed batch", metrics.BatchSizeBuckets,
+			"device"),
+		requestErrors: r.NewCounterVec("worker_request_errors_total",
+			"Failed requests by gRPC status code", "code"),
+
+		queueDepth:    r.NewGaugeVec("worker_queue_depth", "Current queue depth"),
+		lastBatchSize: r.NewGaugeVec("worker_batch_size", "Last batch size (largest across devices)"),
+		avgLatency:    r.NewGaugeVec("worker_avg_latency_ms", "Moving average of batch latency"),
+		vramFree:      r.NewGaugeVec("gpu_vram_free_gb", "Free VRAM available to this worker in GB"),
+		vramTotal:     r.NewGaugeVec("gpu_vram_total_gb", "VRAM budget of this worker in GB"),
+		utilization:   r.NewGaugeVec("gpu_utilization", "GPU utilization percentage"),
+		temperature:   r.NewGaugeVec("gpu_temperature_celsius", "GPU temperature (hottest device)"),
+		gpuShare:      r.NewGaugeVec("worker_gpu_share", "Configured fraction of each device this worker may use"),
+
+		devUtil:        r.NewGaugeVec("gpu_device_utilization", "GPU utilization percentage per device", "device"),
+		devVRAMFree:    r.NewGaugeVec("gpu_device_vram_free_gb", "Free VRAM available to this worker in GB per device", "device"),
+		devTemp:        r.NewGaugeVec("gpu_device_temperature_celsius", "GPU temperature per device", "device"),
+		procVRAM:       r.NewGaugeVec("gpu_process_vram_used_gb", "VRAM held by this worker process per device", "device"),
+		devVRAMTotal:   r.NewGaugeVec("gpu_device_vram_total_gb", "Whole-device VRAM, including other tenants' share", "device"),
+		power:          r.NewGaugeVec("gpu_power_draw_watts", "Power draw per device", "device"),
+		powerLimit:     r.NewGaugeVec("gpu_power_limit_watts", "Enforced power limit per device", "device"),
+		clock:          r.NewGaugeVec("gpu_clock_mhz", "Current clock speed per device", "device", "clock"),
+		throttle:       r.NewGaugeVec("gpu_clock_throttle_active", "Whether a clock throttle reason is active (1) or not (0)", "device", "reason"),
+		ecc:            r.NewGaugeVec("gpu_ecc_errors", "Volatile ECC error count per device", "device", "type"),
+		pcie:           r.NewGaugeVec("gpu_pcie_throughput_kbps", "PCIe throughput per device in KB/s", "device", "direction"),
+		fan:            r.NewGaugeVec("gpu_fan_speed_percent", "Fan speed per device", "device"),
+		devBatches:     r.NewCounterVec("worker_device_batches_total", "Total batches processed per device", "device"),
+		devFailedItems: r.NewCounterVec("worker_device_failed_items_total", "Requests that failed inside a batch per device", "device"),
+	}
+}
+
+// observeRequest records the end-to-end outcome of one Infer call.
+func (t *telemetry) observeRequest(req *pb.InferRequest, elapsed time.Duration, err error) {
+	outcome := outcomeOK
+	if err != nil {
+		code := status.Code(err)
+		t.requestErrors.With(code.String()).Inc()
+		outcome = outcomeError
+		if code == codes.Canceled || code == codes.DeadlineExceeded {
+			outcome = outcomeCancelled
+		}
+	}
+	t.requestDuration.With(req.Priority.String(), t.modelLabel(req), outcome).Observe(elapsed.Seconds())
+}
+
+// observeQueueWait records how long a request waited before its batch ran.
+func (t *telemetry) observeQueueWait(req *pb.InferRequest, wait time.Duration) {
+	t.queueWait.With(req.Priority.String(), t.modelLabel(req)).Observe(wait.Seconds())
+}
+
+// observeBatch records one executed batch on a device.
+func (t *telemetry) observeBatch(device, size, failed int, elapsed time.Duration) {
+	outcome := outcomeOK
+	switch {
+	case failed == size:
+		outcome = outcomeError
+	case failed > 0:
+		outcome = outcomePartial
+	}
+	dev := strconv.Itoa(device)
+	t.batchDuration.With(dev, outcome).Observe(elapsed.Seconds())
+	t.batchSize.With(dev).Observe(float64(size))
+	t.devBatches.With(dev).Inc()
+	t.devFailedItems.With(dev).Add(float64(failed))
+}
+
+// update refreshes the scrape-time gauges from a metrics snapshot.
+func (t *telemetry) update(m *pb.WorkerMetrics) {
+	t.queueDepth.With().Set(float64(m.QueueDepth))
+	t.lastBatchSize.With().Set(float64(m.CurrentBatch))
+	t.avgLatency.With().Set(m.AvgLatencyMs)
+	t.vramFree.With().Set(m.VramFreeGb)
+	t.vramTotal.With().Set(m.VramTotalGb)
+	t.utilization.With().Set(m.GpuUtilization)
+	t.temperature.With().Set(m.TemperatureC)
+	t.gpuShare.With().Set(m.GpuShare)
+
+	for _, d := range m.Devices {
+		dev := strconv.Itoa(int(d.Index))
+		t.devUtil.With(dev).Set(d.GpuUtilization)
+		t.devVRAMFree.With(dev).Set(d.VramFreeGb)
+		t.devTemp.With(dev).Set(d.TemperatureC)
+		t.procVRAM.With(dev).Set(d.ProcessVramUsedGb)
+		t.devVRAMTotal.With(dev).Set(d.DeviceVramTotalGb)
+		t.power.With(dev).Set(d.PowerDrawW)
+		t.powerLimit.With(dev).Set(d.PowerLimitW)
+		t.clock.With(dev, "sm").Set(d.SmClockMhz)
+		t.clock.With(dev, "mem").Set(d.MemClockMhz)
+		for _, r := range gpu.ThrottleReasons {
+			active := 0.0
+			if d.ThrottleReasons&r.Bit != 0 {
+				active = 1
+			}
+			t.throttle.With(dev, r.Name).Set(active)
+		}
+		t.ecc.With(dev, "corrected").Set(float64(d.EccCorrected))
+		t.ecc.With(dev, "uncorrected").Set(float64(d.EccUncorrected))
+		t.pcie.With(dev, "tx").Set(d.PcieTxKbps)
+		t.pcie.With(dev, "rx").Set(d.PcieRxKbps)
+		t.fan.With(dev).Set(d.FanSpeedPct)
+	}
+}
+
+// ServePrometheus writes Prometheus-format metrics to the HTTP response.
+func (w *Worker) ServePrometheus(rw http.ResponseWriter, r *http.Request) {
+	w.tel.update(w.metrics.collect())
+	w.tel.registry.ServeHTTP(rw, r)
+}
+
+// toStatus maps errors from the batching path onto gRPC status codes so
+// callers (and the error counters) can tell client mistakes from failures.
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, executor.ErrInvalidPayload):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// modelLabel is the request's model name if the worker is configured to
+// serve it, "unknown" when unset, and "other" for anything else. Callers
+// choose the name, so using it as is would let them create any number of
+// series.
+func (t *telemetry) modelLabel(req *pb.InferRequest) string {
+	switch {
+	case req.ModelName == "":
+		return "unknown"
+	case t.models[req.ModelName]:
+		return req.ModelName
+	}
+	return "other"
+}
@@ -133,6 +133,7 @@ for i in 1 2 3; do
     MAX_WAIT_MS=50 \
     EXECUTOR_TYPE="${EXECUTOR_TYPE}" \
     USE_NVML=true \
+    GPU_MEMORY_SHARE=0.33 \
     LD_LIBRARY_PATH="${PIP_ORT_PATH}:${LD_LIBRARY_PATH:-}" \
     nohup ./bin/worker > /tmp/worker-${i}.log 2>&1 &
     echo "   ⚡ Worker-${i} on :${GRPC_PORT}"
@@ -6,25 +6,48 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"net"
 	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	pb "github.com/kunal/gpu-batch-router/gen/inference/v1"
+	"github.com/kunal/gpu-batch-router/pkg/tlsutil"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 func main() {
 	addr := flag.String("addr", "localhost:50051", "Router address")
 	concurrency := flag.Int("concurrency", 50, "Number of concurrent clients")
 	duration := flag.Duration("duration", 30*time.Second, "Test duration")
+	caFile := flag.String("tls-ca", "", "CA bundle to verify the router with (enables TLS)")
+	certFile := flag.String("tls-cert", "", "Client certificate, if the router requires one")
+	keyFile := flag.String("tls-key", "", "Client key")
+	serverName := flag.String("tls-server-name", "", "Name expected in the router's certificate (default: host of -addr)")
+	token := flag.String("token", "", "API key or JWT, sent as authorization: Bearer")
+	model := flag.String("model", "resnet50", "Model name to request")
 	flag.Parse()
 
 	log.Printf("🚀 Load test starting: addr=%s, concurrency=%d, duration=%v", *addr, *concurrency, *duration)
 
-	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	creds := insecure.NewCredentials()
+	if *caFile != "" || *certFile != "" {
+		certs, err := tlsutil.NewReloader(tlsutil.Files{Cert: *certFile, Key: *keyFile, CA: *caFile}, 0)
+		if err != nil {
+			log.Fatalf("Failed to load TLS files: %v", err)
+		}
+		name := *serverName
+		if name == "" {
+			name, _, _ = net.SplitHostPort(*addr)
+		}
+		creds = credentials.NewTLS(certs.ClientConfig(name))
+	}
+
+	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
@@ -73,11 +96,14 @@ func main() {
 
 				reqStart := time.Now()
 				reqCtx, reqCancel := context.WithTimeout(context.Background(), 10*time.Second)
+				if *token != "" {
+					reqCtx = metadata.AppendToOutgoingContext(reqCtx, "authorization", "Bearer "+*token)
+				}
 				resp, err := client.Infer(reqCtx, &pb.InferRequest{
 					RequestId: fmt.Sprintf("req-%d-%d", clientID, totalRequests.Load()),
 					Payload:   make([]byte, 1024), // 1KB payload
 					Timestamp: time.Now().UnixNano(),
-					ModelName: "resnet50",
+					ModelName: *model,
 					Priority:  pri,
 				})
 